@@ -0,0 +1,172 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// The Additional keys which carry --control-allow-cidr, --data-allow-cidr
+// and --ssh-allow-cidr to the provisioners, as comma separated CIDRs. When
+// the control or data key is not set the ports it covers stay open to every
+// address, SSH is only opened when its key is set.
+const (
+	controlAllowCIDRKey = "control-allow-cidr"
+	dataAllowCIDRKey    = "data-allow-cidr"
+	sshAllowCIDRKey     = "ssh-allow-cidr"
+)
+
+// anyIPv4 is the source range which the provisioners open their ports to
+const anyIPv4 = "0.0.0.0/0"
+
+// sshPort is only opened by --ssh-allow-cidr, so it is split out of the
+// data ports when they are a range
+const sshPort = 22
+
+// parseAllowCIDRs parses the comma separated CIDRs given with flag, a bare
+// IP address is taken as a single host. The CIDRs are returned in their
+// canonical form, i.e. 192.0.2.0/24 for 192.0.2.10/24, without duplicates.
+func parseAllowCIDRs(flag, value string) ([]string, error) {
+	seen := map[string]bool{}
+	var cidrs []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		if ip := net.ParseIP(part); ip != nil {
+			if ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("--%s must be a comma separated list of CIDRs or IP addresses, i.e. 203.0.113.0/24, but got: %q", flag, part)
+		}
+
+		cidr := ipNet.String()
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs, nil
+}
+
+// validateAllowCIDRs checks that the provider's firewall can be restricted,
+// and warns about the checks and challenges which the CIDRs may block
+func validateAllowCIDRs(control, data, ssh []string, tcp bool, spec providerSpec, progress io.Writer) error {
+	if len(control) == 0 && len(data) == 0 && len(ssh) == 0 {
+		return nil
+	}
+
+	if !spec.AllowCIDR {
+		return fmt.Errorf("--control-allow-cidr, --data-allow-cidr and --ssh-allow-cidr are not supported for the %s provider, use one of: %s",
+			spec.Name, strings.Join(providersWith(func(s providerSpec) bool { return s.AllowCIDR }), ", "))
+	}
+	if readinessMayBeBlocked(control, data, tcp) {
+		fmt.Fprintln(progress, "Note: the readiness check connects to the exit-server from this machine, so when it fails the exit-server is kept rather than rolled back, give --ready-timeout 0 to skip it")
+	}
+	if len(data) > 0 && !tcp {
+		fmt.Fprintln(progress, "Warning: --data-allow-cidr also restricts port 80, which Let's Encrypt must reach from any address to issue a certificate")
+	}
+	return nil
+}
+
+// readinessMayBeBlocked reports whether the CIDRs restrict a port which the
+// readiness probes connect to from this machine, whose address may not be
+// allowed
+func readinessMayBeBlocked(control, data []string, tcp bool) bool {
+	return len(control) > 0 || (len(data) > 0 && !tcp)
+}
+
+// allowCIDRs gives the CIDRs recorded in the host request under key, or nil
+// when the ports it covers are open to every address
+func allowCIDRs(host provision.BasicHost, key string) []string {
+	value := host.Additional[key]
+	if len(value) == 0 {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// hasAllowCIDRs reports whether any of the allow CIDR keys is set
+func hasAllowCIDRs(host provision.BasicHost) bool {
+	return len(host.Additional[controlAllowCIDRKey]) > 0 || len(host.Additional[dataAllowCIDRKey]) > 0 ||
+		len(host.Additional[sshAllowCIDRKey]) > 0
+}
+
+// sourceRanges gives the CIDRs for a firewall rule, every IPv4 address when
+// none were given
+func sourceRanges(cidrs []string) []string {
+	if len(cidrs) == 0 {
+		return []string{anyIPv4}
+	}
+	return cidrs
+}
+
+// dataPortRanges gives the data ports of a tunnel as port ranges, i.e.
+// "443" or "23-8122". They are the ports given with --tcp-ports, or every
+// other port when a TCP tunnel has none, or 80 and 443 for a HTTPS tunnel.
+// The control port and SSH port are never included in the range, so that
+// they can be given their own rules.
+func dataPortRanges(host provision.BasicHost, controlPort string) []string {
+	if ports := host.Additional["ports"]; len(ports) > 0 {
+		return strings.Split(ports, ",")
+	}
+	if host.Additional["pro"] != "true" {
+		return []string{"80", "443"}
+	}
+
+	port, err := strconv.Atoi(controlPort)
+	if err != nil {
+		port = inletsProControlPort
+	}
+	var ranges []string
+	for _, r := range splitPortRange(1, 65535, sshPort, port) {
+		ranges = append(ranges, formatPortRange(r[0], r[1]))
+	}
+	return ranges
+}
+
+// splitPortRange gives the range from-to without the ports in exclude, as
+// one or more ranges in order, or none when the range only held those ports
+func splitPortRange(from, to int, exclude ...int) [][2]int {
+	ranges := [][2]int{{from, to}}
+	for _, port := range exclude {
+		var split [][2]int
+		for _, r := range ranges {
+			if port < r[0] || port > r[1] {
+				split = append(split, r)
+				continue
+			}
+			if port > r[0] {
+				split = append(split, [2]int{r[0], port - 1})
+			}
+			if port < r[1] {
+				split = append(split, [2]int{port + 1, r[1]})
+			}
+		}
+		ranges = split
+	}
+	return ranges
+}
+
+// formatPortRange gives "from-to", or the port alone when they are equal
+func formatPortRange(from, to int) string {
+	if from == to {
+		return strconv.Itoa(from)
+	}
+	return fmt.Sprintf("%d-%d", from, to)
+}
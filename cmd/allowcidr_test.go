@@ -0,0 +1,144 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/inlets/cloud-provision/provision"
+)
+
+func Test_ParseAllowCIDRs(t *testing.T) {
+	got, err := parseAllowCIDRs("control-allow-cidr", "203.0.113.10, 198.51.100.7/24,2001:db8::1,203.0.113.10/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"203.0.113.10/32", "198.51.100.0/24", "2001:db8::1/128"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+
+	if got, err := parseAllowCIDRs("data-allow-cidr", ""); err != nil || len(got) != 0 {
+		t.Errorf("want no CIDRs for an empty value, but got: %v, %v", got, err)
+	}
+}
+
+func Test_ParseAllowCIDRs_Invalid(t *testing.T) {
+	for _, value := range []string{"office", "203.0.113.0/33", "203.0.113"} {
+		_, err := parseAllowCIDRs("data-allow-cidr", value)
+		if err == nil || !strings.Contains(err.Error(), "--data-allow-cidr") {
+			t.Errorf("%s: want an error naming the flag, but got: %v", value, err)
+		}
+	}
+}
+
+func Test_ValidateAllowCIDRs(t *testing.T) {
+	ovh, _ := getProviderSpec("ovh")
+	err := validateAllowCIDRs([]string{"203.0.113.10/32"}, nil, nil, true, ovh, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "use one of: digitalocean, gce, ec2, azure, scaleway, linode, hetzner, vultr") {
+		t.Errorf("want an error listing the providers which support it, but got: %v", err)
+	}
+
+	ec2Spec, _ := getProviderSpec("ec2")
+	var progress bytes.Buffer
+	if err := validateAllowCIDRs(nil, []string{"198.51.100.0/24"}, nil, false, ec2Spec, &progress); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(progress.String(), "Let's Encrypt") {
+		t.Errorf("want a warning about Let's Encrypt for a HTTPS tunnel, but got: %q", progress.String())
+	}
+}
+
+func Test_DataPortRanges(t *testing.T) {
+	cases := []struct {
+		additional map[string]string
+		want       []string
+	}{
+		{map[string]string{"pro": "true", "ports": "22,443"}, []string{"22", "443"}},
+		{map[string]string{"pro": "true"}, []string{"1-21", "23-8122", "8124-65535"}},
+		{map[string]string{"pro": "false"}, []string{"80", "443"}},
+	}
+	for _, c := range cases {
+		got := dataPortRanges(provision.BasicHost{Additional: c.additional}, "8123")
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: want %v, but got %v", c.additional, c.want, got)
+		}
+	}
+}
+
+func Test_SplitPortRange(t *testing.T) {
+	cases := []struct {
+		from, to int
+		exclude  []int
+		want     [][2]int
+	}{
+		{1024, 65535, []int{8123}, [][2]int{{1024, 8122}, {8124, 65535}}},
+		{1024, 65535, nil, [][2]int{{1024, 65535}}},
+		{8123, 8123, []int{8123}, nil},
+		{8123, 9000, []int{8123}, [][2]int{{8124, 9000}}},
+		{1, 65535, []int{22, 8123}, [][2]int{{1, 21}, {23, 8122}, {8124, 65535}}},
+		{22, 22, []int{22, 8123}, nil},
+	}
+	for _, c := range cases {
+		if got := splitPortRange(c.from, c.to, c.exclude...); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%d-%d without %v: want %v, but got %v", c.from, c.to, c.exclude, c.want, got)
+		}
+	}
+}
+
+// formatPermissions gives "from-to:cidr|cidr" for each permission
+func formatPermissions(perms []*ec2.IpPermission) []string {
+	var res []string
+	for _, perm := range perms {
+		var cidrs []string
+		for _, r := range perm.IpRanges {
+			cidrs = append(cidrs, aws.StringValue(r.CidrIp))
+		}
+		for _, r := range perm.Ipv6Ranges {
+			cidrs = append(cidrs, aws.StringValue(r.CidrIpv6))
+		}
+		res = append(res, formatPortRange(int(aws.Int64Value(perm.FromPort)), int(aws.Int64Value(perm.ToPort)))+":"+strings.Join(cidrs, "|"))
+	}
+	return res
+}
+
+func Test_EC2IngressChanges(t *testing.T) {
+	// The rules cloud-provision makes for a TCP tunnel without --tcp-ports
+	perms := []*ec2.IpPermission{
+		ec2Permission(8123, 8123, []string{anyIPv4}),
+		ec2Permission(1024, 65535, []string{anyIPv4}),
+	}
+
+	revoke, authorize := ec2IngressChanges(perms, 8123, []string{"203.0.113.10/32"}, nil)
+	if got, want := formatPermissions(revoke), []string{"8123:0.0.0.0/0", "1024-65535:0.0.0.0/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want revoke %v, but got %v", want, got)
+	}
+	// The high ports stay open to any address, but no longer include the
+	// control port
+	if got, want := formatPermissions(authorize), []string{"8123:203.0.113.10/32", "1024-8122:0.0.0.0/0", "8124-65535:0.0.0.0/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want authorize %v, but got %v", want, got)
+	}
+}
+
+func Test_EC2IngressChanges_DataOnly(t *testing.T) {
+	perms := []*ec2.IpPermission{
+		ec2Permission(8123, 8123, []string{anyIPv4}),
+		ec2Permission(80, 80, []string{anyIPv4}),
+		ec2Permission(443, 443, []string{anyIPv4}),
+		ec2Permission(22, 22, []string{"192.0.2.0/24"}),
+	}
+
+	revoke, authorize := ec2IngressChanges(perms, 8123, nil, []string{"198.51.100.0/24", "2001:db8::/32"})
+	if got, want := formatPermissions(revoke), []string{"80:0.0.0.0/0", "443:0.0.0.0/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want revoke %v, but got %v", want, got)
+	}
+	if got, want := formatPermissions(authorize), []string{"80:198.51.100.0/24|2001:db8::/32", "443:198.51.100.0/24|2001:db8::/32"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want authorize %v, but got %v", want, got)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// archs are the values accepted by --arch
+var archs = []string{"amd64", "arm64"}
+
+// validateArch checks that the provider has a plan and OS image for the
+// architecture, which createHost then chooses
+func validateArch(arch, provider string) error {
+	if !contains(archs, arch) {
+		return fmt.Errorf("--arch must be one of: %s", strings.Join(archs, ", "))
+	}
+
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return err
+	}
+	if _, ok := spec.Images[arch]; !ok {
+		supported := providersWith(func(s providerSpec) bool {
+			_, ok := s.Images[arch]
+			return ok
+		})
+		return fmt.Errorf("--arch %s is not supported by the %s provider, use one of: %s", arch, provider, strings.Join(supported, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ValidateArch(t *testing.T) {
+	if err := validateArch("arm64", "hetzner"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := validateArch("amd64", "digitalocean"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := validateArch("arm64", "digitalocean"); err == nil {
+		t.Errorf("want error for arm64 on digitalocean")
+	}
+	if err := validateArch("riscv64", "ec2"); err == nil {
+		t.Errorf("want error for an unknown architecture")
+	}
+}
+
+func Test_InletsProAsset(t *testing.T) {
+	if got := inletsProAsset("amd64"); got != "inlets-pro" {
+		t.Errorf("want inlets-pro, but got: %s", got)
+	}
+	if got := inletsProAsset("arm64"); got != "inlets-pro-arm64" {
+		t.Errorf("want inlets-pro-arm64, but got: %s", got)
+	}
+}
+
+func Test_CreateHost_Arm64(t *testing.T) {
+	cases := []struct {
+		provider string
+		wantPlan string
+		wantOS   string
+	}{
+		{"ec2", "t4g.nano", "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-20250822"},
+		{"gce", "t2a-standard-1", "projects/ubuntu-os-cloud/global/images/ubuntu-minimal-2204-jammy-arm64-v20240606"},
+		{"hetzner", "cax11", "debian-13"},
+		{"scaleway", "COPARM1-2C-8G", "ubuntu-jammy"},
+	}
+
+	for _, c := range cases {
+		host, err := createHost(c.provider, hostOptions{
+			Name:        "tunnel-1",
+			Region:      "region-1",
+			Zone:        "zone-1",
+			ProjectID:   "project-1",
+			Arch:        "arm64",
+			UserData:    "#!/bin/bash",
+			ControlPort: "8123",
+			TCP:         true,
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", c.provider, err)
+		}
+		if host.Plan != c.wantPlan {
+			t.Errorf("%s: want plan %s, but got: %s", c.provider, c.wantPlan, host.Plan)
+		}
+		if host.OS != c.wantOS {
+			t.Errorf("%s: want OS %s, but got: %s", c.provider, c.wantOS, host.OS)
+		}
+	}
+
+	host, err := createHost("azure", hostOptions{
+		Name:        "tunnel-1",
+		Region:      "eastus",
+		Arch:        "arm64",
+		UserData:    "#!/bin/bash",
+		ControlPort: "8123",
+		TCP:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := host.Additional["imageSku"]; got != "22_04-lts-arm64" {
+		t.Errorf("azure: want imageSku 22_04-lts-arm64, but got: %s", got)
+	}
+}
+
+func Test_MakeExitServerUserdata_Arm64(t *testing.T) {
+	got := makeExitServerUserdata("token", "0.11.5", "arm64", "", "echo 203.0.113.10")
+
+	for _, want := range []string{
+		"/releases/download/$VERSION/inlets-pro-arm64 -o /tmp/inlets-pro",
+		"/releases/download/$VERSION/inlets-pro-arm64.sha256",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want user-data to contain %q, but got\n%s", want, got)
+		}
+	}
+}
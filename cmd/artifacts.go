@@ -0,0 +1,225 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clientConfig is what an inlets-pro client needs to connect to an
+// exit-server, Ports is only used in TCP mode
+type clientConfig struct {
+	Name     string
+	Mode     string
+	URL      string
+	Token    string
+	Upstream string
+	Ports    string
+	Version  string
+}
+
+func makeClientConfig(s tunnelSummary) clientConfig {
+	c := clientConfig{
+		Name:     s.Name,
+		Mode:     s.Mode,
+		URL:      fmt.Sprintf("wss://%s:%d", s.IP, s.ControlPort),
+		Token:    s.Token,
+		Upstream: s.Upstream,
+		Version:  s.InletsProVersion,
+	}
+	if len(c.Upstream) == 0 {
+		c.Upstream = defaultUpstream(s.Mode)
+	}
+	if s.Mode == "tcp" {
+		c.Ports = clientPorts(s.Ports)
+	}
+	return c
+}
+
+// args gives the arguments for inlets-pro, without the token so that
+// each artifact can pass it in the way that suits it
+func (c clientConfig) args() []string {
+	args := []string{
+		c.Mode,
+		"client",
+		"--url=" + c.URL,
+		"--upstream=" + c.Upstream,
+	}
+	if c.Mode == "tcp" {
+		args = append(args, "--ports="+c.Ports)
+	}
+	return args
+}
+
+// clientArtifacts are written by --client-artifacts, named by file
+func clientArtifacts(c clientConfig) map[string]string {
+	return map[string]string{
+		"inlets-client.service": makeSystemdUnit(c),
+		"kubernetes.yaml":       makeKubernetesClient(c),
+		"docker-compose.yaml":   makeComposeClient(c),
+		"inlets-client.ps1":     makePowerShellClient(c),
+	}
+}
+
+// writeClientArtifacts writes the client artifacts for the exit-server
+// into a directory named after it within dir, and returns that directory.
+// The files hold the auth token, so are only readable by the user.
+func writeClientArtifacts(dir string, s tunnelSummary) (string, error) {
+	target := filepath.Join(dir, s.Name)
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return "", err
+	}
+
+	for name, contents := range clientArtifacts(makeClientConfig(s)) {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(contents), 0600); err != nil {
+			return "", err
+		}
+	}
+	return target, nil
+}
+
+func makeSystemdUnit(c clientConfig) string {
+	var args []string
+	// The service runs as root without a HOME, so the license is read
+	// from a fixed path rather than ~/.inlets/LICENSE
+	for _, arg := range append(c.args(), "--token="+c.Token, "--license-file=/etc/inlets/LICENSE") {
+		args = append(args, systemdQuote(arg))
+	}
+
+	return fmt.Sprintf(`# Install with:
+#   sudo install -D -m 0600 $HOME/.inlets/LICENSE /etc/inlets/LICENSE
+#   sudo cp inlets-client.service /etc/systemd/system/inlets-%s.service
+#   sudo systemctl daemon-reload
+#   sudo systemctl enable --now inlets-%s
+[Unit]
+Description=inlets %s client for %s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+Restart=always
+RestartSec=2
+ExecStart=/usr/local/bin/inlets-pro %s
+
+[Install]
+WantedBy=multi-user.target
+`, c.Name, c.Name, c.Mode, c.Name, strings.Join(args, " "))
+}
+
+// systemdQuote quotes an argument for ExecStart, where $ and % would
+// otherwise be expanded
+func systemdQuote(arg string) string {
+	quoted := strconv.Quote(arg)
+	quoted = strings.ReplaceAll(quoted, "$", "$$")
+	return strings.ReplaceAll(quoted, "%", "%%")
+}
+
+func makeKubernetesClient(c clientConfig) string {
+	args := append(c.args(),
+		"--token-file=/var/inlets/token/token",
+		"--license-file=/var/inlets/license/LICENSE")
+
+	var argList string
+	for _, arg := range args {
+		argList += fmt.Sprintf("        - %q\n", arg)
+	}
+
+	return fmt.Sprintf(`# The license is read from a separate secret, create it with:
+#   kubectl create secret generic inlets-license --from-file LICENSE=$HOME/.inlets/LICENSE
+# Set --upstream to the Service to expose, then apply this file with:
+#   kubectl apply -f kubernetes.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: inlets-client-%s
+type: Opaque
+stringData:
+  token: %q
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: inlets-client-%s
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: inlets-client-%s
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: inlets-client-%s
+    spec:
+      containers:
+      - name: inlets-client
+        image: ghcr.io/inlets/inlets-pro:%s
+        imagePullPolicy: IfNotPresent
+        command: ["inlets-pro"]
+        args:
+%s        volumeMounts:
+        - name: token
+          mountPath: /var/inlets/token
+          readOnly: true
+        - name: license
+          mountPath: /var/inlets/license
+          readOnly: true
+      volumes:
+      - name: token
+        secret:
+          secretName: inlets-client-%s
+      - name: license
+        secret:
+          secretName: inlets-license
+`, c.Name, c.Token, c.Name, c.Name, c.Name, c.Version, argList, c.Name)
+}
+
+func makeComposeClient(c clientConfig) string {
+	args := append(c.args(),
+		"--token="+c.Token,
+		"--license-file=/var/inlets/LICENSE")
+
+	// Compose interpolates variables in the file, so $ must be escaped
+	var argList string
+	for _, arg := range args {
+		argList += fmt.Sprintf("      - %q\n", strings.ReplaceAll(arg, "$", "$$"))
+	}
+
+	return fmt.Sprintf(`# Start with: docker compose up -d
+# The host's network is used so that the upstream on 127.0.0.1 is reachable
+services:
+  inlets-client:
+    image: ghcr.io/inlets/inlets-pro:%s
+    restart: always
+    network_mode: host
+    command:
+%s    volumes:
+      - ${HOME}/.inlets/LICENSE:/var/inlets/LICENSE:ro
+`, c.Version, argList)
+}
+
+func makePowerShellClient(c clientConfig) string {
+	// A Windows service has no user profile, so the license is read from
+	// a fixed path rather than ~/.inlets/LICENSE
+	args := append(c.args(), "--token="+c.Token, `--license-file=C:\ProgramData\inlets\LICENSE`)
+
+	var argList []string
+	for _, arg := range args {
+		argList = append(argList, fmt.Sprintf("'%s'", strings.ReplaceAll(arg, "'", "''")))
+	}
+
+	return fmt.Sprintf(`# Run with: powershell -ExecutionPolicy Bypass -File inlets-client.ps1
+# inlets-pro.exe must be in the PATH, get it with: inletsctl download
+# Copy the license to where the script reads it with:
+#   New-Item -ItemType Directory -Force C:\ProgramData\inlets
+#   Copy-Item $HOME\.inlets\LICENSE C:\ProgramData\inlets\LICENSE
+$ErrorActionPreference = "Stop"
+
+& inlets-pro.exe %s
+`, strings.Join(argList, " "))
+}
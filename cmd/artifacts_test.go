@@ -0,0 +1,126 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func testSummary(mode string) tunnelSummary {
+	return tunnelSummary{
+		Name:             "tunnel-1",
+		IP:               "192.0.2.1",
+		Mode:             mode,
+		ControlPort:      8123,
+		Token:            "it's-a-secret",
+		InletsProVersion: "0.11.5",
+	}
+}
+
+func Test_MakeClientConfig_TCP(t *testing.T) {
+	c := makeClientConfig(testSummary("tcp"))
+
+	want := []string{"tcp", "client", "--url=wss://192.0.2.1:8123", "--upstream=127.0.0.1", "--ports=2222"}
+	if got := c.args(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("want args %q, but got: %q", want, got)
+	}
+}
+
+func Test_MakeClientConfig_TCPPorts(t *testing.T) {
+	s := testSummary("tcp")
+	s.Upstream = "192.168.0.10"
+	s.Ports = []int{22, 5432}
+	c := makeClientConfig(s)
+
+	want := []string{"tcp", "client", "--url=wss://192.0.2.1:8123", "--upstream=192.168.0.10", "--ports=22,5432"}
+	if got := c.args(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("want args %q, but got: %q", want, got)
+	}
+}
+
+func Test_MakeKubernetesClient_Valid(t *testing.T) {
+	manifest := makeKubernetesClient(makeClientConfig(testSummary("http")))
+
+	var docs []map[string]interface{}
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			break
+		}
+		docs = append(docs, doc)
+	}
+
+	if len(docs) != 2 || docs[0]["kind"] != "Secret" || docs[1]["kind"] != "Deployment" {
+		t.Fatalf("want a Secret and a Deployment, but got:\n%s", manifest)
+	}
+
+	token := docs[0]["stringData"].(map[interface{}]interface{})["token"]
+	if token != "it's-a-secret" {
+		t.Errorf("want the token in the Secret, but got: %v", token)
+	}
+	if strings.Contains(manifest, "--token=") {
+		t.Errorf("want the token to be read from the Secret, not given in the args")
+	}
+}
+
+func Test_MakePowerShellClient_QuotesArgs(t *testing.T) {
+	script := makePowerShellClient(makeClientConfig(testSummary("http")))
+
+	if !strings.Contains(script, `'--token=it''s-a-secret'`) {
+		t.Errorf("want the token quoted for PowerShell, but got:\n%s", script)
+	}
+	want := `& inlets-pro.exe 'http' 'client' '--url=wss://192.0.2.1:8123' '--upstream=http://127.0.0.1:8080'`
+	if !strings.Contains(script, want) {
+		t.Errorf("want %q in the script, but got:\n%s", want, script)
+	}
+}
+
+func Test_ClientArtifacts_LicenseFile(t *testing.T) {
+	c := makeClientConfig(testSummary("tcp"))
+
+	cases := map[string]string{
+		"systemd":    makeSystemdUnit(c),
+		"powershell": makePowerShellClient(c),
+	}
+	for name, artifact := range cases {
+		if !strings.Contains(artifact, "--license-file=") {
+			t.Errorf("%s: want the license file to be given, but got:\n%s", name, artifact)
+		}
+	}
+}
+
+func Test_WriteClientArtifacts(t *testing.T) {
+	dir := t.TempDir()
+
+	target, err := writeClientArtifacts(dir, testSummary("tcp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "tunnel-1"); target != want {
+		t.Errorf("want artifacts in %s, but got: %s", want, target)
+	}
+
+	for _, name := range []string{"inlets-client.service", "kubernetes.yaml", "docker-compose.yaml", "inlets-client.ps1"} {
+		info, err := os.Stat(filepath.Join(target, name))
+		if err != nil {
+			t.Errorf("want %s to be written: %s", name, err)
+			continue
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("want %s to be readable only by the user, but got: %s", name, info.Mode().Perm())
+		}
+	}
+}
+
+func Test_SystemdQuote(t *testing.T) {
+	if got, want := systemdQuote(`--token=a$b%c"d`), `"--token=a$$b%%c\"d"`; got != want {
+		t.Errorf("want %s, but got: %s", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// The names given by cloud-provision to the network security group of an
+// exit-server and to its rules, the rule for all ports is used for a TCP
+// tunnel and the others for a HTTPS tunnel. The rules which replace them
+// are named InletsControl and InletsPorts, and SSH is opened by InletsSSH.
+const (
+	azureSecurityGroup = "inlets-vm-nsg"
+	azureAllPortsRule  = "AllPorts"
+	azurePortsRule     = "InletsPorts"
+	azureControlRule   = "InletsControl"
+	azureSSHRule       = "InletsSSH"
+)
+
+// azureHTTPSRules are the rules given to a HTTPS tunnel by cloud-provision
+var azureHTTPSRules = []string{"HTTPS", "HTTP", "HTTP8080"}
+
+// azureProvisioner extends the Azure provisioner from cloud-provision,
+// which opens every port for a TCP tunnel. When the "ports" Additional key
+// or an allowed CIDR is given, the rules from cloud-provision are replaced
+// with one for the control port and one for the data ports, each open to
+// its allowed CIDRs, once the deployment has finished. The ports are
+// recorded in the inlets-ports tag of the resource group.
+type azureProvisioner struct {
+	*provision.AzureProvisioner
+	subscriptionID string
+	rules          *armnetwork.SecurityRulesClient
+	groups         *armresources.ResourceGroupsClient
+
+	// pending holds the rules of the deployments which are waiting for
+	// their security rules to be replaced
+	pending map[string]azureRules
+}
+
+// azureRules are the security rules which replace those made by
+// cloud-provision for a deployment
+type azureRules struct {
+	ControlPort  string
+	ControlCIDRs []string
+	DataPorts    []string
+	DataCIDRs    []string
+	SSHCIDRs     []string
+
+	// Ports are given by --tcp-ports, for the inlets-ports tag
+	Ports string
+
+	// Replaced are the rules made by cloud-provision
+	Replaced []string
+}
+
+func newAzureProvisioner(subscriptionID, authFileContents string) (*azureProvisioner, error) {
+	p, err := provision.NewAzureProvisioner(subscriptionID, authFileContents)
+	if err != nil {
+		return nil, err
+	}
+
+	// NewAzureProvisioner sets the environment variables from the auth file
+	credential, err := azidentity.NewEnvironmentCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := armnetwork.NewSecurityRulesClient(subscriptionID, credential, nil)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := armresources.NewResourceGroupsClient(subscriptionID, credential, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureProvisioner{
+		AzureProvisioner: p,
+		subscriptionID:   subscriptionID,
+		rules:            rules,
+		groups:           groups,
+		pending:          map[string]azureRules{},
+	}, nil
+}
+
+// Provision starts the deployment with cloud-provision, its security rules
+// are replaced by Status once it has finished
+func (p *azureProvisioner) Provision(host provision.BasicHost) (*provision.ProvisionedHost, error) {
+	res, err := p.AzureProvisioner.Provision(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := host.Additional["ports"]
+	if len(ports) == 0 && !hasAllowCIDRs(host) {
+		return res, nil
+	}
+
+	replaced := azureHTTPSRules
+	if host.Additional["pro"] == "true" {
+		replaced = []string{azureAllPortsRule}
+	}
+	p.pending[res.ID] = azureRules{
+		ControlPort:  host.Additional["inlets-port"],
+		ControlCIDRs: allowCIDRs(host, controlAllowCIDRKey),
+		DataPorts:    dataPortRanges(host, host.Additional["inlets-port"]),
+		DataCIDRs:    allowCIDRs(host, dataAllowCIDRKey),
+		SSHCIDRs:     allowCIDRs(host, sshAllowCIDRKey),
+		Ports:        ports,
+		Replaced:     replaced,
+	}
+	return res, nil
+}
+
+// Status gives the status from cloud-provision, once the deployment has
+// finished its security rules are replaced and the resource group is tagged
+func (p *azureProvisioner) Status(id string) (*provision.ProvisionedHost, error) {
+	res, err := p.AzureProvisioner.Status(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, ok := p.pending[id]
+	if !ok || res.Status != provision.ActiveStatus {
+		return res, nil
+	}
+
+	group, _, _ := strings.Cut(id, "|")
+	ctx := context.Background()
+
+	if err := p.createRule(ctx, group, azureControlRule, 260, []string{rules.ControlPort}, rules.ControlCIDRs); err != nil {
+		return nil, err
+	}
+	if err := p.createRule(ctx, group, azurePortsRule, 270, rules.DataPorts, rules.DataCIDRs); err != nil {
+		return nil, err
+	}
+	if len(rules.SSHCIDRs) > 0 {
+		if err := p.createRule(ctx, group, azureSSHRule, 250, []string{"22"}, rules.SSHCIDRs); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range rules.Replaced {
+		deleted, err := p.rules.BeginDelete(ctx, group, azureSecurityGroup, name, nil)
+		if err == nil {
+			_, err = deleted.PollUntilDone(ctx, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to delete security rule %s in %s: %w", name, group, err)
+		}
+	}
+
+	if len(rules.Ports) > 0 {
+		if _, err := p.groups.Update(ctx, group, armresources.ResourceGroupPatchable{
+			Tags: map[string]*string{portsTag: to.Ptr(rules.Ports)},
+		}, nil); err != nil {
+			return nil, fmt.Errorf("unable to tag resource group %s: %w", group, err)
+		}
+	}
+
+	delete(p.pending, id)
+	return res, nil
+}
+
+// createRule creates an inbound rule for the TCP port ranges, open to the
+// CIDRs or to every address when none are given
+func (p *azureProvisioner) createRule(ctx context.Context, group, name string, priority int32, ports, cidrs []string) error {
+	props := &armnetwork.SecurityRulePropertiesFormat{
+		Priority:                 to.Ptr(priority),
+		Protocol:                 to.Ptr(armnetwork.SecurityRuleProtocolTCP),
+		Access:                   to.Ptr(armnetwork.SecurityRuleAccessAllow),
+		Direction:                to.Ptr(armnetwork.SecurityRuleDirectionInbound),
+		SourcePortRange:          to.Ptr("*"),
+		DestinationAddressPrefix: to.Ptr("*"),
+		DestinationPortRanges:    to.SliceOfPtrs(ports...),
+	}
+	if len(cidrs) > 0 {
+		props.SourceAddressPrefixes = to.SliceOfPtrs(cidrs...)
+	} else {
+		props.SourceAddressPrefix = to.Ptr("*")
+	}
+
+	created, err := p.rules.BeginCreateOrUpdate(ctx, group, azureSecurityGroup, name, armnetwork.SecurityRule{Properties: props}, nil)
+	if err == nil {
+		_, err = created.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create security rule %s in %s: %w", name, group, err)
+	}
+	return nil
+}
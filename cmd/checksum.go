@@ -0,0 +1,52 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// inletsProReleaseURL is where the inlets-pro binaries and their checksums
+// are published, followed by the version
+const inletsProReleaseURL = "https://github.com/inlets/inlets-pro/releases/download"
+
+// inletsProAsset gives the name of the inlets-pro release asset for a Linux
+// exit-server of the given architecture
+func inletsProAsset(arch string) string {
+	suffix, _ := buildFilename(arch, "linux")
+	return "inlets-pro" + suffix
+}
+
+// validateChecksum checks that a checksum given with --inlets-sha256 is a
+// hex encoded SHA256 digest, an empty value is valid and means the published
+// checksum is used
+func validateChecksum(checksum string) error {
+	if len(checksum) == 0 {
+		return nil
+	}
+
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != 32 {
+		return fmt.Errorf("--inlets-sha256 must be a SHA256 checksum of 64 hex characters")
+	}
+	return nil
+}
+
+// makeVerifyCommand gives a shell command which exits non-zero unless the
+// file at path has the given SHA256 checksum. When checksum is empty, the
+// checksum published alongside the release asset is downloaded and used
+// instead, so that a missing checksum file also fails the check.
+//
+// version is substituted into the release URL as-is, so it may be a shell
+// variable such as $VERSION.
+func makeVerifyCommand(path, version, asset, checksum string) string {
+	if len(checksum) > 0 {
+		checksum = strings.ToLower(checksum)
+	} else {
+		checksum = fmt.Sprintf("$(curl -SLsf %s/%s/%s.sha256 | cut -d ' ' -f 1)", inletsProReleaseURL, version, asset)
+	}
+
+	return fmt.Sprintf(`echo "%s  %s" | sha256sum --check --strict -`, checksum, path)
+}
@@ -0,0 +1,49 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_ValidateChecksum(t *testing.T) {
+	cases := []struct {
+		checksum string
+		wantErr  bool
+	}{
+		{"", false},
+		{"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", false},
+		{"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855", false},
+		{"e3b0c44298fc1c149afbf4c8996fb924", true},
+		{"not-a-checksum", true},
+	}
+
+	for _, c := range cases {
+		err := validateChecksum(c.checksum)
+		if c.wantErr && err == nil {
+			t.Errorf("%q: want error", c.checksum)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("%q: unexpected error: %s", c.checksum, err)
+		}
+	}
+}
+
+func Test_MakeVerifyCommand_Pinned(t *testing.T) {
+	got := makeVerifyCommand("/tmp/inlets-pro", "0.11.5", "inlets-pro", "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855")
+	want := `echo "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  /tmp/inlets-pro" | sha256sum --check --strict -`
+
+	if want != got {
+		t.Fatalf("want\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func Test_MakeVerifyCommand_Published(t *testing.T) {
+	got := makeVerifyCommand("/tmp/inlets-pro", "$VERSION", "inlets-pro-arm64", "")
+	want := `echo "$(curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro-arm64.sha256 | cut -d ' ' -f 1)  /tmp/inlets-pro" | sha256sum --check --strict -`
+
+	if want != got {
+		t.Fatalf("want\n%s\nbut got\n%s", want, got)
+	}
+}
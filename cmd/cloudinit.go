@@ -0,0 +1,142 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// userdataFormats are the values accepted by --userdata-format
+var userdataFormats = []string{"bash", "cloud-init"}
+
+// validateUserdataFormat checks that the provider passes user-data to
+// cloud-init when that format is chosen, see providerSpec.CloudInit
+func validateUserdataFormat(format, provider string) error {
+	if !contains(userdataFormats, format) {
+		return fmt.Errorf("--userdata-format must be one of: %s", strings.Join(userdataFormats, ", "))
+	}
+
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return err
+	}
+	if format == "cloud-init" && !spec.CloudInit {
+		return fmt.Errorf("--userdata-format cloud-init is not supported by the %s provider, use bash instead", provider)
+	}
+	return nil
+}
+
+// cloudConfig is the subset of the cloud-init #cloud-config format used to
+// bootstrap an exit-server
+type cloudConfig struct {
+	Packages   []string          `yaml:"packages,omitempty"`
+	WriteFiles []cloudConfigFile `yaml:"write_files,omitempty"`
+	RunCmd     []string          `yaml:"runcmd,omitempty"`
+}
+
+type cloudConfigFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Owner       string `yaml:"owner"`
+	Content     string `yaml:"content"`
+}
+
+// inletsProEnvFile holds the settings for the inlets-pro service. The public
+// IP is only known once the host has booted, so it is written to a file of
+// its own by runcmd.
+const (
+	inletsProEnvFile   = "/etc/default/inlets-pro"
+	inletsProIPEnvFile = "/etc/default/inlets-pro-ip"
+	inletsProUnitFile  = "/etc/systemd/system/inlets-pro.service"
+)
+
+const inletsProUnitTemplate = `[Unit]
+Description=inlets Pro %s Server
+After=network.target
+
+[Service]
+Type=simple
+Restart=always
+RestartSec=5
+StartLimitInterval=0
+EnvironmentFile=` + inletsProEnvFile + `
+EnvironmentFile=` + inletsProIPEnvFile + `
+ExecStart=/usr/local/bin/inlets-pro %s
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// makeCloudConfig makes a cloud-init #cloud-config document to setup inlets
+// with a systemd service and the given version and architecture. A HTTPS
+// tunnel server is configured when domains are given, otherwise a TCP tunnel
+// server.
+//
+// Unlike the bash scripts, the unit and environment files are written in
+// full rather than downloaded or appended to, so the result is the same if
+// the bootstrap runs more than once. inlets-pro is only installed when the
+// download matches checksum, or the published checksum when that is empty.
+// The public IP is printed by ipCommand, see makeIPCommand. sshCommands
+// run first, so that the host can be reached when inlets-pro fails to
+// install.
+func makeCloudConfig(authToken, version, arch, checksum, ipCommand, letsEncryptIssuer string, domains, sshCommands []string) (string, error) {
+	env := fmt.Sprintf("AUTHTOKEN=%s\n", authToken)
+
+	var unit string
+	if len(domains) > 0 {
+		domainFlags := []string{}
+		for _, domain := range domains {
+			domainFlags = append(domainFlags, fmt.Sprintf("--letsencrypt-domain=%s", domain))
+		}
+		env += fmt.Sprintf("DOMAINS=%s\n", strings.Join(domainFlags, " "))
+		env += fmt.Sprintf("ISSUER=--letsencrypt-issuer=%s\n", letsEncryptIssuer)
+
+		unit = fmt.Sprintf(inletsProUnitTemplate, "HTTP",
+			`http server --auto-tls --auto-tls-san="${IP}" --token="${AUTHTOKEN}" $DOMAINS $ISSUER`)
+	} else {
+		unit = fmt.Sprintf(inletsProUnitTemplate, "TCP",
+			`tcp server --auto-tls --auto-tls-san="${IP}" --token="${AUTHTOKEN}"`)
+	}
+
+	asset := inletsProAsset(arch)
+	downloadURL := fmt.Sprintf("%s/%s/%s", inletsProReleaseURL, version, asset)
+
+	config := cloudConfig{
+		Packages: []string{"curl", "ca-certificates"},
+		WriteFiles: []cloudConfigFile{
+			{
+				Path:        inletsProEnvFile,
+				Permissions: "0600",
+				Owner:       "root:root",
+				Content:     env,
+			},
+			{
+				Path:        inletsProUnitFile,
+				Permissions: "0644",
+				Owner:       "root:root",
+				Content:     unit,
+			},
+		},
+		RunCmd: []string{
+			fmt.Sprintf("curl -SLsf %s -o /tmp/inlets-pro", downloadURL),
+			// Each runcmd entry runs even when an earlier one fails, so the
+			// install is chained onto the check
+			makeVerifyCommand("/tmp/inlets-pro", version, asset, checksum) + " && install -m 0755 /tmp/inlets-pro /usr/local/bin/inlets-pro",
+			fmt.Sprintf(`echo "IP=$(%s)" > %s`, ipCommand, inletsProIPEnvFile),
+			"systemctl daemon-reload",
+			"systemctl enable --now inlets-pro",
+		},
+	}
+	config.RunCmd = append(append([]string{}, sshCommands...), config.RunCmd...)
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	return "#cloud-config\n" + string(out), nil
+}
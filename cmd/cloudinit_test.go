@@ -0,0 +1,129 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func Test_MakeCloudConfig_TCP(t *testing.T) {
+	got, err := makeCloudConfig("token", "0.11.5", "amd64", "", "curl -sfSL https://checkip.amazonaws.com", "prod", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `#cloud-config
+packages:
+- curl
+- ca-certificates
+write_files:
+- path: /etc/default/inlets-pro
+  permissions: "0600"
+  owner: root:root
+  content: |
+    AUTHTOKEN=token
+- path: /etc/systemd/system/inlets-pro.service
+  permissions: "0644"
+  owner: root:root
+  content: |
+    [Unit]
+    Description=inlets Pro TCP Server
+    After=network.target
+
+    [Service]
+    Type=simple
+    Restart=always
+    RestartSec=5
+    StartLimitInterval=0
+    EnvironmentFile=/etc/default/inlets-pro
+    EnvironmentFile=/etc/default/inlets-pro-ip
+    ExecStart=/usr/local/bin/inlets-pro tcp server --auto-tls --auto-tls-san="${IP}" --token="${AUTHTOKEN}"
+
+    [Install]
+    WantedBy=multi-user.target
+runcmd:
+- curl -SLsf https://github.com/inlets/inlets-pro/releases/download/0.11.5/inlets-pro
+  -o /tmp/inlets-pro
+- echo "$(curl -SLsf https://github.com/inlets/inlets-pro/releases/download/0.11.5/inlets-pro.sha256
+  | cut -d ' ' -f 1)  /tmp/inlets-pro" | sha256sum --check --strict - && install -m
+  0755 /tmp/inlets-pro /usr/local/bin/inlets-pro
+- echo "IP=$(curl -sfSL https://checkip.amazonaws.com)" > /etc/default/inlets-pro-ip
+- systemctl daemon-reload
+- systemctl enable --now inlets-pro
+`
+
+	if want != got {
+		t.Fatalf("want\n\n%s\n\nbut got\n\n%s\n\n", want, got)
+	}
+}
+
+func Test_MakeCloudConfig_HTTPSTwoDomains(t *testing.T) {
+	got, err := makeCloudConfig("token", "0.11.5", "amd64", "", "curl -sfSL https://checkip.amazonaws.com", "staging", []string{"a.example.com", "b.example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(got, "#cloud-config\n") {
+		t.Fatalf("want #cloud-config header, but got: %s", got)
+	}
+
+	config := cloudConfig{}
+	if err := yaml.Unmarshal([]byte(got), &config); err != nil {
+		t.Fatalf("output is not valid YAML: %s", err)
+	}
+
+	if len(config.WriteFiles) != 2 {
+		t.Fatalf("want 2 files, but got: %d", len(config.WriteFiles))
+	}
+
+	env := config.WriteFiles[0].Content
+	wantEnv := `AUTHTOKEN=token
+DOMAINS=--letsencrypt-domain=a.example.com --letsencrypt-domain=b.example.com
+ISSUER=--letsencrypt-issuer=staging
+`
+	if env != wantEnv {
+		t.Errorf("want env file\n%s\nbut got\n%s", wantEnv, env)
+	}
+
+	if unit := config.WriteFiles[1].Content; !strings.Contains(unit, "inlets-pro http server") {
+		t.Errorf("want a HTTP server unit, but got\n%s", unit)
+	}
+}
+
+func Test_MakeCloudConfig_SSHKeysFirst(t *testing.T) {
+	commands := makeSSHKeyCommands("root", []string{"ssh-ed25519 AAAA"})
+	got, err := makeCloudConfig("token", "0.11.5", "amd64", "", "curl -sfSL https://checkip.amazonaws.com", "prod", nil, commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := cloudConfig{}
+	if err := yaml.Unmarshal([]byte(got), &config); err != nil {
+		t.Fatalf("output is not valid YAML: %s", err)
+	}
+
+	if len(config.RunCmd) != len(commands)+5 {
+		t.Fatalf("want the key commands and the install, but got: %q", config.RunCmd)
+	}
+	for i, command := range commands {
+		if config.RunCmd[i] != command {
+			t.Errorf("want runcmd[%d] %q, but got %q", i, command, config.RunCmd[i])
+		}
+	}
+}
+
+func Test_ValidateUserdataFormat(t *testing.T) {
+	if err := validateUserdataFormat("cloud-init", "hetzner"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := validateUserdataFormat("cloud-init", "gce"); err == nil {
+		t.Errorf("want error for cloud-init on gce")
+	}
+	if err := validateUserdataFormat("powershell", "azure"); err == nil {
+		t.Errorf("want error for an unknown format")
+	}
+}
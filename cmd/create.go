@@ -4,14 +4,21 @@
 package cmd
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/inlets/cloud-provision/provision"
 
-	"github.com/inlets/inletsctl/pkg/env"
+	"github.com/inlets/inletsctl/pkg/dns"
+	"github.com/inlets/inletsctl/pkg/inventory"
 	"github.com/inlets/inletsctl/pkg/names"
 
 	"github.com/pkg/errors"
@@ -30,17 +37,21 @@ func init() {
 	createCmd.Flags().StringP("region", "r", "lon1", "The region for your cloud provider")
 	createCmd.Flags().StringP("plan", "s", "", "The plan or size for your cloud instance")
 	createCmd.Flags().StringP("zone", "z", "us-central1-a", "The zone for the exit-server (gce)")
+	createCmd.Flags().String("arch", "amd64", "The CPU architecture of the exit-server - amd64 or arm64, arm64 is available for ec2, gce, azure, hetzner and scaleway")
 
 	createCmd.Flags().StringP("inlets-token", "t", "", "The auth token for the inlets server on your new exit-server, leave blank to auto-generate")
 	createCmd.Flags().StringP("access-token", "a", "", "The access token for your cloud")
 	createCmd.Flags().StringP("access-token-file", "f", "", "Read this file for the access token for your cloud")
+	createCmd.Flags().String("access-token-cmd", "", "Run this command and read the access token for your cloud from its output, i.e. \"pass show inlets/do-token\"")
 
 	createCmd.Flags().String("vpc-id", "", "The VPC ID to create the exit-server in (ec2)")
 	createCmd.Flags().String("subnet-id", "", "The Subnet ID where the exit-server should be placed (ec2)")
 	createCmd.Flags().String("secret-key", "", "The secret key for your cloud (scaleway, ec2)")
 	createCmd.Flags().String("secret-key-file", "", "Read this file for the secret key for your cloud (scaleway, ec2)")
+	createCmd.Flags().String("secret-key-cmd", "", "Run this command and read the secret key for your cloud from its output (scaleway, ec2)")
 	createCmd.Flags().String("session-token", "", "The session token for ec2 (when using with temporary credentials)")
 	createCmd.Flags().String("session-token-file", "", "Read this file for the session token for ec2 (when using with temporary credentials)")
+	createCmd.Flags().String("session-token-cmd", "", "Run this command and read the session token for ec2 from its output (when using with temporary credentials)")
 
 	createCmd.Flags().String("organisation-id", "", "Organisation ID (scaleway)")
 	createCmd.Flags().String("project-id", "", "Project ID (gce, ovh)")
@@ -50,14 +61,44 @@ func init() {
 	createCmd.Flags().String("consumer-key", "", "The Consumer Key for using the OVH API")
 
 	createCmd.Flags().Bool("tcp", false, `Provision an exit-server with inlets running as a TCP server`)
+	createCmd.Flags().String("tcp-ports", "", `Comma separated TCP ports for a TCP tunnel, i.e. "22,443,5432", the firewall is narrowed to these and the control port instead of opening all ports (all but ovh), required for a TCP tunnel on digitalocean, hetzner, linode, vultr and scaleway`)
+	createCmd.Flags().String("control-allow-cidr", "", `Comma separated CIDRs or IP addresses which may connect to the control port of inlets-pro, i.e. "203.0.113.10", default: any address (all but ovh)`)
+	createCmd.Flags().String("data-allow-cidr", "", `Comma separated CIDRs or IP addresses which may connect to the tunnelled ports, default: any address (all but ovh)`)
+	createCmd.Flags().String("ssh-allow-cidr", "", `Comma separated CIDRs or IP addresses which may connect to SSH on port 22, which the cloud firewall otherwise blocks (all but ovh)`)
+	createCmd.Flags().String("upstream", "", `The upstream given in the client command, a host or IP for a TCP tunnel or a URL for a HTTPS tunnel, default: "127.0.0.1" or "http://127.0.0.1:8080"`)
+	createCmd.Flags().String("ssh-key-file", "", `Add the SSH public keys in this file, i.e. ~/.ssh/id_ed25519.pub, to the login user of the exit-server for maintenance`)
+	createCmd.Flags().String("ssh-key-name", "", `The name of a SSH key stored with the provider to add to the login user of the exit-server for maintenance (digitalocean, ec2, linode, hetzner, ovh, vultr)`)
 	createCmd.Flags().String("aws-key-name", "", "The name of an existing SSH key on AWS to be used to access the EC2 instance for maintenance (optional)")
+	createCmd.Flags().MarkDeprecated("aws-key-name", "use --ssh-key-name instead")
 
 	createCmd.Flags().StringArray("letsencrypt-domain", []string{}, `Domains you want to get a Let's Encrypt certificate for`)
 	createCmd.Flags().String("letsencrypt-issuer", "prod", `The issuer endpoint to use with Let's Encrypt - "prod" or "staging"`)
+	createCmd.Flags().String("dns-provider", "", `Point each --letsencrypt-domain at the exit-server once its IP is known, by creating or updating its A or AAAA record - "digitalocean", "route53", "cloudflare" or "hetzner"`)
+	createCmd.Flags().Duration("dns-timeout", time.Minute*5, "How long to wait for the records created by --dns-provider to reach the domain's name servers, 0 skips the wait")
+	addDNSCredentialFlags(createCmd.Flags())
 
 	createCmd.Flags().DurationP("poll", "n", time.Second*2, "poll every N seconds, use a higher value if you encounter rate-limiting")
 
 	createCmd.Flags().String("inlets-version", inletsProDefaultVersion, `Binary release version for inlets`)
+	createCmd.Flags().String("inlets-sha256", "", `SHA256 checksum the inlets binary must match, leave blank to use the checksum published with the release`)
+	createCmd.Flags().String("ip-source", defaultIPSource, `Comma separated sources tried in turn by the exit-server to find its public IP - "metadata" for the provider's metadata service, "interface" for the address of the default route, "checkip" for checkip.amazonaws.com or a http(s) URL which echoes the caller's IP`)
+	createCmd.Flags().String("public-ip", "", `The public IP of the exit-server when it is known ahead of time, skipping the detection given by --ip-source`)
+	createCmd.Flags().String("reserved-ip", "", `Attach this existing reserved IP to the exit-server, so that its address survives being recreated (digitalocean, hetzner, ec2, gce, linode)`)
+	createCmd.Flags().Bool("allocate-reserved-ip", false, `Allocate a new reserved IP for the exit-server, it is kept when the exit-server is deleted unless delete is given --release-reserved-ip`)
+	createCmd.Flags().String("userdata-format", "bash", `Format of the user-data to bootstrap the exit-server - "bash" or "cloud-init", cloud-init is not available for gce, linode or vultr`)
+
+	createCmd.Flags().Duration("timeout", time.Minute*10, "How long to wait for the exit-server to become active before giving up")
+	createCmd.Flags().Duration("ready-timeout", time.Minute*5, "How long to wait for inlets-pro to start serving on the exit-server once it is active, 0 skips the check")
+	createCmd.Flags().Duration("cert-timeout", time.Minute*5, "How long to wait for a valid certificate to be served for each --letsencrypt-domain once inlets-pro is serving, 0 skips the check")
+	createCmd.Flags().Bool("keep-on-failure", false, "Keep the exit-server when creating the tunnel fails or is interrupted, instead of deleting it, for debugging")
+
+	createCmd.Flags().StringP("output", "o", outputText, "Output format for the summary - text, json, yaml or env, progress is written to stderr for all but text")
+
+	createCmd.Flags().Bool("dry-run", false, "Validate the flags and print the host request and user-data without creating the exit-server")
+
+	createCmd.Flags().String("file", "", "Read a YAML or JSON spec file describing one or more tunnels, flags given on the command line override the file")
+	createCmd.Flags().String("client-artifacts", "", "Write a systemd unit, Kubernetes manifest, Docker Compose file and PowerShell script for the client into a directory named after the tunnel within this directory")
+	createCmd.Flags().String("profile", "", "Read the provider, credentials and region from this profile in $HOME/.inletsctl/config.yaml, flags given on the command line override the profile, default: $INLETSCTL_PROFILE")
 }
 
 // clientCmd represents the client sub command.
@@ -76,10 +117,14 @@ project docs.`,
     tunnel-richardcase \
     --letsencrypt-domain inlets.example.com
 
+  # Show the IP and auth token of the tunnel again later on
+  inletsctl show tunnel-richardcase
+
   # Create a TCP tunnel server with a VM name of ssh-tunnel
   inletsctl create \
     ssh-tunnel \
 	--tcp \
+    --tcp-ports 2222 \
     --provider [digitalocean|ec2|scaleway|gce|azure|linode|hetzner] \
     --access-token-file $HOME/access-token \
     --region lon1
@@ -89,42 +134,350 @@ project docs.`,
   inletsctl create  \
     --letsencrypt-domain tunnel1.example.com \
     --letsencrypt-domain tunnel2.example.com
+
+  # Create the tunnels described in a spec file, where each field is named
+  # after a flag i.e. "provider", "region" or "letsencrypt-domain". Give a
+  # single tunnel at the top level, or a list of them under "tunnels"
+  inletsctl create --file tunnel.yaml --access-token-file $HOME/access-token
+
+  # Print the summary as JSON for use in a script, progress goes to stderr
+  inletsctl create --tcp --tcp-ports 2222 --output json > tunnel.json
+
+  # Review the plan, OS image and user-data without creating anything
+  inletsctl create --provider ec2 --tcp --dry-run
+
+  # Bootstrap the exit-server with a cloud-init #cloud-config document
+  # instead of a bash script
+  inletsctl create --provider hetzner --tcp --tcp-ports 2222 \
+    --userdata-format cloud-init
+
+  # Find the public IP from the EC2 metadata service only, for a VPC
+  # without access to checkip.amazonaws.com
+  inletsctl create --provider ec2 --tcp --ip-source metadata
+
+  # Only open the ports for SSH, HTTPS and Postgres, and forward them to
+  # a host on the client's network
+  inletsctl create db-tunnel --provider ec2 --tcp \
+    --tcp-ports 22,443,5432 \
+    --upstream 192.168.0.10
+
+  # Only allow the client's office to connect to the control port, and a
+  # partner's network to the tunnelled port
+  inletsctl create db-tunnel --provider gce --tcp --tcp-ports 5432 \
+    --control-allow-cidr 203.0.113.10 \
+    --data-allow-cidr 198.51.100.0/24
+
+  # Create a firewall for the tunnel on Hetzner, which also lets the
+  # client's office reach the exit-server over SSH
+  inletsctl create --provider hetzner --tcp --tcp-ports 2222 \
+    --ssh-allow-cidr 203.0.113.10
+
+  # Add your SSH key to the exit-server for maintenance, the login user
+  # is shown in the summary
+  inletsctl create --provider digitalocean --tcp --tcp-ports 2222 \
+    --ssh-key-file ~/.ssh/id_ed25519.pub
+
+  # Keep the same address when the tunnel is recreated, by allocating a
+  # reserved IP the first time, then giving it to create from then on
+  inletsctl create ssh-tunnel --tcp --tcp-ports 2222 --allocate-reserved-ip
+  inletsctl delete ssh-tunnel
+  inletsctl create ssh-tunnel --tcp --tcp-ports 2222 --reserved-ip 203.0.113.10
+
+  # Point the domain at the exit-server with Cloudflare before Let's
+  # Encrypt is asked for a certificate, the record is removed by delete
+  CLOUDFLARE_API_TOKEN=$(cat ~/cf-token) inletsctl create \
+    --letsencrypt-domain inlets.example.com \
+    --dns-provider cloudflare
+
+  # Try a new domain with the staging issuer, which has higher rate
+  # limits, the summary reports whether a certificate was served
+  inletsctl create \
+    --letsencrypt-domain inlets.example.com \
+    --letsencrypt-issuer staging
+
+  # Create a TCP tunnel server on a cheaper arm64 (CAX) server
+  inletsctl create --provider hetzner --tcp --tcp-ports 2222 --arch arm64
+
+  # Use the credentials of the AWS CLI from $AWS_ACCESS_KEY_ID and
+  # $AWS_SECRET_ACCESS_KEY or ~/.aws/credentials, each provider's own
+  # sources are listed by: inletsctl providers NAME
+  AWS_PROFILE=staging inletsctl create --provider ec2 --tcp
+
+  # Read the access token from a password manager instead of a file
+  inletsctl create --provider hetzner --tcp --tcp-ports 2222 \
+    --access-token-cmd "op read op://Private/Hetzner/token"
+
+  # Write a systemd unit, Kubernetes manifest, Docker Compose file and
+  # PowerShell script for the client into ./clients/ssh-tunnel
+  inletsctl create ssh-tunnel --tcp --tcp-ports 2222 \
+    --client-artifacts ./clients
+
+  # Use the provider, credentials and region of the "staging" profile
+  # from $HOME/.inletsctl/config.yaml, i.e.
+  # profiles:
+  #   staging:
+  #     provider: ec2
+  #     region: eu-west-2
+  #     access-token-file: ~/.inlets/aws-access-key
+  #     secret-key-file: ~/.inlets/aws-secret-key
+  inletsctl create --profile staging --tcp
 `,
 	RunE:          runCreate,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
 
-func runCreate(cmd *cobra.Command, _ []string) error {
+func runCreate(cmd *cobra.Command, args []string) error {
+	specFile, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'file' value")
+	}
 
-	// Get name from the Args, if not provided, generate a random name
-	name := strings.Replace(names.GetRandomName(10), "_", "-", -1)
-	if len(cmd.Flags().Args()) > 0 {
-		name = cmd.Flags().Args()[0]
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'output' value")
+	}
+	if err := validateOutput(output); err != nil {
+		return err
 	}
+	progress := progressWriter(output)
 
-	inletsProVersion, err := cmd.Flags().GetString("inlets-version")
+	specs := []tunnelSpec{{}}
+	if len(specFile) > 0 {
+		if specs, err = loadTunnelSpecs(specFile, cmd.Flags()); err != nil {
+			return err
+		}
+
+		if len(specs) > 1 && len(args) > 0 {
+			return fmt.Errorf("a name cannot be given when %s describes more than one tunnel", specFile)
+		}
+		if len(specs) > 1 && output == "env" {
+			return fmt.Errorf("--output env can only be used with a single tunnel, %s describes %d", specFile, len(specs))
+		}
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'dry-run' value")
+	}
+	// The host request and user-data have no form as variables
+	if dryRun && output == "env" {
+		return fmt.Errorf("--output env cannot be used with --dry-run, use json or yaml instead")
+	}
+
+	inv, err := loadInventory()
 	if err != nil {
 		return err
 	}
 
+	// A host which has been provisioned is rolled back when the user
+	// interrupts the command, so the signal must not exit straight away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default behaviour, so a second signal exits without
+		// waiting for the rollback
+		stop()
+	}()
+
+	var summaries []tunnelSummary
+	var plans []dryRunResult
+	userSet := changedFlags(cmd.Flags())
+	for i, spec := range specs {
+		if len(specFile) > 0 {
+			if err := applyTunnelSpec(cmd.Flags(), spec, userSet); err != nil {
+				return fmt.Errorf("%s: %w", specFile, err)
+			}
+		}
+
+		if err := applyProfile(cmd.Flags(), progress); err != nil {
+			return err
+		}
+
+		name, _ := spec["name"].(string)
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		if len(specs) > 1 {
+			fmt.Fprintf(progress, "Creating tunnel %d/%d from %s\n", i+1, len(specs), specFile)
+		}
+
+		plan, err := planTunnel(cmd, name, inv, dryRun, progress)
+		if err != nil {
+			if len(specs) > 1 {
+				return fmt.Errorf("tunnels[%d]: %w", i, err)
+			}
+			return err
+		}
+
+		if dryRun {
+			result := makeDryRunResult(plan)
+			if output == outputText {
+				printDryRun(os.Stdout, result)
+			}
+			plans = append(plans, result)
+			continue
+		}
+
+		summary, err := provisionTunnel(ctx, plan, inv, progress)
+		if summary != nil {
+			if output == outputText {
+				printSummary(os.Stdout, *summary)
+			}
+			summaries = append(summaries, *summary)
+		}
+
+		if err != nil {
+			// Print the tunnels created so far, so that scripts can still
+			// find them when a later one fails
+			if len(summaries) > 0 && output != outputText {
+				printSummaries(output, summaries)
+			}
+
+			if len(specs) > 1 {
+				return fmt.Errorf("tunnels[%d]: %w", i, err)
+			}
+			return err
+		}
+	}
+
+	if output == outputText {
+		return nil
+	}
+
+	if dryRun {
+		if len(plans) == 1 {
+			return printDocument(os.Stdout, output, plans[0])
+		}
+		return printDocument(os.Stdout, output, plans)
+	}
+	return printSummaries(output, summaries)
+}
+
+// printSummaries writes a single document for one tunnel, or a list of
+// them when a spec file described more than one
+func printSummaries(output string, summaries []tunnelSummary) error {
+	if len(summaries) == 1 {
+		return printDocument(os.Stdout, output, summaries[0])
+	}
+	return printDocument(os.Stdout, output, summaries)
+}
+
+// tunnelPlan holds everything needed to create an exit-server, once the
+// flags for it have been validated
+type tunnelPlan struct {
+	Name             string
+	Provider         string
+	Region           string
+	Zone             string
+	ProjectID        string
+	Mode             string
+	Domains          []string
+	InletsProVersion string
+	Token            string
+	Poll             time.Duration
+	Timeout          time.Duration
+	ReadyTimeout     time.Duration
+	KeepOnFailure    bool
+
+	// ReadyMayBeBlocked is true when the allow CIDRs may stop the readiness
+	// probes from reaching the exit-server, so a failed check keeps it
+	ReadyMayBeBlocked bool
+
+	// TCPPorts are given by --tcp-ports, they are empty when all ports are
+	// opened
+	TCPPorts []int
+	Upstream string
+
+	// ReservedIP is attached to the host once it is active, when it was
+	// allocated by create it is released again if the tunnel fails
+	ReservedIP          string
+	ReservedIPAllocated bool
+
+	// DNSProvider creates the records for Domains once the IP is known,
+	// then waits up to DNSTimeout for them to propagate
+	DNSProvider string
+	DNSTimeout  time.Duration
+
+	// CertTimeout limits the wait for a certificate from LetsEncryptIssuer
+	// to be served for each of Domains
+	LetsEncryptIssuer string
+	CertTimeout       time.Duration
+
+	// ClientArtifacts is the directory given by --client-artifacts
+	ClientArtifacts string
+
+	// Firewall is created with FirewallRules once the host is active, it
+	// is empty when the provisioner manages the host's firewall
+	Firewall      string
+	FirewallRules []firewallRule
+
+	// SSHUser logs in with the keys given by --ssh-key-file and
+	// --ssh-key-name, it is empty when neither was given
+	SSHUser string
+
+	// UserData is the bootstrap script, before any encoding required by
+	// the provider is applied to Host.UserData
+	UserData string
+	Host     *provision.BasicHost
+
+	// provisioner, reservedIPs, dns, firewalls and portTags are nil for a
+	// dry-run
+	provisioner provision.Provisioner
+	reservedIPs reservedIPs
+	dns         dns.Provider
+	firewalls   cloudFirewalls
+	portTags    portTags
+}
+
+// planTunnel validates the create command's flags and prepares the host
+// request for a single exit-server. A random name is generated when name is
+// empty. For a dry-run, credentials are optional and no provisioner is
+// created.
+func planTunnel(cmd *cobra.Command, name string, inv *inventory.Inventory, dryRun bool, progress io.Writer) (*tunnelPlan, error) {
+	if len(name) == 0 {
+		name = strings.Replace(names.GetRandomName(10), "_", "-", -1)
+	}
+
+	if _, exists := inv.Get(name); exists {
+		return nil, fmt.Errorf("a tunnel named %q already exists in the inventory, see: inletsctl show %s", name, name)
+	}
+
+	inletsProVersion, err := cmd.Flags().GetString("inlets-version")
+	if err != nil {
+		return nil, err
+	}
+
 	if len(inletsProVersion) == 0 {
 		inletsProVersion = inletsProDefaultVersion
 	}
 
+	inletsProChecksum, err := cmd.Flags().GetString("inlets-sha256")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'inlets-sha256' value")
+	}
+	if err := validateChecksum(inletsProChecksum); err != nil {
+		return nil, err
+	}
+
 	tcp := false
 	if cmd.Flags().Changed("tcp") {
 		tcp, _ = cmd.Flags().GetBool("tcp")
 	}
 
-	awsKeyName, err := cmd.Flags().GetString("aws-key-name")
+	provider, err := cmd.Flags().GetString("provider")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	provider, err := cmd.Flags().GetString("provider")
+	arch, err := cmd.Flags().GetString("arch")
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "failed to get 'arch' value")
+	}
+	if err := validateArch(arch, provider); err != nil {
+		return nil, err
 	}
 
 	serverMode := "L4 TCP"
@@ -132,13 +485,13 @@ func runCreate(cmd *cobra.Command, _ []string) error {
 		serverMode = "L7 HTTPS"
 	}
 
-	fmt.Printf("inletsctl version: %v\nTunnel server: %s\tProvider: %s\tinlets-pro version: %s\n",
+	fmt.Fprintf(progress, "inletsctl version: %v\nTunnel server: %s\tProvider: %s\tinlets-pro version: %s\n",
 		getVersion(),
 		serverMode, provider, inletsProVersion)
 
 	inletsToken, err := cmd.Flags().GetString("inlets-token")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(inletsToken) == 0 {
@@ -146,299 +499,609 @@ func runCreate(cmd *cobra.Command, _ []string) error {
 		inletsToken, passwordErr = generateAuth()
 
 		if passwordErr != nil {
-			return passwordErr
+			return nil, passwordErr
 		}
 	}
 
-	var poll time.Duration
-	pollOverride, pollOverrideErr := cmd.Flags().GetDuration("poll")
-	if pollOverrideErr == nil {
-		poll = pollOverride
+	poll, err := cmd.Flags().GetDuration("poll")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'poll' value")
+	}
+	// The backoff after a rate-limit doubles the poll interval, so zero
+	// would retry the cloud API in a tight loop
+	if poll <= 0 {
+		return nil, fmt.Errorf("--poll must be greater than zero")
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'timeout' value")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("--timeout must be greater than zero")
 	}
 
-	accessToken, err := env.GetRequiredFileOrString(cmd.Flags(),
-		"access-token-file",
-		"access-token",
-		"INLETS_ACCESS_TOKEN",
-	)
+	readyTimeout, err := cmd.Flags().GetDuration("ready-timeout")
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "failed to get 'ready-timeout' value")
 	}
 
-	var region string
-	if cmd.Flags().Changed("region") {
-		if regionVal, err := cmd.Flags().GetString("region"); len(regionVal) > 0 {
-			if err != nil {
-				return errors.Wrap(err, "failed to get 'region' value.")
-			}
-			region = regionVal
-		}
-
-	} else if provider == "digitalocean" {
-		region = "lon1"
-	} else if provider == "scaleway" {
-		region = "fr-par-1"
-	} else if provider == "ec2" {
-		region = "eu-west-1"
-	} else if provider == "hetzner" {
-		region = "hel1"
-	} else if provider == "vultr" {
-		region = "LHR" // London
-	} else if provider == "linode" {
-		region = "eu-west"
-	} else if provider == "ovh" {
-		region = "DE1"
-	} else if provider == "gce" {
-		return fmt.Errorf("--region is required for the GCE provider")
-	}
-
-	var zone string
-	if provider == "gce" {
-		zone, err = cmd.Flags().GetString("zone")
+	keepOnFailure, err := cmd.Flags().GetBool("keep-on-failure")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'keep-on-failure' value")
+	}
+
+	clientArtifactsDir, err := cmd.Flags().GetString("client-artifacts")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'client-artifacts' value")
+	}
+
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	region := providerRegion(cmd.Flags(), spec)
+	if len(region) == 0 {
+		return nil, fmt.Errorf("--region is required for the %s provider", provider)
+	}
+	zone := providerZone(cmd.Flags(), spec)
+
+	creds, err := readCredentials(cmd.Flags(), spec, region, !dryRun)
+	if err != nil {
+		return nil, err
+	}
+	creds.report(progress)
+	if err := creds.check(provider, append(spec.RequiredFlags, spec.CreateFlags...)); err != nil {
+		return nil, err
+	}
+
+	vpcID, err := cmd.Flags().GetString("vpc-id")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'vpc-id' value")
+	}
+
+	subnetID, err := cmd.Flags().GetString("subnet-id")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'subnet-id' value")
+	}
+
+	if (len(vpcID) == 0 && len(subnetID) > 0) || (len(subnetID) == 0 && len(vpcID) > 0) {
+		return nil, fmt.Errorf("both --vpc-id and --subnet-id must be set")
+	}
+
+	var provisioner provision.Provisioner
+	if !dryRun {
+		provisioner, err = getProvisioner(provider, creds)
 		if err != nil {
-			return errors.Wrap(err, "failed to get 'zone' value")
+			return nil, err
 		}
 	}
 
-	var secretKey string
-	var sessionToken string
-	var organisationID string
-	var projectID string
-	var vpcID string
-	var subnetID string
-	if provider == "scaleway" || provider == "ec2" || provider == "ovh" {
+	letsencryptDomains, _ := cmd.Flags().GetStringArray("letsencrypt-domain")
+	letsencryptIssuer, _ := cmd.Flags().GetString("letsencrypt-issuer")
 
-		var secretKeyErr error
-		secretKey, secretKeyErr = getFileOrString(cmd.Flags(), "secret-key-file", "secret-key", true)
-		if secretKeyErr != nil {
-			return secretKeyErr
-		}
+	if len(letsencryptDomains) == 0 && !tcp {
+		return nil, fmt.Errorf("either --letsencrypt-domain (for a HTTPS tunnel) or --tcp (for a TCP tunnel) must be set")
+	}
 
-		if provider == "ovh" {
-			projectID, _ = cmd.Flags().GetString("project-id")
-			if len(projectID) == 0 {
-				return fmt.Errorf("--project-id flag must be set")
-			}
+	if len(letsencryptDomains) > 0 {
+		if len(letsencryptIssuer) == 0 {
+			return nil, fmt.Errorf("--letsencrypt-issuer is required when --letsencrypt-domain is given")
 		}
-
-		if provider == "scaleway" {
-			organisationID, _ = cmd.Flags().GetString("organisation-id")
-			if len(organisationID) == 0 {
-				return fmt.Errorf("--organisation-id flag must be set")
-			}
+		tcp = false
+	}
+	for _, domain := range letsencryptDomains {
+		if err := validateDomain(domain); err != nil {
+			return nil, err
 		}
+	}
 
-		if provider == "ec2" {
-			vpcID, err = cmd.Flags().GetString("vpc-id")
-			if err != nil {
-				return errors.Wrap(err, "failed to get 'vpc-id' value")
-			}
+	certTimeout, err := cmd.Flags().GetDuration("cert-timeout")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'cert-timeout' value")
+	}
 
-			subnetID, err = cmd.Flags().GetString("subnet-id")
-			if err != nil {
-				return errors.Wrap(err, "failed to get 'subnet-id' value")
-			}
+	dnsProvider, err := cmd.Flags().GetString("dns-provider")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'dns-provider' value")
+	}
+	if err := validateDNSProvider(dnsProvider, letsencryptDomains); err != nil {
+		return nil, err
+	}
 
-			if (len(vpcID) == 0 && len(subnetID) > 0) || (len(subnetID) == 0 && len(vpcID) > 0) {
-				return fmt.Errorf("both --vpc-id and --subnet-id must be set")
-			}
-			var sessionTokenErr error
-			sessionToken, sessionTokenErr = getFileOrString(cmd.Flags(), "session-token-file", "session-token", false)
-			if sessionTokenErr != nil {
-				return sessionTokenErr
+	dnsTimeout, err := cmd.Flags().GetDuration("dns-timeout")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'dns-timeout' value")
+	}
+
+	var dnsClient dns.Provider
+	if len(dnsProvider) > 0 {
+		dnsCreds, err := readDNSCredentials(cmd.Flags(), dnsProvider, provider, creds, !dryRun, progress)
+		if err != nil {
+			return nil, err
+		}
+		if !dryRun {
+			if dnsClient, err = dns.New(dnsProvider, dnsCreds); err != nil {
+				return nil, err
 			}
 		}
+	}
 
-	} else if provider == "gce" {
-		projectID, _ = cmd.Flags().GetString("project-id")
-		if len(projectID) == 0 {
-			return fmt.Errorf("--project-id flag must be set")
-		}
+	tcpPortsValue, err := cmd.Flags().GetString("tcp-ports")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'tcp-ports' value")
+	}
+	tcpPorts, err := parseTCPPorts(tcpPortsValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(tcpPorts) > 0 && !tcp {
+		return nil, fmt.Errorf("--tcp-ports can only be used with --tcp")
+	}
+	if len(tcpPorts) > 0 && !spec.TCPPortsFirewall {
+		fmt.Fprintf(progress, "Note: inletsctl does not manage a firewall for %s, so all ports remain reachable and --tcp-ports only sets the client's ports\n", provider)
+	}
+	// The firewall made for the tunnel only opens the control port and the
+	// declared ports, rather than every port to every address
+	if tcp && len(tcpPorts) == 0 && spec.newFirewalls != nil {
+		return nil, fmt.Errorf("--tcp-ports must be given for a TCP tunnel on %s, as its firewall only opens the declared ports, i.e. --tcp-ports 2222", provider)
+	}
+
+	controlAllowCIDRValue, err := cmd.Flags().GetString("control-allow-cidr")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'control-allow-cidr' value")
+	}
+	controlAllowCIDRs, err := parseAllowCIDRs("control-allow-cidr", controlAllowCIDRValue)
+	if err != nil {
+		return nil, err
+	}
+	dataAllowCIDRValue, err := cmd.Flags().GetString("data-allow-cidr")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'data-allow-cidr' value")
+	}
+	dataAllowCIDRs, err := parseAllowCIDRs("data-allow-cidr", dataAllowCIDRValue)
+	if err != nil {
+		return nil, err
+	}
+	sshAllowCIDRValue, err := cmd.Flags().GetString("ssh-allow-cidr")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'ssh-allow-cidr' value")
+	}
+	sshAllowCIDRs, err := parseAllowCIDRs("ssh-allow-cidr", sshAllowCIDRValue)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAllowCIDRs(controlAllowCIDRs, dataAllowCIDRs, sshAllowCIDRs, tcp, spec, progress); err != nil {
+		return nil, err
 	}
 
-	var subscriptionID string
-	if provider == "azure" {
-		subscriptionID, _ = cmd.Flags().GetString("subscription-id")
+	upstream, err := cmd.Flags().GetString("upstream")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'upstream' value")
+	}
+	if err := validateUpstream(upstream, tcp); err != nil {
+		return nil, err
 	}
 
-	var endpoint string
-	var consumerKey string
-	if provider == "ovh" {
-		endpoint, err = cmd.Flags().GetString("endpoint")
-		if err != nil {
-			return errors.Wrap(err, "failed to get 'endpoint' value")
+	sshKeyFile, err := cmd.Flags().GetString("ssh-key-file")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'ssh-key-file' value")
+	}
+	sshKeyName, err := cmd.Flags().GetString("ssh-key-name")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'ssh-key-name' value")
+	}
+	awsKeyName, err := cmd.Flags().GetString("aws-key-name")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'aws-key-name' value")
+	}
+	if len(awsKeyName) > 0 {
+		if len(sshKeyName) > 0 && sshKeyName != awsKeyName {
+			return nil, fmt.Errorf("give either --ssh-key-name or --aws-key-name, not both")
 		}
-		consumerKey, err = cmd.Flags().GetString("consumer-key")
-		if err != nil {
-			return errors.Wrap(err, "failed to get 'endpoint' value")
+		sshKeyName = awsKeyName
+	}
+	if err := validateSSHKey(sshKeyName, spec); err != nil {
+		return nil, err
+	}
+
+	// The keys are found before anything is allocated, so that a missing
+	// key needs no rollback
+	var sshPublicKeys []string
+	if len(sshKeyFile) > 0 {
+		if sshPublicKeys, err = readSSHKeyFile(sshKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(sshKeyName) > 0 && spec.newSSHKeys != nil {
+		if dryRun {
+			fmt.Fprintf(progress, "The SSH key %s would be looked up in the %s account and added by the user-data\n", sshKeyName, provider)
+		} else {
+			keys, err := lookupSSHKey(spec, creds, sshKeyName)
+			if err != nil {
+				return nil, err
+			}
+			sshPublicKeys = append(sshPublicKeys, keys...)
 		}
 	}
+	var sshUser string
+	if len(sshKeyFile) > 0 || len(sshKeyName) > 0 {
+		sshUser = spec.SSHUser
+	}
+	sshCommands := makeSSHKeyCommands(spec.SSHUser, sshPublicKeys)
+	if len(sshUser) > 0 && spec.newFirewalls != nil && len(sshAllowCIDRs) == 0 {
+		fmt.Fprintln(progress, "Note: the firewall for the tunnel blocks SSH, give --ssh-allow-cidr to log in with the SSH key")
+	}
 
-	provisioner, err := getProvisioner(provider, accessToken, secretKey, organisationID, region, subscriptionID, sessionToken, endpoint, consumerKey, projectID)
+	userdataFormat, err := cmd.Flags().GetString("userdata-format")
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "failed to get 'userdata-format' value")
+	}
+	if err := validateUserdataFormat(userdataFormat, provider); err != nil {
+		return nil, err
 	}
 
-	letsencryptDomains, _ := cmd.Flags().GetStringArray("letsencrypt-domain")
-	letsencryptIssuer, _ := cmd.Flags().GetString("letsencrypt-issuer")
+	ipSource, err := cmd.Flags().GetString("ip-source")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'ip-source' value")
+	}
+	ipSources, err := parseIPSource(ipSource)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(letsencryptDomains) == 0 && !tcp {
-		return fmt.Errorf("either --letsencrypt-domain (for a HTTPS tunnel) or --tcp (for a TCP tunnel) must be set")
+	publicIP, err := cmd.Flags().GetString("public-ip")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'public-ip' value")
+	}
+	if err := validatePublicIP(publicIP); err != nil {
+		return nil, err
 	}
 
-	if len(letsencryptDomains) > 0 {
-		if len(letsencryptIssuer) == 0 {
-			return fmt.Errorf("--letsencrypt-issuer is required when --letsencrypt-domain is given")
+	reservedIP, err := cmd.Flags().GetString("reserved-ip")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'reserved-ip' value")
+	}
+	allocateReservedIP, err := cmd.Flags().GetBool("allocate-reserved-ip")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get 'allocate-reserved-ip' value")
+	}
+	if err := validateReservedIP(reservedIP, allocateReservedIP, publicIP, spec); err != nil {
+		return nil, err
+	}
+
+	// A domain which points elsewhere will fail the ACME challenge, which
+	// counts against the rate limits of Let's Encrypt
+	expectedIP := publicIP
+	if len(reservedIP) > 0 {
+		expectedIP = reservedIP
+	}
+	preflightDomains(context.Background(), net.DefaultResolver.LookupHost, letsencryptDomains, expectedIP, dnsProvider, letsencryptIssuer, progress)
+
+	var rips reservedIPs
+	if !dryRun && (len(reservedIP) > 0 || allocateReservedIP) {
+		if rips, err = getReservedIPs(provider, creds); err != nil {
+			return nil, err
 		}
-		tcp = false
 	}
 
+	// The address is allocated up front, so that the exit-server is given
+	// it as its public IP. It is released again if the plan cannot be made.
+	release := func(err error) error { return err }
+	if allocateReservedIP {
+		if dryRun {
+			fmt.Fprintf(progress, "A reserved IP would be allocated in %s, the exit-server would use it as its public IP\n", valueOrDash(region))
+		} else {
+			if reservedIP, err = rips.Allocate(name, region, zone); err != nil {
+				return nil, fmt.Errorf("unable to allocate a reserved IP: %w", err)
+			}
+			fmt.Fprintf(progress, "Allocated reserved IP: %s\n", reservedIP)
+
+			release = func(err error) error {
+				releaseOrWarn(rips, reservedIP, provider, region, zone, poll, progress)
+				return err
+			}
+		}
+	}
+	if len(reservedIP) > 0 {
+		publicIP = reservedIP
+	}
+	ipCommand := makeIPCommand(provider, publicIP, ipSources)
+
 	var userData string
-	if len(letsencryptDomains) > 0 {
+	if userdataFormat == "cloud-init" {
+		userData, err = makeCloudConfig(inletsToken,
+			inletsProVersion,
+			arch,
+			inletsProChecksum,
+			ipCommand,
+			letsencryptIssuer, letsencryptDomains, sshCommands)
+		if err != nil {
+			return nil, release(err)
+		}
+	} else if len(letsencryptDomains) > 0 {
 		userData = makeHTTPSUserdata(inletsToken,
 			inletsProVersion,
+			arch,
+			inletsProChecksum,
+			ipCommand,
 			letsencryptIssuer, letsencryptDomains)
 	} else {
 		userData = makeExitServerUserdata(
 			inletsToken,
-			inletsProVersion)
-	}
-
-	hostReq, err := createHost(provider,
-		name,
-		region,
-		zone,
-		projectID,
-		userData,
-		fmt.Sprintf("%d", inletsProControlPort),
-		vpcID,
-		subnetID,
-		awsKeyName,
-		tcp,
-		letsencryptDomains,
-	)
+			inletsProVersion,
+			arch,
+			inletsProChecksum,
+			ipCommand)
+	}
+	if userdataFormat == "bash" {
+		userData = addSSHKeyCommands(userData, sshCommands)
+	}
+
+	hostReq, err := createHost(provider, hostOptions{
+		Name:        name,
+		Region:      region,
+		Zone:        zone,
+		ProjectID:   creds.ProjectID,
+		Arch:        arch,
+		UserData:    userData,
+		ControlPort: fmt.Sprintf("%d", inletsProControlPort),
+		VPCID:       vpcID,
+		SubnetID:    subnetID,
+		TCP:         tcp,
+		Domains:     letsencryptDomains,
+		TCPPorts:    tcpPorts,
+		ReservedIP:  reservedIP,
+		SSHKeyName:  sshKeyName,
+
+		ControlAllowCIDRs: controlAllowCIDRs,
+		DataAllowCIDRs:    dataAllowCIDRs,
+		SSHAllowCIDRs:     sshAllowCIDRs,
+	})
 	if err != nil {
-		return err
+		return nil, release(err)
 	}
 
 	// override default plan/size when provided
 	if cmd.Flags().Changed("plan") {
 		planOverride, err := cmd.Flags().GetString("plan")
 		if err != nil {
-			return errors.Wrap(err, "failed to get 'plan' value")
+			return nil, release(errors.Wrap(err, "failed to get 'plan' value"))
 		}
 		hostReq.Plan = planOverride
 	}
 
+	var firewall string
+	var rules []firewallRule
+	var firewalls cloudFirewalls
+	if spec.newFirewalls != nil {
+		firewall = hostReq.Additional["firewall-name"]
+		rules = firewallRules(*hostReq)
+		if !dryRun {
+			if firewalls, err = getFirewalls(provider, creds); err != nil {
+				return nil, release(err)
+			}
+		}
+	}
+
+	var tags portTags
+	if spec.newPortTags != nil && len(tcpPorts) > 0 && !dryRun {
+		if tags, err = spec.newPortTags(creds); err != nil {
+			return nil, release(err)
+		}
+	}
+
+	mode := "tcp"
+	if !tcp {
+		mode = "https"
+	}
+	if len(upstream) == 0 {
+		upstream = defaultUpstream(mode)
+	}
+
+	return &tunnelPlan{
+		Name:                name,
+		Provider:            provider,
+		Region:              region,
+		Zone:                zone,
+		ProjectID:           creds.ProjectID,
+		Mode:                mode,
+		Domains:             letsencryptDomains,
+		InletsProVersion:    inletsProVersion,
+		Token:               inletsToken,
+		Poll:                poll,
+		Timeout:             timeout,
+		ReadyTimeout:        readyTimeout,
+		KeepOnFailure:       keepOnFailure,
+		ReadyMayBeBlocked:   readinessMayBeBlocked(controlAllowCIDRs, dataAllowCIDRs, tcp),
+		TCPPorts:            tcpPorts,
+		Upstream:            upstream,
+		ReservedIP:          reservedIP,
+		ReservedIPAllocated: allocateReservedIP && !dryRun,
+		DNSProvider:         dnsProvider,
+		DNSTimeout:          dnsTimeout,
+		LetsEncryptIssuer:   letsencryptIssuer,
+		CertTimeout:         certTimeout,
+		ClientArtifacts:     clientArtifactsDir,
+		Firewall:            firewall,
+		FirewallRules:       rules,
+		SSHUser:             sshUser,
+		UserData:            userData,
+		Host:                hostReq,
+		provisioner:         provisioner,
+		reservedIPs:         rips,
+		dns:                 dnsClient,
+		firewalls:           firewalls,
+		portTags:            tags,
+	}, nil
+}
+
+// provisionTunnel creates the exit-server described by the plan, records
+// it in the inventory and waits for it to become active, then for inlets-pro
+// to start serving. If any step after provisioning fails, or ctx is
+// cancelled, the host is rolled back.
+func provisionTunnel(ctx context.Context, plan *tunnelPlan, inv *inventory.Inventory, progress io.Writer) (*tunnelSummary, error) {
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("interrupted before creating %s", plan.Name)
+	}
+
+	name, provider, region, zone := plan.Name, plan.Provider, plan.Region, plan.Zone
+	provisioner := plan.provisioner
+
 	if provider == "gce" {
-		fmt.Printf("Provisioning exit-server: %s in %s [%s]\n", name, zone, provider)
+		fmt.Fprintf(progress, "Provisioning exit-server: %s in %s [%s]\n", name, zone, provider)
 	} else {
-		fmt.Printf("Provisioning exit-server: %s in %s [%s]\n", name, region, provider)
+		fmt.Fprintf(progress, "Provisioning exit-server: %s in %s [%s]\n", name, region, provider)
 	}
 
-	hostRes, err := provisioner.Provision(*hostReq)
+	started := time.Now()
+	hostRes, err := provisioner.Provision(*plan.Host)
 	if err != nil {
-		return err
+		releaseAllocatedIP(plan, progress)
+		return nil, err
 	}
 
-	fmt.Printf("Host: %s, status: %s\n", hostRes.ID, hostRes.Status)
+	fmt.Fprintf(progress, "Host: %s, status: %s\n", hostRes.ID, hostRes.Status)
 
-	max := 500
-	for i := 0; i < max; i++ {
-		time.Sleep(poll)
+	// The tag only records the ports, so the host is kept when it cannot
+	// be added
+	if plan.portTags != nil {
+		if err := plan.portTags.Tag(hostRes.ID, plan.TCPPorts); err != nil {
+			fmt.Fprintf(progress, "Warning: unable to tag host %s with its ports: %s\n", hostRes.ID, err)
+		}
+	}
 
-		hostStatus, err := provisioner.Status(hostRes.ID)
-		if err != nil {
-			return err
+	tunnel := inventory.Tunnel{
+		Name:             name,
+		Provider:         provider,
+		Region:           region,
+		Zone:             zone,
+		ProjectID:        plan.ProjectID,
+		HostID:           hostRes.ID,
+		IP:               hostRes.IP,
+		Mode:             plan.Mode,
+		Domains:          plan.Domains,
+		DNSProvider:      plan.DNSProvider,
+		Ports:            plan.TCPPorts,
+		Upstream:         plan.Upstream,
+		ReservedIP:       plan.ReservedIP,
+		Firewall:         plan.Firewall,
+		SSHUser:          plan.SSHUser,
+		InletsProVersion: plan.InletsProVersion,
+		Token:            plan.Token,
+		CreatedAt:        time.Now().UTC(),
+	}
+	saveTunnel(inv, tunnel)
+
+	// The user may have interrupted while the provisioning request was
+	// in-flight
+	if ctx.Err() != nil {
+		return nil, rollbackHost(plan, hostRes.ID, hostRes.IP, inv, progress,
+			fmt.Errorf("interrupted while creating host %s", hostRes.ID))
+	}
+
+	hostStatus, err := waitForActive(ctx, provisioner, hostRes.ID, plan.Timeout, plan.Poll, progress)
+	if err != nil {
+		return nil, rollbackHost(plan, hostRes.ID, hostRes.IP, inv, progress, err)
+	}
+
+	if plan.firewalls != nil {
+		fmt.Fprintf(progress, "Creating firewall %s for host %s\n", plan.Firewall, hostStatus.ID)
+		if err := plan.firewalls.Create(plan.Firewall, hostStatus, plan.FirewallRules); err != nil {
+			return nil, rollbackHost(plan, hostStatus.ID, hostStatus.IP, inv, progress,
+				fmt.Errorf("unable to create firewall %s for host %s: %w", plan.Firewall, hostStatus.ID, err))
 		}
+	}
 
-		fmt.Printf("[%d/%d] Host: %s, status: %s\n",
-			i+1, max, hostStatus.ID, hostStatus.Status)
+	if len(plan.ReservedIP) > 0 {
+		fmt.Fprintf(progress, "Attaching reserved IP %s to host %s\n", plan.ReservedIP, hostStatus.ID)
+		if err := plan.reservedIPs.Attach(plan.ReservedIP, hostStatus); err != nil {
+			return nil, rollbackHost(plan, hostStatus.ID, hostStatus.IP, inv, progress,
+				fmt.Errorf("unable to attach reserved IP %s to host %s: %w", plan.ReservedIP, hostStatus.ID, err))
+		}
+		hostStatus.IP = plan.ReservedIP
+	}
 
-		if hostStatus.Status == "active" {
-			if len(letsencryptDomains) > 0 {
-				fmt.Printf(`inlets HTTPS (%s) server summary:
-  IP: %s
-  HTTPS Domains: %v
-  Auth-token: %s
+	tunnel.IP = hostStatus.IP
+	saveTunnel(inv, tunnel)
 
-Command:
+	// The records are created before the readiness check, as inlets-pro
+	// cannot get its certificate until the domains point at it
+	if plan.dns != nil {
+		if err := createDNSRecords(ctx, plan.dns, plan.DNSProvider, plan.Domains, hostStatus.IP, plan.DNSTimeout, plan.Poll, progress); err != nil {
+			return nil, rollbackHost(plan, hostStatus.ID, hostStatus.IP, inv, progress, err)
+		}
+	}
 
-inlets-pro http client --url "wss://%s:%d" \
-  --token "%s" \
-  --upstream http://127.0.0.1:8080
+	summary := &tunnelSummary{
+		Name:             name,
+		HostID:           hostStatus.ID,
+		IP:               hostStatus.IP,
+		Provider:         provider,
+		Region:           region,
+		Zone:             zone,
+		Mode:             plan.Mode,
+		Domains:          plan.Domains,
+		DNSProvider:      plan.DNSProvider,
+		ControlPort:      inletsProControlPort,
+		Ports:            plan.TCPPorts,
+		Upstream:         plan.Upstream,
+		ReservedIP:       plan.ReservedIP,
+		SSHUser:          plan.SSHUser,
+		Token:            plan.Token,
+		InletsProVersion: plan.InletsProVersion,
+		ClientCommand:    makeClientCommand(plan.Mode, hostStatus.IP, inletsProControlPort, plan.Token, plan.Upstream, plan.TCPPorts),
+	}
 
-To delete:
-  inletsctl delete --provider %s --id "%s"
-`,
-					inletsProVersion,
-					hostStatus.IP,
-					letsencryptDomains,
-					inletsToken,
-					hostStatus.IP,
-					inletsProControlPort,
-					inletsToken,
-					provider,
-					hostStatus.ID)
-
-				return nil
-			} else {
-				fmt.Printf(`inlets TCP (%s) server summary:
-  IP: %s
-  Auth-token: %s
-
-Command:
-
-inlets-pro tcp client --url "wss://%s:%d" \
-  --token "%s" \
-  --upstream 127.0.0.1 \
-  --ports 2222
-
-To delete:
-  inletsctl delete --provider %s --id "%s"
-`,
-					inletsProVersion,
-					hostStatus.IP,
-					inletsToken,
-					hostStatus.IP,
-					inletsProControlPort,
-					inletsToken,
-					provider,
-					hostStatus.ID)
-
-				return nil
+	if plan.ReadyTimeout > 0 {
+		fmt.Fprintf(progress, "Waiting up to %s for inlets-pro to start serving on %s\n", plan.ReadyTimeout, hostStatus.IP)
+
+		probes := makeReadinessProbes(plan.Mode, hostStatus.IP, inletsProControlPort)
+		if err := waitForReady(ctx, probes, plan.ReadyTimeout, plan.Poll, progress); err != nil {
+			// The host may be serving, but not to this machine
+			if plan.ReadyMayBeBlocked && ctx.Err() == nil {
+				fmt.Fprintf(progress, "Keeping host %s, as --control-allow-cidr or --data-allow-cidr may block the readiness check from this machine\n", hostStatus.ID)
+				return summary, fmt.Errorf("host %s is active, but inlets-pro could not be reached from this machine, which the allow CIDRs may block: %w (host %s was kept, delete it with: inletsctl delete %s)",
+					hostStatus.ID, err, hostStatus.ID, plan.Name)
+			}
+
+			err = rollbackHost(plan, hostStatus.ID, hostStatus.IP, inv, progress,
+				fmt.Errorf("host %s is active, but inlets-pro is not serving: %w", hostStatus.ID, err))
+			if plan.KeepOnFailure {
+				return summary, err
 			}
+			return nil, err
+		}
+		summary.TimeToReady = time.Since(started).Round(time.Second).String()
+
+		controlAddr := net.JoinHostPort(hostStatus.IP, strconv.Itoa(inletsProControlPort))
+		if err := checkCertificateIP(ctx, controlAddr, hostStatus.IP); err != nil {
+			fmt.Fprintf(progress, "Warning: the exit-server did not detect %s as its public IP, so clients may not trust it: %s\n"+
+				"Try another --ip-source, or give the address with --public-ip\n", hostStatus.IP, err)
 		}
 	}
 
-	return err
-}
+	// A domain without a certificate is reported rather than rolled back,
+	// as its DNS record may still be being changed
+	if plan.Mode == "https" && plan.CertTimeout > 0 {
+		fmt.Fprintf(progress, "Waiting up to %s for a certificate to be served for: %s\n", plan.CertTimeout, strings.Join(plan.Domains, ", "))
+		checker := newDomainChecker(plan.LetsEncryptIssuer)
+		summary.Certificates = checker.check(ctx, plan.Domains, hostStatus.IP, plan.CertTimeout, plan.Poll, progress)
+	}
 
-func getProvisioner(provider, accessToken, secretKey, organisationID, region, subscriptionID, sessionToken, endpoint, consumerKey, projectID string) (provision.Provisioner, error) {
-
-	switch provider {
-	case "digitalocean":
-		return provision.NewDigitalOceanProvisioner(accessToken)
-	case "scaleway":
-		return provision.NewScalewayProvisioner(accessToken, secretKey, organisationID, region)
-	case "gce":
-		return provision.NewGCEProvisioner(accessToken)
-	case "ec2":
-		return provision.NewEC2Provisioner(region, accessToken, secretKey, sessionToken)
-	case "azure":
-		return provision.NewAzureProvisioner(subscriptionID, accessToken)
-	case "linode":
-		return provision.NewLinodeProvisioner(accessToken)
-	case "hetzner":
-		return provision.NewHetznerProvisioner(accessToken)
-	case "vultr":
-		return provision.NewVultrProvisioner(accessToken)
-	case "ovh":
-		return provision.NewOVHProvisioner(endpoint, accessToken, secretKey, consumerKey, region, projectID)
-	default:
-		return nil, fmt.Errorf("no provisioner for provider: %s", provider)
+	// The exit-server is ready, so failing to write the artifacts is only
+	// a warning
+	if len(plan.ClientArtifacts) > 0 {
+		dir, err := writeClientArtifacts(plan.ClientArtifacts, *summary)
+		if err != nil {
+			fmt.Fprintf(progress, "Warning: unable to write the client artifacts to %s: %s\n", plan.ClientArtifacts, err)
+		} else {
+			summary.ClientArtifacts = dir
+		}
 	}
 
+	return summary, nil
 }
 
 func generateAuth() (string, error) {
@@ -446,158 +1109,13 @@ func generateAuth() (string, error) {
 	return pwdRes, pwdErr
 }
 
-func createHost(provider, name, region, zone, projectID, userData, inletsProControlPort, vpcID, subnetID, awsKeyName string, tcp bool, letsencryptDomains []string) (*provision.BasicHost, error) {
-	if provider == "digitalocean" {
-		return &provision.BasicHost{
-			Name:       name,
-			OS:         "debian-13-x64",
-			Plan:       "s-1vcpu-512mb-10gb",
-			Region:     region,
-			UserData:   userData,
-			Additional: map[string]string{},
-		}, nil
-	} else if provider == "ovh" {
-		return &provision.BasicHost{
-			Name:       name,
-			OS:         "Ubuntu 22.04",
-			Plan:       "s1-2",
-			Region:     region,
-			UserData:   userData,
-			Additional: map[string]string{},
-		}, nil
-	} else if provider == "scaleway" {
-		return &provision.BasicHost{
-			Name:       name,
-			OS:         "ubuntu-jammy",
-			Plan:       "DEV1-S",
-			Region:     region,
-			UserData:   userData,
-			Additional: map[string]string{},
-		}, nil
-
-	} else if provider == "gce" {
-		return &provision.BasicHost{
-			Name:     name,
-			OS:       "projects/ubuntu-os-cloud/global/images/ubuntu-minimal-2204-jammy-v20240606",
-			Plan:     "f1-micro",
-			Region:   region,
-			UserData: userData,
-			Additional: map[string]string{
-				"projectid":     projectID,
-				"zone":          zone,
-				"firewall-name": "inlets",
-				"firewall-port": inletsProControlPort,
-				"pro":           fmt.Sprint(tcp),
-			},
-		}, nil
-	} else if provider == "ec2" {
-		// Ubuntu images can be found here https://cloud-images.ubuntu.com/locator/ec2/
-		// Name is used in the OS field so the ami can be lookup up in the region specified
-
-		var additional = map[string]string{
-			"inlets-port": inletsProControlPort,
-			"pro":         fmt.Sprint(tcp),
-		}
-
-		if len(letsencryptDomains) > 0 {
-			additional["ports"] = "80,443"
-		}
-
-		if len(awsKeyName) > 0 {
-			additional["key-name"] = awsKeyName
-		}
-
-		if len(vpcID) > 0 {
-			additional["vpc-id"] = vpcID
-		}
-
-		if len(subnetID) > 0 {
-			additional["subnet-id"] = subnetID
-		}
-
-		return &provision.BasicHost{
-			Name:       name,
-			OS:         "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20250822",
-			Plan:       "t3.nano",
-			Region:     region,
-			UserData:   base64.StdEncoding.EncodeToString([]byte(userData)),
-			Additional: additional,
-		}, nil
-
-	} else if provider == "azure" {
-		// Ubuntu images can be found here https://docs.microsoft.com/en-us/azure/virtual-machines/linux/cli-ps-findimage#list-popular-images
-		// An image includes more than one property, it has publisher, offer, sku and version.
-		// So they have to be in "Additional" instead of just "OS".
-		return &provision.BasicHost{
-			Name:     name,
-			OS:       "Additional.imageOffer",
-			Plan:     "Standard_B1ls",
-			Region:   region,
-			UserData: userData,
-			Additional: map[string]string{
-				"inlets-port":    inletsProControlPort,
-				"pro":            fmt.Sprint(tcp),
-				"imagePublisher": "Canonical",
-				"imageOffer":     "0001-com-ubuntu-server-jammy",
-				"imageSku":       "22_04-lts-gen2",
-				"imageVersion":   "latest",
-			},
-		}, nil
-	} else if provider == "vultr" {
-		// OS:
-		//  A complete list of available OS is available using: https://api.vultr.com/v1/os/list
-		//  387 = Ubuntu 20.04 x64
-		// Plans:
-		//  A complete list of available OS is available using: https://api.vultr.com/v1/plans/list
-		//  201 = 1024 MB RAM,25 GB SSD,1.00 TB BW
-		const ubuntu22_04_x64 = "1743"
-		return &provision.BasicHost{
-			Name:       name,
-			OS:         ubuntu22_04_x64,
-			Plan:       "201",
-			Region:     region,
-			UserData:   userData,
-			Additional: map[string]string{},
-		}, nil
-	} else if provider == "linode" {
-		// Image:
-		//  List of images can be retrieved using: https://api.linode.com/v4/images
-		//  Example response: .."id": "linode/ubuntu20.04", "label": "Ubuntu 20.04 LTS"..
-		// Type:
-		//  Type is the VM plan / size in linode.
-		//  List of type and price can be retrieved using curl https://api.linode.com/v4/linode/types
-		return &provision.BasicHost{
-			Name:     name,
-			OS:       "linode/ubuntu22.04",
-			Plan:     "g6-nanode-1",
-			Region:   region,
-			UserData: userData,
-			Additional: map[string]string{
-				"inlets-port": inletsProControlPort,
-				"pro":         fmt.Sprint(tcp),
-			},
-		}, nil
-	} else if provider == "hetzner" {
-		// Easiest way to get the information of available images and server types is through
-		// the Hetzner API, but it requires auth for any type of call.
-		// Images can be fetched from https://api.hetzner.cloud/v1/images
-		// Server types can be fetched from https://api.hetzner.cloud/v1/server_types
-		// The regions available are hel1 (Helsinki), nur1 (Nuremberg), fsn1 (Falkenstein)
-		return &provision.BasicHost{
-			Name:     name,
-			Region:   region,
-			Plan:     "cx23",
-			OS:       "debian-13",
-			UserData: userData,
-		}, nil
-	}
-
-	return nil, fmt.Errorf("no provisioner for provider: %q", provider)
-}
-
 // makeHTTPSUserdata makes a user-data script in bash to setup inlets
-// with a systemd service and the given version.
-func makeHTTPSUserdata(authToken, version, letsEncryptIssuer string, domains []string) string {
+// with a systemd service and the given version and architecture. The
+// script exits before installing inlets-pro if the binary does not match
+// checksum, or the published checksum when that is empty. The public IP
+// is printed by ipCommand, see makeIPCommand.
+func makeHTTPSUserdata(authToken, version, arch, checksum, ipCommand, letsEncryptIssuer string, domains []string) string {
+	asset := inletsProAsset(arch)
 
 	domainFlags := ""
 	for _, domain := range domains {
@@ -606,12 +1124,14 @@ func makeHTTPSUserdata(authToken, version, letsEncryptIssuer string, domains []s
 	domainFlags = strings.TrimSpace(domainFlags)
 	return fmt.Sprintf(`#!/bin/bash
 export AUTHTOKEN="%s"
-export IP=$(curl -sfSL https://checkip.amazonaws.com)
+export IP=$(%s)
 export VERSION="%s"
 
-curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro -o /tmp/inlets-pro && \
+curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/%s -o /tmp/inlets-pro && \
+  %s && \
   chmod +x /tmp/inlets-pro  && \
-  mv /tmp/inlets-pro /usr/local/bin/inlets-pro
+  mv /tmp/inlets-pro /usr/local/bin/inlets-pro || \
+  { echo "inlets-pro $VERSION could not be downloaded or failed SHA256 verification" >&2; exit 1; }
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro-http.service -o inlets-pro.service && \
   mv inlets-pro.service /etc/systemd/system/inlets-pro.service && \
@@ -622,21 +1142,26 @@ curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlet
   systemctl daemon-reload && \
   systemctl start inlets-pro && \
   systemctl enable inlets-pro
-`, authToken, version, domainFlags, letsEncryptIssuer)
+`, authToken, ipCommand, version, asset, makeVerifyCommand("/tmp/inlets-pro", "$VERSION", asset, checksum), domainFlags, letsEncryptIssuer)
 }
 
 // makeExitServerUserdata makes a user-data script in bash to setup inlets
-// with systemd service and the given version.
-func makeExitServerUserdata(authToken, version string) string {
+// with systemd service and the given version and architecture, verifying
+// the binary and detecting the public IP in the same way as
+// makeHTTPSUserdata.
+func makeExitServerUserdata(authToken, version, arch, checksum, ipCommand string) string {
+	asset := inletsProAsset(arch)
 
 	return fmt.Sprintf(`#!/bin/bash
 export AUTHTOKEN="%s"
-export IP=$(curl -sfSL https://checkip.amazonaws.com)
+export IP=$(%s)
 export VERSION="%s"
 
-curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro -o /tmp/inlets-pro && \
+curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/%s -o /tmp/inlets-pro && \
+  %s && \
   chmod +x /tmp/inlets-pro  && \
-  mv /tmp/inlets-pro /usr/local/bin/inlets-pro
+  mv /tmp/inlets-pro /usr/local/bin/inlets-pro || \
+  { echo "inlets-pro $VERSION could not be downloaded or failed SHA256 verification" >&2; exit 1; }
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro.service -o inlets-pro.service && \
   mv inlets-pro.service /etc/systemd/system/inlets-pro.service && \
@@ -645,5 +1170,5 @@ curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlet
   systemctl daemon-reload && \
   systemctl start inlets-pro && \
   systemctl enable inlets-pro
-`, authToken, version)
+`, authToken, ipCommand, version, asset, makeVerifyCommand("/tmp/inlets-pro", "$VERSION", asset, checksum))
 }
@@ -9,7 +9,7 @@ import (
 )
 
 func Test_MakeTCPUserdata_OneTunnel(t *testing.T) {
-	got := makeExitServerUserdata("token", "0.11.5")
+	got := makeExitServerUserdata("token", "0.11.5", "amd64", "", "curl -sfSL https://checkip.amazonaws.com")
 	os.WriteFile("/tmp/tcp.txt", []byte(got), 0600)
 	want := `#!/bin/bash
 export AUTHTOKEN="token"
@@ -17,8 +17,10 @@ export IP=$(curl -sfSL https://checkip.amazonaws.com)
 export VERSION="0.11.5"
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro -o /tmp/inlets-pro && \
+  echo "$(curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro.sha256 | cut -d ' ' -f 1)  /tmp/inlets-pro" | sha256sum --check --strict - && \
   chmod +x /tmp/inlets-pro  && \
-  mv /tmp/inlets-pro /usr/local/bin/inlets-pro
+  mv /tmp/inlets-pro /usr/local/bin/inlets-pro || \
+  { echo "inlets-pro $VERSION could not be downloaded or failed SHA256 verification" >&2; exit 1; }
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro.service -o inlets-pro.service && \
   mv inlets-pro.service /etc/systemd/system/inlets-pro.service && \
@@ -36,7 +38,7 @@ curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlet
 }
 
 func Test_MakeHTTPSUserdata_OneDomain(t *testing.T) {
-	got := makeHTTPSUserdata("token", "0.9.40", "prod", []string{"example.com"})
+	got := makeHTTPSUserdata("token", "0.9.40", "amd64", "", "curl -sfSL https://checkip.amazonaws.com", "prod", []string{"example.com"})
 
 	os.WriteFile("/tmp/t.txt", []byte(got), 0600)
 	want := `#!/bin/bash
@@ -45,8 +47,10 @@ export IP=$(curl -sfSL https://checkip.amazonaws.com)
 export VERSION="0.9.40"
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro -o /tmp/inlets-pro && \
+  echo "$(curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro.sha256 | cut -d ' ' -f 1)  /tmp/inlets-pro" | sha256sum --check --strict - && \
   chmod +x /tmp/inlets-pro  && \
-  mv /tmp/inlets-pro /usr/local/bin/inlets-pro
+  mv /tmp/inlets-pro /usr/local/bin/inlets-pro || \
+  { echo "inlets-pro $VERSION could not be downloaded or failed SHA256 verification" >&2; exit 1; }
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro-http.service -o inlets-pro.service && \
   mv inlets-pro.service /etc/systemd/system/inlets-pro.service && \
@@ -64,7 +68,7 @@ curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlet
 }
 
 func Test_MakeHTTPSUserdata_TwoDomains(t *testing.T) {
-	got := makeHTTPSUserdata("token", "0.9.40", "prod",
+	got := makeHTTPSUserdata("token", "0.9.40", "amd64", "", "curl -sfSL https://checkip.amazonaws.com", "prod",
 		[]string{"a.example.com", "b.example.com"})
 
 	os.WriteFile("/tmp/t.txt", []byte(got), 0600)
@@ -74,8 +78,10 @@ export IP=$(curl -sfSL https://checkip.amazonaws.com)
 export VERSION="0.9.40"
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro -o /tmp/inlets-pro && \
+  echo "$(curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro.sha256 | cut -d ' ' -f 1)  /tmp/inlets-pro" | sha256sum --check --strict - && \
   chmod +x /tmp/inlets-pro  && \
-  mv /tmp/inlets-pro /usr/local/bin/inlets-pro
+  mv /tmp/inlets-pro /usr/local/bin/inlets-pro || \
+  { echo "inlets-pro $VERSION could not be downloaded or failed SHA256 verification" >&2; exit 1; }
 
 curl -SLsf https://github.com/inlets/inlets-pro/releases/download/$VERSION/inlets-pro-http.service -o inlets-pro.service && \
   mv inlets-pro.service /etc/systemd/system/inlets-pro.service && \
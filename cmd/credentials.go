@@ -0,0 +1,65 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/inlets/inletsctl/pkg/env"
+)
+
+// azureEnvKeys map the environment variables read by the Azure SDK to the
+// fields of an SDK auth file, which is what the Azure provisioner expects
+// to be given as its access token
+var azureEnvKeys = map[string]string{
+	"AZURE_TENANT_ID":                   "tenantId",
+	"AZURE_CLIENT_ID":                   "clientId",
+	"AZURE_CLIENT_SECRET":               "clientSecret",
+	"AZURE_CLIENT_CERTIFICATE_PATH":     "certificatePath",
+	"AZURE_CLIENT_CERTIFICATE_PASSWORD": "certificatePassword",
+	"AZURE_USERNAME":                    "username",
+	"AZURE_PASSWORD":                    "password",
+	"AZURE_SUBSCRIPTION_ID":             "subscriptionId",
+}
+
+// azureEnvCredentials makes an SDK auth file from the AZURE_* variables
+// used by the Azure SDK and Terraform, a tenant and client ID are needed
+// along with a client secret, a certificate or a username and password.
+// The source is reported with the variables which the SDK will use, in
+// the same order of precedence.
+func azureEnvCredentials() env.Source {
+	return env.Source{
+		Name: "$AZURE_TENANT_ID and $AZURE_CLIENT_ID with $AZURE_CLIENT_SECRET, $AZURE_CLIENT_CERTIFICATE_PATH or $AZURE_USERNAME and $AZURE_PASSWORD",
+		Used: func() string {
+			switch {
+			case len(os.Getenv("AZURE_CLIENT_SECRET")) > 0:
+				return "$AZURE_TENANT_ID, $AZURE_CLIENT_ID and $AZURE_CLIENT_SECRET"
+			case len(os.Getenv("AZURE_CLIENT_CERTIFICATE_PATH")) > 0:
+				return "$AZURE_TENANT_ID, $AZURE_CLIENT_ID and $AZURE_CLIENT_CERTIFICATE_PATH"
+			case len(os.Getenv("AZURE_USERNAME")) > 0:
+				return "$AZURE_TENANT_ID, $AZURE_CLIENT_ID, $AZURE_USERNAME and $AZURE_PASSWORD"
+			}
+			return "$AZURE_TENANT_ID and $AZURE_CLIENT_ID"
+		},
+		Get: func() (string, error) {
+			if len(os.Getenv("AZURE_TENANT_ID")) == 0 || len(os.Getenv("AZURE_CLIENT_ID")) == 0 {
+				return "", nil
+			}
+
+			auth := map[string]string{}
+			for envVar, key := range azureEnvKeys {
+				if value := os.Getenv(envVar); len(value) > 0 {
+					auth[key] = value
+				}
+			}
+
+			data, err := json.Marshal(auth)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
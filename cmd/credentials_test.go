@@ -0,0 +1,184 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func credentialFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	for _, name := range []string{
+		"access-token", "access-token-file",
+		"secret-key", "secret-key-file",
+		"session-token", "session-token-file",
+		"subscription-id",
+	} {
+		flags.String(name, "", "")
+	}
+	return flags
+}
+
+func Test_ReadCredentials_NativeEnvVar(t *testing.T) {
+	t.Setenv("INLETS_ACCESS_TOKEN", "")
+	t.Setenv("HCLOUD_TOKEN", "hcloud")
+
+	spec, err := getProviderSpec("hetzner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := readCredentials(credentialFlags(), spec, "hel1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessToken != "hcloud" {
+		t.Errorf("want access token from $HCLOUD_TOKEN, but got: %q", creds.AccessToken)
+	}
+
+	buf := &bytes.Buffer{}
+	creds.report(buf)
+	if want := "Using access-token from $HCLOUD_TOKEN\n"; buf.String() != want {
+		t.Errorf("want report %q, but got: %q", want, buf.String())
+	}
+}
+
+func Test_ReadCredentials_FlagOverridesNative(t *testing.T) {
+	t.Setenv("INLETS_ACCESS_TOKEN", "")
+	t.Setenv("DIGITALOCEAN_TOKEN", "from-env")
+
+	spec, err := getProviderSpec("digitalocean")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := credentialFlags()
+	flags.Set("access-token", "from-flag")
+
+	creds, err := readCredentials(flags, spec, "lon1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessToken != "from-flag" {
+		t.Errorf("want access token from the flag, but got: %q", creds.AccessToken)
+	}
+	if got := creds.Sources["access-token"]; got != "--access-token" {
+		t.Errorf("want source --access-token, but got: %q", got)
+	}
+}
+
+func Test_ReadCredentials_AWSSharedCredentials(t *testing.T) {
+	for _, name := range []string{"INLETS_ACCESS_TOKEN", "INLETS_SECRET_KEY", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"} {
+		t.Setenv(name, "")
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte(`[default]
+aws_access_key_id = default-key
+
+[staging]
+aws_access_key_id = staging-key
+aws_secret_access_key = staging-secret
+`), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "staging")
+
+	spec, err := getProviderSpec("ec2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := readCredentials(credentialFlags(), spec, "eu-west-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessToken != "staging-key" || creds.SecretKey != "staging-secret" || creds.SessionToken != "" {
+		t.Errorf("want credentials from the staging profile, but got: %+v", creds)
+	}
+}
+
+func Test_ReadCredentials_AzureEnv(t *testing.T) {
+	t.Setenv("INLETS_ACCESS_TOKEN", "")
+	t.Setenv("AZURE_AUTH_LOCATION", "")
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+	t.Setenv("AZURE_SUBSCRIPTION_ID", "subscription")
+
+	spec, err := getProviderSpec("azure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := readCredentials(credentialFlags(), spec, "uksouth", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.SubscriptionID != "subscription" {
+		t.Errorf("want subscription from $AZURE_SUBSCRIPTION_ID, but got: %q", creds.SubscriptionID)
+	}
+
+	auth := map[string]string{}
+	if err := json.Unmarshal([]byte(creds.AccessToken), &auth); err != nil {
+		t.Fatal(err)
+	}
+	if auth["tenantId"] != "tenant" || auth["clientId"] != "client" || auth["clientSecret"] != "secret" {
+		t.Errorf("want an auth file made from the AZURE_* variables, but got: %v", auth)
+	}
+}
+
+func Test_ReadCredentials_AzureEnvReportsVariables(t *testing.T) {
+	t.Setenv("INLETS_ACCESS_TOKEN", "")
+	t.Setenv("AZURE_AUTH_LOCATION", "")
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+
+	spec, err := getProviderSpec("azure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "client secret",
+			env:  map[string]string{"AZURE_CLIENT_SECRET": "secret"},
+			want: "$AZURE_TENANT_ID, $AZURE_CLIENT_ID and $AZURE_CLIENT_SECRET",
+		},
+		{
+			name: "certificate",
+			env:  map[string]string{"AZURE_CLIENT_CERTIFICATE_PATH": "/tmp/client.pfx"},
+			want: "$AZURE_TENANT_ID, $AZURE_CLIENT_ID and $AZURE_CLIENT_CERTIFICATE_PATH",
+		},
+		{
+			name: "username and password",
+			env:  map[string]string{"AZURE_USERNAME": "user", "AZURE_PASSWORD": "password"},
+			want: "$AZURE_TENANT_ID, $AZURE_CLIENT_ID, $AZURE_USERNAME and $AZURE_PASSWORD",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"AZURE_CLIENT_SECRET", "AZURE_CLIENT_CERTIFICATE_PATH", "AZURE_USERNAME", "AZURE_PASSWORD"} {
+				t.Setenv(name, tc.env[name])
+			}
+
+			creds, err := readCredentials(credentialFlags(), spec, "uksouth", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := creds.Sources["access-token"]; got != tc.want {
+				t.Errorf("want access-token from %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
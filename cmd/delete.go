@@ -5,166 +5,296 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/inlets/cloud-provision/provision"
-	"github.com/inlets/inletsctl/pkg/env"
+	"github.com/inlets/inletsctl/pkg/dns"
+	"github.com/inlets/inletsctl/pkg/inventory"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	inletsCmd.AddCommand(deleteCmd)
-	deleteCmd.Flags().StringP("provider", "p", "digitalocean", "The cloud provider - digitalocean, gce, ec2, azure, scaleway, linode, hetzner or vultr")
+	deleteCmd.Flags().StringP("provider", "p", "digitalocean", "The cloud provider - digitalocean, gce, ec2, azure, scaleway, linode, hetzner, ovh or vultr")
 	deleteCmd.Flags().StringP("region", "r", "lon1", "The region for your cloud provider")
 	deleteCmd.Flags().StringP("zone", "z", "us-central1-a", "The zone for the exit node (gce)")
 
 	deleteCmd.Flags().StringP("access-token", "a", "", "The access token for your cloud")
 	deleteCmd.Flags().StringP("access-token-file", "f", "", "Read this file for the access token for your cloud")
+	deleteCmd.Flags().String("access-token-cmd", "", "Run this command and read the access token for your cloud from its output, i.e. \"pass show inlets/do-token\"")
 
 	deleteCmd.Flags().StringP("id", "i", "", "Host ID")
 	deleteCmd.Flags().String("ip", "", "Host IP")
+	deleteCmd.Flags().Bool("release-reserved-ip", false, "Release the reserved IP recorded for the tunnel in the inventory, by default it is kept so that the tunnel can be recreated with the same address")
+	deleteCmd.Flags().Bool("keep-dns-records", false, "Keep the DNS records created with --dns-provider, by default they are removed when they still point at the tunnel")
+	addDNSCredentialFlags(deleteCmd.Flags())
 
 	deleteCmd.Flags().String("secret-key", "", "The secret key for your cloud (scaleway, ec2)")
 	deleteCmd.Flags().String("secret-key-file", "", "Read this file for the secret key for your cloud (scaleway, ec2)")
+	deleteCmd.Flags().String("secret-key-cmd", "", "Run this command and read the secret key for your cloud from its output (scaleway, ec2)")
 	deleteCmd.Flags().String("session-token", "", "The session token for ec2 (when using with temporary credentials)")
 	deleteCmd.Flags().String("session-token-file", "", "Read this file for the session token for ec2 (when using with temporary credentials)")
+	deleteCmd.Flags().String("session-token-cmd", "", "Run this command and read the session token for ec2 from its output (when using with temporary credentials)")
 
 	deleteCmd.Flags().String("organisation-id", "", "Organisation ID (scaleway)")
-	deleteCmd.Flags().String("project-id", "", "Project ID (gce)")
+	deleteCmd.Flags().String("project-id", "", "Project ID (gce, ovh)")
 	deleteCmd.Flags().String("subscription-id", "", "Subscription ID (azure)")
 
 	deleteCmd.Flags().String("endpoint", "ovh-eu", "API endpoint (ovh), default: ovh-eu")
 	deleteCmd.Flags().String("consumer-key", "", "The Consumer Key for using the OVH API")
+
+	deleteCmd.Flags().String("profile", "", "Read the provider, credentials and region from this profile in $HOME/.inletsctl/config.yaml, flags given on the command line override the profile, default: $INLETSCTL_PROFILE")
+
+	deleteCmd.Flags().StringP("output", "o", outputText, "Output format for the result - text, json, yaml or env, progress is written to stderr for all but text")
 }
 
 // deleteCmd represents the client sub command
 var deleteCmd = &cobra.Command{
-	Use:   "delete",
+	Use:   "delete [NAME]",
 	Short: "Delete an exit node",
 	Long: `Delete an exit node created at an earlier time by inletsctl using an API 
-key for your cloud host.`,
-	Example: `  inletsctl delete --provider digitalocean --id 1235678
+key for your cloud host.
+
+When the name of a tunnel is given, its provider, host ID, region and zone
+are read from the local inventory, see also: inletsctl show. The DNS
+records created for it with --dns-provider are removed too, using the
+same credentials as create, along with the firewall which create made
+for it.`,
+	Example: `  inletsctl delete tunnel-richardcase --access-token-file $HOME/access-token
+  inletsctl delete --provider digitalocean --id 1235678
+  inletsctl delete tunnel-richardcase --profile staging
+  inletsctl delete tunnel-richardcase --release-reserved-ip
+  inletsctl delete tunnel-richardcase --keep-dns-records
 	inletsctl delete --access-token-file $HOME/access-token --region lon1
 `,
+	Args:          cobra.MaximumNArgs(1),
 	RunE:          runDelete,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
 
-func runDelete(cmd *cobra.Command, _ []string) error {
-	provider, err := cmd.Flags().GetString("provider")
+func runDelete(cmd *cobra.Command, args []string) error {
+	output, err := cmd.Flags().GetString("output")
 	if err != nil {
-		return errors.Wrap(err, "failed to get 'provider' value.")
+		return errors.Wrap(err, "failed to get 'output' value.")
 	}
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	progress := progressWriter(output)
 
-	fmt.Printf("Using provider: %s\n", provider)
+	inv, err := loadInventory()
+	if err != nil {
+		if len(args) > 0 {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: unable to read the inventory: %s\n", err)
+	}
 
-	var region string
-	if cmd.Flags().Changed("region") {
-		if regionVal, err := cmd.Flags().GetString("region"); isSet(regionVal) {
-			if err != nil {
-				return errors.Wrap(err, "failed to get 'region' value.")
-			}
-			region = regionVal
+	var tunnel inventory.Tunnel
+	var found bool
+	if len(args) > 0 {
+		if tunnel, found = inv.Get(args[0]); !found {
+			return fmt.Errorf("no tunnel named %q found in the inventory", args[0])
 		}
+	}
 
-	} else if provider == "scaleway" {
-		region = "fr-par-1"
-	} else if provider == "ec2" {
-		region = "eu-west-1"
+	// The inventory takes precedence over a profile, but not over flags
+	// given on the command line
+	userSet := changedFlags(cmd.Flags())
+	if err := applyProfile(cmd.Flags(), progress); err != nil {
+		return err
 	}
 
-	accessToken, err := env.GetRequiredFileOrString(cmd.Flags(),
-		"access-token-file",
-		"access-token",
-		"INLETS_ACCESS_TOKEN",
-	)
+	provider, err := cmd.Flags().GetString("provider")
 	if err != nil {
-		return err
+		return errors.Wrap(err, "failed to get 'provider' value.")
+	}
+	if found && !userSet["provider"] {
+		provider = tunnel.Provider
 	}
 
-	var secretKey string
-	var sessionToken string
-	var organisationID string
-	if provider == "scaleway" || provider == "ec2" || provider == "ovh" {
-		var secretKeyErr error
-		secretKey, secretKeyErr = env.GetRequiredFileOrString(cmd.Flags(),
-			"secret-key-file",
-			"secret-key",
-			"INLETS_SECRET_KEY",
-		)
-		if secretKeyErr != nil {
-			return secretKeyErr
-		}
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return err
+	}
 
-		if provider == "ec2" {
-			var sessionTokenErr error
-			sessionToken, sessionTokenErr = getFileOrString(cmd.Flags(), "session-token-file", "session-token", false)
-			if sessionTokenErr != nil {
-				return sessionTokenErr
-			}
-		}
+	fmt.Fprintf(progress, "Using provider: %s\n", provider)
 
-		if provider == "scaleway" {
-			organisationID, _ = cmd.Flags().GetString("organisation-id")
-			if len(organisationID) == 0 {
-				return fmt.Errorf("--organisation-id cannot be empty")
-			}
-		}
+	region := providerRegion(cmd.Flags(), spec)
+	if found && !userSet["region"] {
+		region = tunnel.Region
 	}
 
-	var subscriptionID string
-	if provider == "azure" {
-		subscriptionID, _ = cmd.Flags().GetString("subscription-id")
+	creds, err := readCredentials(cmd.Flags(), spec, region, true)
+	if err != nil {
+		return err
 	}
-	var endpoint string
-	var consumerKey string
-	if provider == "ovh" {
-		endpoint, err = cmd.Flags().GetString("endpoint")
-		if err != nil {
-			return errors.Wrap(err, "failed to get 'endpoint' value")
-		}
-		consumerKey, err = cmd.Flags().GetString("consumer-key")
-		if err != nil {
-			return errors.Wrap(err, "failed to get 'endpoint' value")
-		}
+	creds.report(progress)
+	if found && !userSet["project-id"] {
+		creds.ProjectID = tunnel.ProjectID
+	}
+	if err := creds.check(provider, spec.RequiredFlags); err != nil {
+		return err
 	}
 
-	projectID, _ := cmd.Flags().GetString("project-id")
-	provisioner, err := getProvisioner(provider, accessToken, secretKey, organisationID, region, subscriptionID, sessionToken, endpoint, consumerKey, projectID)
+	provisioner, err := getProvisioner(provider, creds)
 	if err != nil {
 		return err
 	}
 
 	hostID, _ := cmd.Flags().GetString("id")
 	hostIP, _ := cmd.Flags().GetString("ip")
-	zone, _ := cmd.Flags().GetString("zone")
+	zone := providerZone(cmd.Flags(), spec)
+
+	if found {
+		if !cmd.Flags().Changed("id") && !cmd.Flags().Changed("ip") {
+			hostID = tunnel.HostID
+		}
+		if !userSet["zone"] && len(tunnel.Zone) > 0 {
+			zone = tunnel.Zone
+		}
+	}
 
 	if isNotSet(hostID) && isNotSet(hostIP) {
 		return fmt.Errorf("give a valid --id or --ip for your host")
 	}
 
+	if isNotSet(hostID) && !spec.DeleteByIP {
+		return fmt.Errorf("deleting by --ip is not supported by the %s provider, give the --id of the host instead", provider)
+	}
+
 	if provider == "gce" && isSet(hostIP) {
-		if isNotSet(projectID) {
+		if isNotSet(creds.ProjectID) {
 			return fmt.Errorf("--ip requires --project-id to be set for provider")
 		}
 	}
 
+	releaseIP, err := cmd.Flags().GetBool("release-reserved-ip")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'release-reserved-ip' value.")
+	}
+
+	recorded := tunnel
+	if !found && inv != nil {
+		recorded, _ = inv.Find(provider, hostID, hostIP)
+	}
+	reservedIP := recorded.ReservedIP
+
+	var rips reservedIPs
+	if releaseIP {
+		if len(reservedIP) == 0 {
+			return fmt.Errorf("--release-reserved-ip needs a tunnel with a reserved IP in the inventory, release other addresses from the %s console", provider)
+		}
+		if rips, err = getReservedIPs(provider, creds); err != nil {
+			return err
+		}
+	}
+
+	keepDNS, err := cmd.Flags().GetBool("keep-dns-records")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'keep-dns-records' value.")
+	}
+
+	// The credentials are read up front, so that the host is not deleted
+	// when its records could not be removed
+	var dnsClient dns.Provider
+	if len(recorded.DNSProvider) > 0 && len(recorded.IP) > 0 && !keepDNS {
+		dnsCreds, err := readDNSCredentials(cmd.Flags(), recorded.DNSProvider, provider, creds, true, progress)
+		if err != nil {
+			return fmt.Errorf("%w, or give --keep-dns-records", err)
+		}
+		if dnsClient, err = dns.New(recorded.DNSProvider, dnsCreds); err != nil {
+			return err
+		}
+	}
+
+	var firewalls cloudFirewalls
+	if len(recorded.Firewall) > 0 {
+		if firewalls, err = getFirewalls(provider, creds); err != nil {
+			return err
+		}
+	}
+
 	deleteRequest := provision.HostDeleteRequest{
 		ID:        hostID,
 		IP:        hostIP,
-		ProjectID: projectID,
+		ProjectID: creds.ProjectID,
 		Zone:      zone,
 		Region:    region,
 	}
 
-	fmt.Printf("Deleting host: %s%s from %s\n", hostID, hostIP, provider)
+	fmt.Fprintf(progress, "Deleting host: %s%s from %s\n", hostID, hostIP, provider)
 
 	if err = provisioner.Delete(deleteRequest); err != nil {
 		return err
 	}
 
-	return err
+	// A reserved IP which could not be released is reported once the rest
+	// of the tunnel has been cleaned up
+	var released bool
+	var releaseErr error
+	if releaseIP {
+		if err := releaseReservedIP(rips, reservedIP, region, zone, reservedIPTimeout, 5*time.Second, progress); err != nil {
+			releaseErr = fmt.Errorf("host was deleted, but reserved IP %s was not released, release it from the %s console: %w", reservedIP, provider, err)
+		} else {
+			released = true
+		}
+	} else if len(reservedIP) > 0 {
+		fmt.Fprintf(progress, "Keeping reserved IP %s, give it to create with --reserved-ip, or release it with --release-reserved-ip\n", reservedIP)
+	}
+
+	var removed []string
+	if dnsClient != nil {
+		removed = removeDNSRecords(dnsClient, recorded.DNSProvider, recorded.Domains, recorded.IP, progress)
+	}
+
+	// The firewall can only be removed once the host has gone
+	var firewallRemoved bool
+	if firewalls != nil {
+		firewallRemoved = removeFirewallOrWarn(firewalls, recorded.Firewall, provider, 5*time.Second, progress)
+	}
+
+	if inv != nil {
+		if !found {
+			tunnel, found = inv.Find(provider, hostID, hostIP)
+		}
+		if found {
+			inv.Remove(tunnel.Name)
+			if err := inv.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: unable to remove tunnel %s from the inventory: %s\n", tunnel.Name, err)
+			}
+		}
+	}
+
+	if output != outputText {
+		// A tunnel deleted by name has its IP in the inventory
+		ip := hostIP
+		if len(ip) == 0 {
+			ip = recorded.IP
+		}
+
+		err := printDocument(os.Stdout, output, deleteSummary{
+			Name:     tunnel.Name,
+			Provider: provider,
+			HostID:   hostID,
+			IP:       ip,
+			Deleted:  true,
+
+			ReservedIP:         reservedIP,
+			ReservedIPReleased: released,
+			DNSRecordsRemoved:  removed,
+			Firewall:           recorded.Firewall,
+			FirewallRemoved:    firewallRemoved,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return releaseErr
 }
 
 func isNotSet(s string) bool {
@@ -0,0 +1,208 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/inlets/cloud-provision/provision"
+	"golang.org/x/oauth2"
+)
+
+// digitalOceanReservedIPs manages DigitalOcean reserved IPs, traffic to
+// the reserved IP is routed to the droplet's anchor IP, so the exit-server
+// needs no configuration for it
+type digitalOceanReservedIPs struct {
+	client *godo.Client
+}
+
+func newDigitalOceanReservedIPs(accessToken string) *digitalOceanReservedIPs {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &digitalOceanReservedIPs{
+		client: godo.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (r *digitalOceanReservedIPs) Allocate(name, region, zone string) (string, error) {
+	ip, _, err := r.client.ReservedIPs.Create(context.Background(), &godo.ReservedIPCreateRequest{Region: region})
+	if err != nil {
+		return "", err
+	}
+	return ip.IP, nil
+}
+
+// Attach assigns the reserved IP to the droplet and waits for the action
+// to complete
+func (r *digitalOceanReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	ctx := context.Background()
+
+	dropletID, err := strconv.Atoi(host.ID)
+	if err != nil {
+		return fmt.Errorf("invalid droplet ID %q: %w", host.ID, err)
+	}
+
+	action, _, err := r.client.ReservedIPActions.Assign(ctx, ip, dropletID)
+	if err != nil {
+		return err
+	}
+
+	return waitUntil(reservedIPTimeout, 2*time.Second, func() (bool, error) {
+		action, _, err := r.client.ReservedIPActions.Get(ctx, ip, action.ID)
+		if err != nil {
+			return false, err
+		}
+		if action.Status == "errored" {
+			return false, fmt.Errorf("assigning reserved IP %s to droplet %d failed", ip, dropletID)
+		}
+		return action.Status == godo.ActionCompleted, nil
+	})
+}
+
+func (r *digitalOceanReservedIPs) Release(ip, region, zone string) error {
+	_, err := r.client.ReservedIPs.Delete(context.Background(), ip)
+	return err
+}
+
+// digitalOceanFirewalls manages DigitalOcean cloud firewalls. A firewall
+// drops outbound traffic which no rule allows, so rules are added to allow
+// every outbound connection.
+type digitalOceanFirewalls struct {
+	client *godo.Client
+}
+
+func newDigitalOceanFirewalls(accessToken string) *digitalOceanFirewalls {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &digitalOceanFirewalls{
+		client: godo.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (f *digitalOceanFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	dropletID, err := strconv.Atoi(host.ID)
+	if err != nil {
+		return fmt.Errorf("invalid droplet ID %q: %w", host.ID, err)
+	}
+
+	req := &godo.FirewallRequest{
+		Name:       name,
+		DropletIDs: []int{dropletID},
+	}
+	for _, rule := range rules {
+		for _, ports := range rule.Ports {
+			req.InboundRules = append(req.InboundRules, godo.InboundRule{
+				Protocol:  "tcp",
+				PortRange: ports,
+				Sources:   &godo.Sources{Addresses: rule.Sources},
+			})
+		}
+	}
+	for _, protocol := range []string{"tcp", "udp", "icmp"} {
+		outbound := godo.OutboundRule{
+			Protocol:     protocol,
+			Destinations: &godo.Destinations{Addresses: anyAddress},
+		}
+		if protocol != "icmp" {
+			outbound.PortRange = "all"
+		}
+		req.OutboundRules = append(req.OutboundRules, outbound)
+	}
+
+	_, _, err = f.client.Firewalls.Create(context.Background(), req)
+	return err
+}
+
+func (f *digitalOceanFirewalls) Remove(name string) error {
+	ctx := context.Background()
+
+	opt := &godo.ListOptions{PerPage: 200}
+	for {
+		firewalls, res, err := f.client.Firewalls.List(ctx, opt)
+		if err != nil {
+			return err
+		}
+		for _, firewall := range firewalls {
+			if firewall.Name == name {
+				_, err := f.client.Firewalls.Delete(ctx, firewall.ID)
+				return err
+			}
+		}
+
+		if res.Links == nil || res.Links.IsLastPage() {
+			return nil
+		}
+		page, err := res.Links.CurrentPage()
+		if err != nil {
+			return err
+		}
+		opt.Page = page + 1
+	}
+}
+
+// digitalOceanPortTags records the ports as an inlets-ports tag on the
+// droplet, the tag is created before the droplet can be given it
+type digitalOceanPortTags struct {
+	client *godo.Client
+}
+
+func newDigitalOceanPortTags(accessToken string) *digitalOceanPortTags {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &digitalOceanPortTags{
+		client: godo.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (t *digitalOceanPortTags) Tag(hostID string, ports []int) error {
+	ctx := context.Background()
+
+	tag := portsTagValue(ports)
+	if _, _, err := t.client.Tags.Create(ctx, &godo.TagCreateRequest{Name: tag}); err != nil {
+		return err
+	}
+	_, err := t.client.Tags.TagResources(ctx, tag, &godo.TagResourcesRequest{
+		Resources: []godo.Resource{{ID: hostID, Type: godo.DropletResourceType}},
+	})
+	return err
+}
+
+// digitalOceanSSHKeys looks up the keys of a DigitalOcean account
+type digitalOceanSSHKeys struct {
+	client *godo.Client
+}
+
+func newDigitalOceanSSHKeys(accessToken string) *digitalOceanSSHKeys {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &digitalOceanSSHKeys{
+		client: godo.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (k *digitalOceanSSHKeys) Get(name string) (string, error) {
+	ctx := context.Background()
+
+	opt := &godo.ListOptions{PerPage: 200}
+	for {
+		keys, res, err := k.client.Keys.List(ctx, opt)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			if key.Name == name {
+				return key.PublicKey, nil
+			}
+		}
+
+		if res.Links == nil || res.Links.IsLastPage() {
+			return "", fmt.Errorf("no DigitalOcean SSH key named %q", name)
+		}
+		page, err := res.Links.CurrentPage()
+		if err != nil {
+			return "", err
+		}
+		opt.Page = page + 1
+	}
+}
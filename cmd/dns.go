@@ -0,0 +1,159 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inlets/inletsctl/pkg/dns"
+	"github.com/inlets/inletsctl/pkg/env"
+	"github.com/spf13/pflag"
+)
+
+// dnsComputeProviders maps the DNS providers which share their credentials
+// with a compute provider, so that --provider's credentials and native
+// sources are used when no --dns-* credentials are given
+var dnsComputeProviders = map[string]string{
+	"digitalocean": "digitalocean",
+	"hetzner":      "hetzner",
+	"route53":      "ec2",
+}
+
+// cloudflareSources are where Cloudflare's own tooling, such as wrangler
+// and its Terraform provider, look for an API token
+var cloudflareSources = []env.Source{env.EnvVar("CLOUDFLARE_API_TOKEN"), env.EnvVar("CF_API_TOKEN")}
+
+// addDNSCredentialFlags adds the flags which give the credentials for the
+// DNS provider, to create and delete
+func addDNSCredentialFlags(flags *pflag.FlagSet) {
+	flags.String("dns-access-token", "", "The API token for the DNS provider, or the access key ID for route53, default: the credentials of --provider when it is the same cloud")
+	flags.String("dns-access-token-file", "", "Read this file for the API token or access key ID of the DNS provider")
+	flags.String("dns-access-token-cmd", "", "Run this command and read the API token or access key ID of the DNS provider from its output")
+	flags.String("dns-secret-key", "", "The secret access key for the DNS provider (route53)")
+	flags.String("dns-secret-key-file", "", "Read this file for the secret access key of the DNS provider (route53)")
+	flags.String("dns-secret-key-cmd", "", "Run this command and read the secret access key of the DNS provider from its output (route53)")
+	flags.String("dns-session-token", "", "The session token for the DNS provider, when using temporary credentials (route53)")
+	flags.String("dns-session-token-file", "", "Read this file for the session token of the DNS provider (route53)")
+	flags.String("dns-session-token-cmd", "", "Run this command and read the session token of the DNS provider from its output (route53)")
+}
+
+// validateDNSProvider checks --dns-provider, which manages the records of
+// the --letsencrypt-domain names
+func validateDNSProvider(dnsProvider string, domains []string) error {
+	if len(dnsProvider) == 0 {
+		return nil
+	}
+
+	if _, err := dns.New(dnsProvider, dns.Credentials{}); err != nil {
+		return err
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("--dns-provider needs at least one --letsencrypt-domain to create records for")
+	}
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") {
+			return fmt.Errorf("--dns-provider cannot create a record for the wildcard domain %s", domain)
+		}
+	}
+	return nil
+}
+
+// readDNSCredentials reads the credentials for the DNS provider from the
+// --dns-* flags and INLETS_DNS_* variables. When the DNS provider is the
+// same cloud as --provider, its credentials are used next, otherwise the
+// native sources of that cloud. The source of each value is written to
+// progress.
+func readDNSCredentials(flags *pflag.FlagSet, dnsProvider, provider string, creds providerCredentials, required bool, progress io.Writer) (dns.Credentials, error) {
+	var res dns.Credentials
+
+	compute := dnsComputeProviders[dnsProvider]
+	var native map[string][]env.Source
+	if spec, err := getProviderSpec(compute); err == nil {
+		native = spec.NativeSources
+	}
+	if dnsProvider == "cloudflare" {
+		native = map[string][]env.Source{"access-token": cloudflareSources}
+	}
+
+	secrets := []struct {
+		flag     string
+		value    *string
+		same     string
+		required bool
+	}{
+		{"access-token", &res.Token, creds.AccessToken, true},
+		{"secret-key", &res.SecretKey, creds.SecretKey, dnsProvider == "route53"},
+		{"session-token", &res.SessionToken, creds.SessionToken, false},
+	}
+	for _, secret := range secrets {
+		sources := []env.Source{env.EnvVar("INLETS_DNS_" + strings.ToUpper(strings.ReplaceAll(secret.flag, "-", "_")))}
+		if compute == provider {
+			same := secret.same
+			sources = append(sources, env.Source{
+				Name: "the " + secret.flag + " of the " + provider + " provider",
+				Get:  func() (string, error) { return same, nil },
+			})
+		} else {
+			sources = append(sources, native[secret.flag]...)
+		}
+
+		flag := "dns-" + secret.flag
+		value, from, err := env.LookupFileOrString(flags, flag+"-file", flag, required && secret.required, sources...)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", dnsProvider, err)
+		}
+		*secret.value = value
+		if len(from) > 0 {
+			fmt.Fprintf(progress, "Using %s from %s\n", flag, from)
+		}
+	}
+	return res, nil
+}
+
+// createDNSRecords points each domain at ip, then waits up to timeout for
+// the authoritative name servers to answer with it, a timeout of zero
+// skips the wait
+func createDNSRecords(ctx context.Context, p dns.Provider, dnsProvider string, domains []string, ip string, timeout, poll time.Duration, progress io.Writer) error {
+	for _, domain := range domains {
+		fmt.Fprintf(progress, "Pointing %s at %s with %s\n", domain, ip, dnsProvider)
+		if err := p.Upsert(ctx, domain, ip); err != nil {
+			return fmt.Errorf("unable to create the DNS record for %s with %s: %w", domain, dnsProvider, err)
+		}
+	}
+
+	if timeout <= 0 {
+		return nil
+	}
+
+	fmt.Fprintf(progress, "Waiting up to %s for the DNS records to propagate\n", timeout)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, domain := range domains {
+		if err := dns.WaitForPropagation(waitCtx, domain, ip, poll); err != nil {
+			return fmt.Errorf("the DNS record for %s did not propagate: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// removeDNSRecords removes each domain's record for ip, a failure is only
+// a warning since the host has already been deleted. The domains whose
+// records were removed are returned.
+func removeDNSRecords(p dns.Provider, dnsProvider string, domains []string, ip string, progress io.Writer) []string {
+	var removed []string
+	for _, domain := range domains {
+		fmt.Fprintf(progress, "Removing DNS record: %s %s\n", domain, ip)
+		if err := p.Remove(context.Background(), domain, ip); err != nil {
+			fmt.Fprintf(progress, "Warning: unable to remove the DNS record for %s, remove it with %s: %s\n", domain, dnsProvider, err)
+			continue
+		}
+		removed = append(removed, domain)
+	}
+	return removed
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+type fakeDNS struct {
+	records   map[string]string
+	removeErr error
+}
+
+func (f *fakeDNS) Upsert(ctx context.Context, name, ip string) error {
+	f.records[name] = ip
+	return nil
+}
+
+func (f *fakeDNS) Remove(ctx context.Context, name, ip string) error {
+	if f.removeErr != nil {
+		return f.removeErr
+	}
+	if f.records[name] == ip {
+		delete(f.records, name)
+	}
+	return nil
+}
+
+func Test_ValidateDNSProvider(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		domains  []string
+		wantErr  string
+	}{
+		{name: "not set", provider: ""},
+		{name: "cloudflare", provider: "cloudflare", domains: []string{"a.example.com"}},
+		{name: "unknown", provider: "gandi", domains: []string{"a.example.com"}, wantErr: "unknown DNS provider"},
+		{name: "no domains", provider: "route53", wantErr: "needs at least one --letsencrypt-domain"},
+		{name: "wildcard", provider: "hetzner", domains: []string{"*.example.com"}, wantErr: "wildcard"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateDNSProvider(c.provider, c.domains)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("want error containing %q, but got: %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func makeDNSFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addDNSCredentialFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func Test_ReadDNSCredentials_SameCloudUsesProviderCredentials(t *testing.T) {
+	t.Setenv("INLETS_DNS_ACCESS_TOKEN", "")
+
+	creds := providerCredentials{AccessToken: "AKID", SecretKey: "secret", SessionToken: "session"}
+	var progress bytes.Buffer
+	got, err := readDNSCredentials(makeDNSFlags(t), "route53", "ec2", creds, true, &progress)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got.Token != "AKID" || got.SecretKey != "secret" || got.SessionToken != "session" {
+		t.Errorf("want the ec2 credentials, but got: %+v", got)
+	}
+	if !strings.Contains(progress.String(), "Using dns-access-token from the access-token of the ec2 provider") {
+		t.Errorf("want the source to be reported, but got: %q", progress.String())
+	}
+}
+
+func Test_ReadDNSCredentials_FlagsAndEnvFirst(t *testing.T) {
+	t.Setenv("INLETS_DNS_ACCESS_TOKEN", "from-env")
+
+	creds := providerCredentials{AccessToken: "do-token"}
+	got, err := readDNSCredentials(makeDNSFlags(t), "digitalocean", "digitalocean", creds, true, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Token != "from-env" {
+		t.Errorf("want the token from $INLETS_DNS_ACCESS_TOKEN, but got: %q", got.Token)
+	}
+
+	got, err = readDNSCredentials(makeDNSFlags(t, "--dns-access-token", "from-flag"), "digitalocean", "digitalocean", creds, true, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Token != "from-flag" {
+		t.Errorf("want the token from --dns-access-token, but got: %q", got.Token)
+	}
+}
+
+func Test_ReadDNSCredentials_OtherCloudUsesNativeSources(t *testing.T) {
+	t.Setenv("INLETS_DNS_ACCESS_TOKEN", "")
+	t.Setenv("CLOUDFLARE_API_TOKEN", "cf-token")
+
+	creds := providerCredentials{AccessToken: "linode-token"}
+	got, err := readDNSCredentials(makeDNSFlags(t), "cloudflare", "linode", creds, true, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Token != "cf-token" {
+		t.Errorf("want the token from $CLOUDFLARE_API_TOKEN, but got: %q", got.Token)
+	}
+}
+
+func Test_ReadDNSCredentials_Route53NeedsSecretKey(t *testing.T) {
+	for _, name := range []string{"INLETS_DNS_ACCESS_TOKEN", "INLETS_DNS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/does/not/exist")
+
+	_, err := readDNSCredentials(makeDNSFlags(t, "--dns-access-token", "AKID"), "route53", "hetzner", providerCredentials{}, true, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "--dns-secret-key") {
+		t.Errorf("want an error asking for --dns-secret-key, but got: %v", err)
+	}
+}
+
+func Test_CreateDNSRecords_WithoutWait(t *testing.T) {
+	p := &fakeDNS{records: map[string]string{}}
+
+	err := createDNSRecords(context.Background(), p, "cloudflare", []string{"a.example.com", "b.example.com"}, "192.0.2.1", 0, time.Millisecond, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]string{"a.example.com": "192.0.2.1", "b.example.com": "192.0.2.1"}
+	if !reflect.DeepEqual(p.records, want) {
+		t.Errorf("want %v, but got: %v", want, p.records)
+	}
+}
+
+func Test_RemoveDNSRecords_WarnsOnFailure(t *testing.T) {
+	p := &fakeDNS{records: map[string]string{"a.example.com": "192.0.2.1"}, removeErr: errors.New("403 forbidden")}
+
+	var progress bytes.Buffer
+	removed := removeDNSRecords(p, "hetzner", []string{"a.example.com"}, "192.0.2.1", &progress)
+
+	if len(removed) != 0 {
+		t.Errorf("want no records removed, but got: %v", removed)
+	}
+	if !strings.Contains(progress.String(), "Warning: unable to remove the DNS record for a.example.com") {
+		t.Errorf("want a warning, but got: %q", progress.String())
+	}
+}
+
+func Test_RollbackHost_RemovesDNSRecords(t *testing.T) {
+	p := &fakeProvisioner{}
+	plan, inv := makeRollbackTest(t, p)
+
+	records := &fakeDNS{records: map[string]string{"a.example.com": "192.0.2.1", "b.example.com": "198.51.100.1"}}
+	plan.dns = records
+	plan.DNSProvider = "digitalocean"
+	plan.Domains = []string{"a.example.com", "b.example.com"}
+
+	rollbackHost(plan, "1234", "192.0.2.1", inv, io.Discard, errors.New("not serving"))
+
+	want := map[string]string{"b.example.com": "198.51.100.1"}
+	if !reflect.DeepEqual(records.records, want) {
+		t.Errorf("want only the record for the host to be removed, but got: %v", records.records)
+	}
+}
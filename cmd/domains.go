@@ -0,0 +1,258 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// validateDomain checks the syntax of a --letsencrypt-domain. Wildcards
+// are rejected as they need a DNS-01 challenge, and inlets-pro answers the
+// HTTP-01 challenge on port 80.
+func validateDomain(domain string) error {
+	if strings.Contains(domain, "*") {
+		return fmt.Errorf("--letsencrypt-domain %q is a wildcard, which needs a DNS-01 challenge, give each name instead", domain)
+	}
+	if net.ParseIP(domain) != nil {
+		return fmt.Errorf("--letsencrypt-domain %q is an IP address, Let's Encrypt only issues certificates for domain names", domain)
+	}
+
+	name := strings.TrimSuffix(domain, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return fmt.Errorf("--letsencrypt-domain %q must be between 1 and 253 characters", domain)
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("--letsencrypt-domain %q must be a fully qualified domain name, i.e. tunnel.example.com", domain)
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("--letsencrypt-domain %q has a label which is empty or longer than 63 characters", domain)
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("--letsencrypt-domain %q has a label which starts or ends with a hyphen", domain)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("--letsencrypt-domain %q may only contain letters, digits, hyphens and dots, use the punycode form of an internationalised name", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// preflightDomains warns about domains which already resolve to another
+// address, since Let's Encrypt will fail for them until their records are
+// changed. expectedIP is the address given with --public-ip or
+// --reserved-ip, and is empty when it is not known yet.
+func preflightDomains(ctx context.Context, lookup func(ctx context.Context, host string) ([]string, error), domains []string, expectedIP, dnsProvider, issuer string, progress io.Writer) {
+	for _, domain := range domains {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		addrs, err := lookup(ctx, domain)
+		cancel()
+
+		if err != nil || len(addrs) == 0 {
+			if len(dnsProvider) == 0 {
+				fmt.Fprintf(progress, "Note: %s does not resolve yet, point it at the exit-server once its IP is shown\n", domain)
+			}
+			continue
+		}
+
+		if len(expectedIP) > 0 && containsIP(addrs, expectedIP) {
+			continue
+		}
+
+		if len(dnsProvider) > 0 {
+			fmt.Fprintf(progress, "Note: %s resolves to %s, its record will be replaced with %s\n", domain, strings.Join(addrs, ", "), dnsProvider)
+			continue
+		}
+
+		msg := fmt.Sprintf("Warning: %s resolves to %s, which is not the new exit-server, so no certificate will be issued until its record is updated", domain, strings.Join(addrs, ", "))
+		if issuer == "prod" {
+			msg += ", and each failed attempt counts against Let's Encrypt's rate limits, consider --letsencrypt-issuer staging or --dns-provider"
+		}
+		fmt.Fprintln(progress, msg)
+	}
+}
+
+// domainStatus is the outcome of the checks made for a domain of a HTTPS
+// tunnel once its exit-server is active
+type domainStatus struct {
+	Domain string `json:"domain" yaml:"domain"`
+
+	// Resolves is true when the domain resolved to the exit-server's IP
+	Resolves bool `json:"resolves" yaml:"resolves"`
+
+	// Certificate is true when a valid certificate was served
+	Certificate bool   `json:"certificate" yaml:"certificate"`
+	Issuer      string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Expires     string `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Error       string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// domainChecker polls https://domain on the exit-server until it serves a
+// certificate for the domain. The fields are replaced in tests.
+type domainChecker struct {
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// roots verifies the certificate, nil uses the system's roots
+	roots *x509.CertPool
+
+	// staging certificates are not trusted, so only their name and
+	// validity period are checked
+	staging bool
+}
+
+func newDomainChecker(issuer string) *domainChecker {
+	return &domainChecker{
+		lookup:  net.DefaultResolver.LookupHost,
+		dial:    (&net.Dialer{}).DialContext,
+		staging: issuer == "staging",
+	}
+}
+
+// check waits until each domain resolves to ip and a valid certificate is
+// served for it, or the timeout is reached. The status of every domain is
+// returned, including those which failed.
+func (c *domainChecker) check(ctx context.Context, domains []string, ip string, timeout, interval time.Duration, progress io.Writer) []domainStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var statuses []domainStatus
+	for _, domain := range domains {
+		status := domainStatus{Domain: domain}
+
+		for attempt := 1; ctx.Err() == nil; attempt++ {
+			addrs, _ := c.lookup(ctx, domain)
+			status.Resolves = containsIP(addrs, ip)
+
+			cert, err := c.fetchCertificate(ctx, domain, ip)
+			if err == nil {
+				status.Certificate = true
+				status.Issuer = cert.Issuer.CommonName
+				status.Expires = cert.NotAfter.UTC().Format(time.RFC3339)
+				status.Error = ""
+				fmt.Fprintf(progress, "Certificate for %s is valid until %s, issued by %s\n", domain, status.Expires, valueOrDash(status.Issuer))
+				break
+			}
+
+			status.Error = err.Error()
+			if !status.Resolves {
+				status.Error = fmt.Sprintf("%s does not resolve to %s: %s", domain, ip, err)
+			}
+			fmt.Fprintf(progress, "[%d] Waiting for a certificate for %s: %s\n", attempt, domain, status.Error)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+
+		if !status.Certificate {
+			if len(status.Error) == 0 {
+				status.Error = "not checked, as the timeout was reached"
+			}
+			fmt.Fprintf(progress, "Warning: no valid certificate was served for %s within %s: %s\n", domain, timeout, status.Error)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// fetchCertificate requests https://domain/ from the exit-server at ip, so
+// that the result does not depend on a cached DNS answer, and returns the
+// certificate once it has been verified for domain
+func (c *domainChecker) fetchCertificate(ctx context.Context, domain, ip string) (*x509.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	config := &tls.Config{ServerName: domain, RootCAs: c.roots}
+	if c.staging {
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyStagingCertificate(state.PeerCertificates, domain, time.Now())
+		}
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return c.dial(ctx, network, net.JoinHostPort(ip, "443"))
+			},
+			TLSClientConfig: config,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+domain+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Any status shows that the certificate was accepted, the tunnel may
+	// not have a client connected yet
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.TLS == nil || len(res.TLS.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificate was presented for %s", domain)
+	}
+	return res.TLS.PeerCertificates[0], nil
+}
+
+// verifyStagingCertificate checks the name and validity period of a
+// certificate from the Let's Encrypt staging issuer, whose roots are not
+// trusted
+func verifyStagingCertificate(certs []*x509.Certificate, domain string, now time.Time) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate was presented for %s", domain)
+	}
+
+	leaf := certs[0]
+	if err := leaf.VerifyHostname(domain); err != nil {
+		return err
+	}
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("the certificate for %s is only valid from %s to %s", domain,
+			leaf.NotBefore.UTC().Format(time.RFC3339), leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// formatDomainStatuses gives "domain=expires" for each domain, with an
+// empty expiry when no valid certificate was served, for --output env
+func formatDomainStatuses(statuses []domainStatus) string {
+	parts := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		parts = append(parts, s.Domain+"="+s.Expires)
+	}
+	return strings.Join(parts, ",")
+}
+
+// containsIP reports whether ip is one of addrs
+func containsIP(addrs []string, ip string) bool {
+	want := net.ParseIP(ip)
+	for _, addr := range addrs {
+		if parsed := net.ParseIP(addr); parsed != nil && parsed.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
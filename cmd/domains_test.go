@@ -0,0 +1,161 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func Test_ValidateDomain(t *testing.T) {
+	cases := []struct {
+		domain  string
+		wantErr string
+	}{
+		{domain: "tunnel.example.com"},
+		{domain: "xn--bcher-kva.example.com."},
+		{domain: "*.example.com", wantErr: "wildcard"},
+		{domain: "192.0.2.1", wantErr: "IP address"},
+		{domain: "localhost", wantErr: "fully qualified"},
+		{domain: "a..example.com", wantErr: "empty"},
+		{domain: "-a.example.com", wantErr: "hyphen"},
+		{domain: "tunnel_1.example.com", wantErr: "may only contain"},
+		{domain: strings.Repeat("a", 64) + ".example.com", wantErr: "longer than 63"},
+	}
+
+	for _, c := range cases {
+		err := validateDomain(c.domain)
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", c.domain, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: want error containing %q, but got: %v", c.domain, c.wantErr, err)
+		}
+	}
+}
+
+func Test_PreflightDomains(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		switch host {
+		case "elsewhere.example.com":
+			return []string{"198.51.100.1"}, nil
+		case "reserved.example.com":
+			return []string{"203.0.113.10"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	domains := []string{"elsewhere.example.com", "reserved.example.com", "new.example.com"}
+
+	var progress bytes.Buffer
+	preflightDomains(context.Background(), lookup, domains, "203.0.113.10", "", "prod", &progress)
+	got := progress.String()
+
+	if !strings.Contains(got, "Warning: elsewhere.example.com resolves to 198.51.100.1") || !strings.Contains(got, "rate limits") {
+		t.Errorf("want a warning about the rate limits for elsewhere.example.com, but got: %q", got)
+	}
+	if strings.Contains(got, "reserved.example.com") {
+		t.Errorf("want no warning for a domain which already points at the reserved IP, but got: %q", got)
+	}
+	if !strings.Contains(got, "Note: new.example.com does not resolve yet") {
+		t.Errorf("want a note for new.example.com, but got: %q", got)
+	}
+
+	progress.Reset()
+	preflightDomains(context.Background(), lookup, domains, "", "cloudflare", "prod", &progress)
+	if got := progress.String(); strings.Contains(got, "Warning") || !strings.Contains(got, "will be replaced with cloudflare") {
+		t.Errorf("want notes instead of warnings with --dns-provider, but got: %q", got)
+	}
+}
+
+// makeDomainChecker points the checker at srv for every IP, and trusts
+// its certificate, which is valid for example.com
+func makeDomainChecker(srv *httptest.Server, addrs ...string) *domainChecker {
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	return &domainChecker{
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			return addrs, nil
+		},
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+		roots: roots,
+	}
+}
+
+func Test_DomainChecker_ValidCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := makeDomainChecker(srv, "192.0.2.1")
+	statuses := c.check(context.Background(), []string{"example.com"}, "192.0.2.1", time.Second, time.Millisecond, io.Discard)
+
+	if len(statuses) != 1 {
+		t.Fatalf("want 1 status, but got: %v", statuses)
+	}
+	s := statuses[0]
+	if !s.Resolves || !s.Certificate || len(s.Expires) == 0 || len(s.Error) > 0 {
+		t.Errorf("want a valid certificate with its expiry, but got: %+v", s)
+	}
+	if got := formatDomainStatuses(statuses); got != "example.com="+s.Expires {
+		t.Errorf("want example.com and its expiry for --output env, but got: %q", got)
+	}
+}
+
+func Test_DomainChecker_WrongNameTimesOut(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := makeDomainChecker(srv, "198.51.100.1")
+
+	var progress bytes.Buffer
+	statuses := c.check(context.Background(), []string{"tunnel.example.org"}, "192.0.2.1", 50*time.Millisecond, 10*time.Millisecond, &progress)
+
+	s := statuses[0]
+	if s.Resolves || s.Certificate {
+		t.Errorf("want the domain to neither resolve nor have a certificate, but got: %+v", s)
+	}
+	if !strings.Contains(s.Error, "does not resolve to 192.0.2.1") {
+		t.Errorf("want the error to say the domain points elsewhere, but got: %q", s.Error)
+	}
+	if !strings.Contains(progress.String(), "Warning: no valid certificate was served for tunnel.example.org") {
+		t.Errorf("want a warning, but got: %q", progress.String())
+	}
+}
+
+func Test_DomainChecker_UntrustedUnlessStaging(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := makeDomainChecker(srv, "192.0.2.1")
+	c.roots = x509.NewCertPool()
+
+	if _, err := c.fetchCertificate(context.Background(), "example.com", "192.0.2.1"); err == nil {
+		t.Fatalf("want an error for a certificate from an untrusted issuer")
+	}
+
+	c.staging = true
+	if _, err := c.fetchCertificate(context.Background(), "example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("want a staging certificate to be accepted, but got: %s", err)
+	}
+	if _, err := c.fetchCertificate(context.Background(), "tunnel.example.org", "192.0.2.1"); err == nil {
+		t.Fatalf("want a staging certificate for another name to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// dryRunResult is printed by create --dry-run in place of creating the
+// exit-server
+type dryRunResult struct {
+	Name     string     `json:"name" yaml:"name"`
+	Provider string     `json:"provider" yaml:"provider"`
+	Mode     string     `json:"mode" yaml:"mode"`
+	Host     dryRunHost `json:"host" yaml:"host"`
+
+	// DNSProvider would create the records for Domains
+	DNSProvider string   `json:"dns_provider,omitempty" yaml:"dns_provider,omitempty"`
+	Domains     []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+
+	// Firewall would be created with FirewallRules once the host is active
+	Firewall      string         `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+	FirewallRules []firewallRule `json:"firewall_rules,omitempty" yaml:"firewall_rules,omitempty"`
+
+	// SSHUser would log in with the keys given by --ssh-key-file and
+	// --ssh-key-name
+	SSHUser string `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty"`
+
+	UserData string `json:"user_data" yaml:"user_data"`
+}
+
+// dryRunHost is the provision.BasicHost that would have been sent to
+// the provisioner, without the encoded user-data
+type dryRunHost struct {
+	Name       string            `json:"name" yaml:"name"`
+	Region     string            `json:"region" yaml:"region"`
+	Plan       string            `json:"plan" yaml:"plan"`
+	OS         string            `json:"os" yaml:"os"`
+	Additional map[string]string `json:"additional,omitempty" yaml:"additional,omitempty"`
+}
+
+func makeDryRunResult(plan *tunnelPlan) dryRunResult {
+	return dryRunResult{
+		Name:     plan.Name,
+		Provider: plan.Provider,
+		Mode:     plan.Mode,
+		Host: dryRunHost{
+			Name:       plan.Host.Name,
+			Region:     plan.Host.Region,
+			Plan:       plan.Host.Plan,
+			OS:         plan.Host.OS,
+			Additional: plan.Host.Additional,
+		},
+		UserData:    decodeUserData(plan.Provider, plan.Host.UserData),
+		DNSProvider: plan.DNSProvider,
+		Domains:     plan.Domains,
+
+		Firewall:      plan.Firewall,
+		FirewallRules: plan.FirewallRules,
+		SSHUser:       plan.SSHUser,
+	}
+}
+
+// decodeUserData reverses the encoding that createHost applies to the
+// user-data for some providers, so that the script can be reviewed
+func decodeUserData(provider, userData string) string {
+	if provider == "ec2" {
+		if decoded, err := base64.StdEncoding.DecodeString(userData); err == nil {
+			return string(decoded)
+		}
+	}
+	return userData
+}
+
+func printDryRun(w io.Writer, result dryRunResult) {
+	fmt.Fprintf(w, "Dry-run for %s [%s], no exit-server will be created.\n\nHost request:\n", result.Name, result.Provider)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "  Name:\t%s\n", result.Host.Name)
+	fmt.Fprintf(tw, "  Region:\t%s\n", valueOrDash(result.Host.Region))
+	fmt.Fprintf(tw, "  Plan:\t%s\n", result.Host.Plan)
+	fmt.Fprintf(tw, "  OS:\t%s\n", result.Host.OS)
+	tw.Flush()
+
+	if len(result.Host.Additional) > 0 {
+		fmt.Fprintln(w, "  Additional:")
+
+		keys := make([]string, 0, len(result.Host.Additional))
+		for k := range result.Host.Additional {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(tw, "    %s:\t%s\n", k, result.Host.Additional[k])
+		}
+		tw.Flush()
+	}
+
+	if len(result.DNSProvider) > 0 {
+		fmt.Fprintf(w, "\nDNS records would be created with %s for: %s\n", result.DNSProvider, strings.Join(result.Domains, ", "))
+	}
+
+	if len(result.Firewall) > 0 {
+		fmt.Fprintf(w, "\nFirewall %s would allow TCP:\n", result.Firewall)
+		for _, rule := range result.FirewallRules {
+			fmt.Fprintf(tw, "    %s\tfrom %s\n", strings.Join(rule.Ports, ","), strings.Join(rule.Sources, ", "))
+		}
+		tw.Flush()
+	}
+
+	if len(result.SSHUser) > 0 {
+		fmt.Fprintf(w, "\nSSH login user: %s\n", result.SSHUser)
+	}
+
+	fmt.Fprintf(w, "\nUser-data:\n%s", result.UserData)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/inlets/cloud-provision/provision"
+)
+
+func Test_MakeDryRunResult_DecodesEC2UserData(t *testing.T) {
+	script := "#!/bin/bash\necho hello\n"
+	plan := &tunnelPlan{
+		Name:     "tunnel-1",
+		Provider: "ec2",
+		Mode:     "tcp",
+		Host: &provision.BasicHost{
+			Name:       "tunnel-1",
+			Region:     "eu-west-1",
+			Plan:       "t3.nano",
+			OS:         "ubuntu",
+			UserData:   base64.StdEncoding.EncodeToString([]byte(script)),
+			Additional: map[string]string{"pro": "true"},
+		},
+	}
+
+	got := makeDryRunResult(plan)
+	if got.UserData != script {
+		t.Fatalf("want decoded user-data\n%s\nbut got\n%s\n", script, got.UserData)
+	}
+	if got.Host.Plan != "t3.nano" {
+		t.Errorf("want plan t3.nano, but got: %s", got.Host.Plan)
+	}
+}
+
+func Test_DecodeUserData_PlainForOtherProviders(t *testing.T) {
+	script := "#!/bin/bash\n"
+	if got := decodeUserData("digitalocean", script); got != script {
+		t.Errorf("want user-data unchanged, but got: %q", got)
+	}
+}
+
+func Test_PrintDryRun(t *testing.T) {
+	buf := bytes.Buffer{}
+	printDryRun(&buf, dryRunResult{
+		Name:     "tunnel-1",
+		Provider: "gce",
+		Host: dryRunHost{
+			Name:       "tunnel-1",
+			Region:     "us-central1",
+			Plan:       "f1-micro",
+			OS:         "ubuntu",
+			Additional: map[string]string{"zone": "us-central1-a", "pro": "false"},
+		},
+		UserData: "#!/bin/bash\n",
+	})
+
+	want := `Dry-run for tunnel-1 [gce], no exit-server will be created.
+
+Host request:
+  Name:   tunnel-1
+  Region: us-central1
+  Plan:   f1-micro
+  OS:     ubuntu
+  Additional:
+    pro:  false
+    zone: us-central1-a
+
+User-data:
+#!/bin/bash
+`
+	if buf.String() != want {
+		t.Fatalf("want\n%s\nbut got\n%s\n", want, buf.String())
+	}
+}
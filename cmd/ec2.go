@@ -0,0 +1,283 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// ec2Provisioner extends the EC2 provisioner from cloud-provision, which
+// opens the ports given in the "ports" Additional key. Here the rules of
+// the security group are narrowed to the allowed CIDRs, the ports are
+// recorded by ec2PortTags.
+type ec2Provisioner struct {
+	*provision.EC2Provisioner
+	client *ec2.EC2
+}
+
+func newEC2Provisioner(region, accessKey, secretKey, sessionToken string) (*ec2Provisioner, error) {
+	p, err := provision.NewEC2Provisioner(region, accessKey, secretKey, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newEC2Client(region, accessKey, secretKey, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ec2Provisioner{
+		EC2Provisioner: p,
+		client:         client,
+	}, nil
+}
+
+func newEC2Client(region, accessKey, secretKey, sessionToken string) (*ec2.EC2, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, sessionToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
+
+// Provision creates the instance with cloud-provision, then restricts its
+// security group to the allowed CIDRs when they were given
+func (p *ec2Provisioner) Provision(host provision.BasicHost) (*provision.ProvisionedHost, error) {
+	res, err := p.EC2Provisioner.Provision(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasAllowCIDRs(host) {
+		controlPort, _ := strconv.Atoi(host.Additional["inlets-port"])
+		if err := p.restrictIngress(res.ID, controlPort, allowCIDRs(host, controlAllowCIDRKey), allowCIDRs(host, dataAllowCIDRKey), allowCIDRs(host, sshAllowCIDRKey)); err != nil {
+			// The instance would otherwise be open to every address
+			err = fmt.Errorf("unable to restrict the security group of instance %s: %w", res.ID, err)
+			if deleteErr := p.Delete(provision.HostDeleteRequest{ID: res.ID}); deleteErr != nil {
+				return nil, fmt.Errorf("%w (rollback failed, instance %s could not be deleted: %s, delete it with: inletsctl delete --provider ec2 --id %s)",
+					err, res.ID, deleteErr, res.ID)
+			}
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// restrictIngress replaces the rules which cloud-provision opened to every
+// address with ones for the allowed CIDRs, and opens SSH to its CIDRs. The
+// new rules are added before the old ones are removed, so that the ports
+// are never all closed.
+func (p *ec2Provisioner) restrictIngress(id string, controlPort int, control, data, ssh []string) error {
+	instances, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(instances.Reservations) == 0 || len(instances.Reservations[0].Instances) == 0 ||
+		len(instances.Reservations[0].Instances[0].SecurityGroups) == 0 {
+		return fmt.Errorf("no security group found for instance %s", id)
+	}
+	groupID := instances.Reservations[0].Instances[0].SecurityGroups[0].GroupId
+
+	groups, err := p.client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{groupID},
+	})
+	if err != nil {
+		return err
+	}
+	if len(groups.SecurityGroups) == 0 {
+		return fmt.Errorf("security group %s not found", aws.StringValue(groupID))
+	}
+
+	revoke, authorize := ec2IngressChanges(groups.SecurityGroups[0].IpPermissions, controlPort, control, data)
+	if len(ssh) > 0 {
+		authorize = append(authorize, ec2Permission(sshPort, sshPort, ssh))
+	}
+	if len(authorize) > 0 {
+		if _, err := p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       groupID,
+			IpPermissions: authorize,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(revoke) > 0 {
+		if _, err := p.client.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       groupID,
+			IpPermissions: revoke,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ec2IngressChanges gives the rules to revoke and authorize so that the
+// TCP rules open to every address are restricted. The control port is
+// given the control CIDRs, and every other range the data CIDRs. When the
+// control port is restricted it is split out of the range of high ports
+// from 1024 which includes it, port 22 is never in that range, so SSH is
+// only opened by its own rule. Rules which would not change are left alone.
+func ec2IngressChanges(perms []*ec2.IpPermission, controlPort int, control, data []string) (revoke, authorize []*ec2.IpPermission) {
+	for _, perm := range perms {
+		if aws.StringValue(perm.IpProtocol) != "tcp" {
+			continue
+		}
+		open := false
+		for _, r := range perm.IpRanges {
+			if aws.StringValue(r.CidrIp) == anyIPv4 {
+				open = true
+			}
+		}
+		if !open {
+			continue
+		}
+
+		from, to := int(aws.Int64Value(perm.FromPort)), int(aws.Int64Value(perm.ToPort))
+
+		var ranges [][2]int
+		var cidrs []string
+		if from == controlPort && to == controlPort {
+			if len(control) == 0 {
+				continue
+			}
+			ranges, cidrs = [][2]int{{from, to}}, control
+		} else {
+			var exclude []int
+			if len(control) > 0 {
+				exclude = append(exclude, controlPort)
+			}
+			ranges, cidrs = splitPortRange(from, to, exclude...), sourceRanges(data)
+			if len(data) == 0 && len(ranges) == 1 && ranges[0] == [2]int{from, to} {
+				continue
+			}
+		}
+
+		revoke = append(revoke, ec2Permission(from, to, []string{anyIPv4}))
+		for _, r := range ranges {
+			authorize = append(authorize, ec2Permission(r[0], r[1], cidrs))
+		}
+	}
+	return revoke, authorize
+}
+
+// ec2Permission gives a TCP rule for the ports from-to and the CIDRs
+func ec2Permission(from, to int, cidrs []string) *ec2.IpPermission {
+	perm := &ec2.IpPermission{
+		IpProtocol: aws.String("tcp"),
+		FromPort:   aws.Int64(int64(from)),
+		ToPort:     aws.Int64(int64(to)),
+	}
+	for _, cidr := range cidrs {
+		if strings.Contains(cidr, ":") {
+			perm.Ipv6Ranges = append(perm.Ipv6Ranges, &ec2.Ipv6Range{CidrIpv6: aws.String(cidr)})
+		} else {
+			perm.IpRanges = append(perm.IpRanges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+		}
+	}
+	return perm
+}
+
+// ec2PortTags records the ports in the inlets-ports tag of the instance
+type ec2PortTags struct {
+	client *ec2.EC2
+}
+
+func newEC2PortTags(region, accessKey, secretKey, sessionToken string) (*ec2PortTags, error) {
+	client, err := newEC2Client(region, accessKey, secretKey, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2PortTags{client: client}, nil
+}
+
+func (t *ec2PortTags) Tag(hostID string, ports []int) error {
+	_, err := t.client.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(hostID)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(portsTag), Value: aws.String(joinPorts(ports, ","))},
+		},
+	})
+	return err
+}
+
+// ec2ReservedIPs manages Elastic IPs, which are released with their
+// allocation ID, so that is looked up from the address
+type ec2ReservedIPs struct {
+	client *ec2.EC2
+}
+
+func newEC2ReservedIPs(region, accessKey, secretKey, sessionToken string) (*ec2ReservedIPs, error) {
+	client, err := newEC2Client(region, accessKey, secretKey, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2ReservedIPs{client: client}, nil
+}
+
+func (r *ec2ReservedIPs) Allocate(name, region, zone string) (string, error) {
+	res, err := r.client.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain: aws.String(ec2.DomainTypeVpc),
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeElasticIp),
+			Tags: []*ec2.Tag{
+				{Key: aws.String("Name"), Value: aws.String(name)},
+				{Key: aws.String("inlets"), Value: aws.String("exit-node")},
+			},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(res.PublicIp), nil
+}
+
+func (r *ec2ReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	allocationID, err := r.allocationID(ip)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.AssociateAddress(&ec2.AssociateAddressInput{
+		AllocationId: allocationID,
+		InstanceId:   aws.String(host.ID),
+	})
+	return err
+}
+
+func (r *ec2ReservedIPs) Release(ip, region, zone string) error {
+	allocationID, err := r.allocationID(ip)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.ReleaseAddress(&ec2.ReleaseAddressInput{AllocationId: allocationID})
+	return err
+}
+
+func (r *ec2ReservedIPs) allocationID(ip string) (*string, error) {
+	res, err := r.client.DescribeAddresses(&ec2.DescribeAddressesInput{
+		PublicIps: []*string{aws.String(ip)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Addresses) == 0 {
+		return nil, fmt.Errorf("no Elastic IP with the address %s", ip)
+	}
+	return res.Addresses[0].AllocationId, nil
+}
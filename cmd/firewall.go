@@ -0,0 +1,118 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// firewallTimeout limits how long to wait for a firewall to be removed, the
+// provider may still be detaching it from a host which is being deleted
+const firewallTimeout = 2 * time.Minute
+
+// anyAddress are the sources of a rule which is open to every address, for
+// firewalls which drop IPv6 traffic unless it is allowed too
+var anyAddress = []string{anyIPv4, "::/0"}
+
+// firewallRule allows TCP connections to the port ranges, i.e. "443" or
+// "1-8122", from the source CIDRs
+type firewallRule struct {
+	Ports   []string `json:"ports" yaml:"ports"`
+	Sources []string `json:"sources" yaml:"sources"`
+}
+
+// cloudFirewalls manages the firewall which inletsctl creates for each
+// tunnel on providers whose provisioner from cloud-provision leaves every
+// port of the host open. The firewall drops any inbound connection which
+// its rules do not allow, and allows every outbound connection.
+type cloudFirewalls interface {
+	// Create makes a firewall with the name and rules, and applies it to
+	// the host once it is active
+	Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error
+
+	// Remove deletes the firewall with the name, it is not an error when
+	// there is no such firewall
+	Remove(name string) error
+}
+
+// firewallName names the firewall of a tunnel
+func firewallName(tunnel string) string {
+	return "inlets-" + tunnel
+}
+
+// firewallRules gives the rules for the firewall of a host request, the
+// control port is open to the control CIDRs, the data ports to the data
+// CIDRs and SSH only to the CIDRs given with --ssh-allow-cidr
+func firewallRules(host provision.BasicHost) []firewallRule {
+	controlPort := host.Additional["inlets-port"]
+
+	rules := []firewallRule{
+		{Ports: []string{controlPort}, Sources: firewallSources(allowCIDRs(host, controlAllowCIDRKey))},
+		{Ports: dataPortRanges(host, controlPort), Sources: firewallSources(allowCIDRs(host, dataAllowCIDRKey))},
+	}
+	if ssh := allowCIDRs(host, sshAllowCIDRKey); len(ssh) > 0 {
+		rules = append(rules, firewallRule{Ports: []string{"22"}, Sources: ssh})
+	}
+	return rules
+}
+
+// firewallSources gives the CIDRs for a rule, or every IPv4 and IPv6
+// address when none were given
+func firewallSources(cidrs []string) []string {
+	if len(cidrs) == 0 {
+		return anyAddress
+	}
+	return cidrs
+}
+
+// splitPorts gives the first and last port of a range such as "1-8122", or
+// the port twice for a single port
+func splitPorts(ports string) (from, to string) {
+	if from, to, ok := strings.Cut(ports, "-"); ok {
+		return from, to
+	}
+	return ports, ports
+}
+
+// getFirewalls creates the firewall client for the provider
+func getFirewalls(provider string, creds providerCredentials) (cloudFirewalls, error) {
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return nil, err
+	}
+	if spec.newFirewalls == nil {
+		return nil, fmt.Errorf("firewalls are not managed for the %s provider", provider)
+	}
+	return spec.newFirewalls(creds)
+}
+
+// removeFirewall removes a tunnel's firewall, retrying until the provider
+// has detached it from the deleted host or the timeout is reached
+func removeFirewall(f cloudFirewalls, name string, timeout, poll time.Duration, progress io.Writer) error {
+	fmt.Fprintf(progress, "Removing firewall: %s\n", name)
+
+	return waitUntil(timeout, poll, func() (bool, error) {
+		if err := f.Remove(name); err != nil {
+			fmt.Fprintf(progress, "Firewall %s is not yet removed: %s\n", name, err)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// removeFirewallOrWarn removes the firewall of a tunnel whose host has been
+// deleted, where a failure is only a warning. It reports whether the
+// firewall was removed.
+func removeFirewallOrWarn(f cloudFirewalls, name, provider string, poll time.Duration, progress io.Writer) bool {
+	if err := removeFirewall(f, name, firewallTimeout, poll, progress); err != nil {
+		fmt.Fprintf(progress, "Warning: unable to remove firewall %s, remove it from the %s console: %s\n", name, provider, err)
+		return false
+	}
+	return true
+}
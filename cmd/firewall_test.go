@@ -0,0 +1,156 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+)
+
+type fakeFirewalls struct {
+	created map[string][]firewallRule
+	removed []string
+
+	// removeFailures is the number of calls to Remove which fail
+	removeFailures int
+}
+
+func (f *fakeFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	f.created[name] = rules
+	return nil
+}
+
+func (f *fakeFirewalls) Remove(name string) error {
+	if f.removeFailures > 0 {
+		f.removeFailures--
+		return errors.New("firewall is still attached")
+	}
+	f.removed = append(f.removed, name)
+	return nil
+}
+
+func Test_FirewallRules_HTTPS(t *testing.T) {
+	host := provision.BasicHost{Additional: map[string]string{
+		"inlets-port": "8123",
+		"pro":         "false",
+	}}
+
+	want := []firewallRule{
+		{Ports: []string{"8123"}, Sources: anyAddress},
+		{Ports: []string{"80", "443"}, Sources: anyAddress},
+	}
+	if got := firewallRules(host); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+}
+
+func Test_FirewallRules_AllowCIDRs(t *testing.T) {
+	host := provision.BasicHost{Additional: map[string]string{
+		"inlets-port":       "8123",
+		"pro":               "true",
+		controlAllowCIDRKey: "203.0.113.10/32",
+		sshAllowCIDRKey:     "198.51.100.0/24,2001:db8::/32",
+	}}
+
+	want := []firewallRule{
+		{Ports: []string{"8123"}, Sources: []string{"203.0.113.10/32"}},
+		{Ports: []string{"1-21", "23-8122", "8124-65535"}, Sources: anyAddress},
+		{Ports: []string{"22"}, Sources: []string{"198.51.100.0/24", "2001:db8::/32"}},
+	}
+	if got := firewallRules(host); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+}
+
+func Test_FirewallRules_NoSSHAllowCIDRs(t *testing.T) {
+	host := provision.BasicHost{Additional: map[string]string{
+		"inlets-port":    "8123",
+		"pro":            "true",
+		dataAllowCIDRKey: "198.51.100.0/24",
+	}}
+
+	// Port 22 is closed, as no rule covers it
+	want := []firewallRule{
+		{Ports: []string{"8123"}, Sources: anyAddress},
+		{Ports: []string{"1-21", "23-8122", "8124-65535"}, Sources: []string{"198.51.100.0/24"}},
+	}
+	if got := firewallRules(host); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+}
+
+func Test_SplitPorts(t *testing.T) {
+	if from, to := splitPorts("1-8122"); from != "1" || to != "8122" {
+		t.Errorf("want 1 and 8122, but got %s and %s", from, to)
+	}
+	if from, to := splitPorts("443"); from != "443" || to != "443" {
+		t.Errorf("want 443 twice, but got %s and %s", from, to)
+	}
+}
+
+func Test_CreateHost_Firewall(t *testing.T) {
+	host, err := createHost("digitalocean", hostOptions{
+		Name:          "tunnel-1",
+		Arch:          "amd64",
+		ControlPort:   "8123",
+		TCP:           true,
+		TCPPorts:      []int{5432},
+		SSHAllowCIDRs: []string{"203.0.113.10/32"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"firewall-name":  "inlets-tunnel-1",
+		"inlets-port":    "8123",
+		"pro":            "true",
+		"ports":          "5432",
+		sshAllowCIDRKey:  "203.0.113.10/32",
+		dataAllowCIDRKey: "",
+	}
+	for k, v := range want {
+		if host.Additional[k] != v {
+			t.Errorf("want Additional[%s] %q, but got %q", k, v, host.Additional[k])
+		}
+	}
+}
+
+func Test_RemoveFirewall_Retries(t *testing.T) {
+	f := &fakeFirewalls{removeFailures: 2}
+
+	var progress bytes.Buffer
+	if !removeFirewallOrWarn(f, "inlets-tunnel-1", "hetzner", time.Millisecond, &progress) {
+		t.Fatalf("want the firewall to be removed, but got: %q", progress.String())
+	}
+	if !reflect.DeepEqual(f.removed, []string{"inlets-tunnel-1"}) {
+		t.Errorf("want the firewall removed once, but got: %v", f.removed)
+	}
+	if !strings.Contains(progress.String(), "Firewall inlets-tunnel-1 is not yet removed") {
+		t.Errorf("want the retries to be reported, but got: %q", progress.String())
+	}
+}
+
+func Test_RollbackHost_RemovesFirewall(t *testing.T) {
+	p := &fakeProvisioner{}
+	plan, inv := makeRollbackTest(t, p)
+
+	f := &fakeFirewalls{created: map[string][]firewallRule{}}
+	plan.firewalls = f
+	plan.Firewall = "inlets-tunnel-1"
+	plan.Poll = time.Millisecond
+
+	rollbackHost(plan, "1234", "192.0.2.1", inv, io.Discard, errors.New("not serving"))
+
+	if !reflect.DeepEqual(f.removed, []string{"inlets-tunnel-1"}) {
+		t.Errorf("want the firewall to be removed, but got: %v", f.removed)
+	}
+}
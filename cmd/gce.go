@@ -0,0 +1,380 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// gceProvisioner extends the GCE provisioner from cloud-provision, whose
+// firewall rule applies to every exit-server in the project and opens all
+// TCP ports for a TCP tunnel. When the "ports" Additional key or an allowed
+// CIDR is given, the instance gets rules of its own, one for the control
+// port and one for the data ports, each open to its allowed CIDRs. The
+// ports are recorded in the inlets-ports label.
+type gceProvisioner struct {
+	*provision.GCEProvisioner
+	service *compute.Service
+
+	// pending holds the instances which are waiting for their network tag
+	// and label, which can only be set once they are running
+	pending map[string][]string
+}
+
+func newGCEProvisioner(accessKey string) (*gceProvisioner, error) {
+	p, err := provision.NewGCEProvisioner(accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := newComputeService(accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gceProvisioner{
+		GCEProvisioner: p,
+		service:        service,
+		pending:        map[string][]string{},
+	}, nil
+}
+
+func newComputeService(accessKey string) (*compute.Service, error) {
+	return compute.NewService(context.Background(), option.WithCredentialsJSON([]byte(accessKey)))
+}
+
+// gcePortsRule names the firewall rule and network tag for an instance
+func gcePortsRule(instanceName string) string {
+	return "inlets-" + instanceName
+}
+
+// gceControlRule names the firewall rule for the control port of an
+// instance, which targets the same network tag as gcePortsRule
+func gceControlRule(instanceName string) string {
+	return gcePortsRule(instanceName) + "-control"
+}
+
+// gceSSHRule names the firewall rule which opens SSH to an instance
+func gceSSHRule(instanceName string) string {
+	return gcePortsRule(instanceName) + "-ssh"
+}
+
+// gceSharedTags are given to every instance by cloud-provision, they match
+// the shared "inlets" rule and the default network's rules for HTTP and
+// HTTPS, so they are removed from an instance with rules of its own
+var gceSharedTags = []string{"http-server", "https-server", "inlets"}
+
+// Provision creates the instance with cloud-provision, then narrows its
+// firewall rule to the data ports and adds a rule for the control port.
+// The rule is named by customiseHost, so that the rule shared by other
+// exit-servers in the project is not changed.
+func (p *gceProvisioner) Provision(host provision.BasicHost) (*provision.ProvisionedHost, error) {
+	ports := host.Additional["ports"]
+	if len(ports) == 0 && !hasAllowCIDRs(host) {
+		return p.GCEProvisioner.Provision(host)
+	}
+
+	projectID := host.Additional["projectid"]
+	rule := host.Additional["firewall-name"]
+	controlPort := host.Additional["firewall-port"]
+
+	res, err := p.GCEProvisioner.Provision(host)
+	if err != nil {
+		p.deleteFirewall(projectID, rule)
+		return nil, err
+	}
+
+	// The instance would otherwise be left behind, since no ID is returned
+	fail := func(name string, err error) (*provision.ProvisionedHost, error) {
+		err = fmt.Errorf("unable to update firewall rule %s in project: %s error: %w", name, projectID, err)
+		if deleteErr := p.Delete(provision.HostDeleteRequest{ID: res.ID, ProjectID: projectID}); deleteErr != nil {
+			return nil, fmt.Errorf("%w (rollback failed, instance %s could not be deleted: %s, delete it with: inletsctl delete --provider gce --id %s)",
+				err, res.ID, deleteErr, res.ID)
+		}
+		return nil, err
+	}
+
+	if _, err := p.service.Firewalls.Patch(projectID, rule, &compute.Firewall{
+		Description: "TCP ports for the inlets exit-server " + host.Name,
+		Allowed: []*compute.FirewallAllowed{{
+			IPProtocol: "tcp",
+			Ports:      dataPortRanges(host, controlPort),
+		}},
+		SourceRanges: sourceRanges(allowCIDRs(host, dataAllowCIDRKey)),
+		TargetTags:   []string{rule},
+	}).Do(); err != nil {
+		return fail(rule, err)
+	}
+
+	control := gceControlRule(host.Name)
+	if _, err := p.service.Firewalls.Insert(projectID, &compute.Firewall{
+		Name:        control,
+		Description: "Control port for the inlets exit-server " + host.Name,
+		Allowed: []*compute.FirewallAllowed{{
+			IPProtocol: "tcp",
+			Ports:      []string{controlPort},
+		}},
+		SourceRanges: sourceRanges(allowCIDRs(host, controlAllowCIDRKey)),
+		TargetTags:   []string{rule},
+	}).Do(); err != nil {
+		return fail(control, err)
+	}
+
+	// The default network's default-allow-ssh rule applies to every
+	// instance, so this rule only adds to it when that rule exists
+	if ssh := allowCIDRs(host, sshAllowCIDRKey); len(ssh) > 0 {
+		name := gceSSHRule(host.Name)
+		if _, err := p.service.Firewalls.Insert(projectID, &compute.Firewall{
+			Name:        name,
+			Description: "SSH for the inlets exit-server " + host.Name,
+			Allowed: []*compute.FirewallAllowed{{
+				IPProtocol: "tcp",
+				Ports:      []string{"22"},
+			}},
+			SourceRanges: ssh,
+			TargetTags:   []string{rule},
+		}).Do(); err != nil {
+			return fail(name, err)
+		}
+	}
+
+	var pending []string
+	if len(ports) > 0 {
+		pending = strings.Split(ports, ",")
+	}
+	p.pending[res.ID] = pending
+	return res, nil
+}
+
+// Status gives the status from cloud-provision, once the instance is
+// running its shared network tags are replaced with its own, and its
+// label is added
+func (p *gceProvisioner) Status(id string) (*provision.ProvisionedHost, error) {
+	res, err := p.GCEProvisioner.Status(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ports, ok := p.pending[id]
+	if !ok || res.Status != provision.ActiveStatus {
+		return res, nil
+	}
+
+	name, zone, projectID, _ := splitGCEID(id)
+	instance, err := p.service.Instances.Get(projectID, zone, name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := &compute.Tags{Items: []string{gcePortsRule(name)}}
+	if instance.Tags != nil {
+		tags.Fingerprint = instance.Tags.Fingerprint
+		for _, tag := range instance.Tags.Items {
+			if !contains(gceSharedTags, tag) && tag != gcePortsRule(name) {
+				tags.Items = append(tags.Items, tag)
+			}
+		}
+	}
+	if _, err := p.service.Instances.SetTags(projectID, zone, name, tags).Do(); err != nil {
+		return nil, fmt.Errorf("unable to add network tag to instance %s: %w", name, err)
+	}
+
+	if len(ports) > 0 {
+		// Label values cannot contain commas
+		labels := map[string]string{portsTag: strings.Join(ports, "-")}
+		for k, v := range instance.Labels {
+			labels[k] = v
+		}
+		if _, err := p.service.Instances.SetLabels(projectID, zone, name, &compute.InstancesSetLabelsRequest{
+			Labels:           labels,
+			LabelFingerprint: instance.LabelFingerprint,
+		}).Do(); err != nil {
+			return nil, fmt.Errorf("unable to add label to instance %s: %w", name, err)
+		}
+	}
+
+	delete(p.pending, id)
+	return res, nil
+}
+
+// Delete deletes the instance with cloud-provision, then its firewall rules
+// when it has them
+func (p *gceProvisioner) Delete(request provision.HostDeleteRequest) error {
+	id := request.ID
+	if len(id) == 0 {
+		hosts, err := p.List(provision.ListFilter{
+			Filter:    "labels.inlets=exit-node",
+			ProjectID: request.ProjectID,
+			Zone:      request.Zone,
+			Region:    request.Region,
+		})
+		if err != nil {
+			return err
+		}
+		for _, host := range hosts {
+			if host.IP == request.IP {
+				id = host.ID
+			}
+		}
+	}
+
+	if err := p.GCEProvisioner.Delete(request); err != nil {
+		return err
+	}
+
+	name, _, projectID, _ := splitGCEID(id)
+	if len(request.ProjectID) > 0 {
+		projectID = request.ProjectID
+	}
+	if len(name) == 0 || len(projectID) == 0 {
+		return nil
+	}
+	for _, rule := range []string{gceControlRule(name), gceSSHRule(name)} {
+		if err := p.deleteFirewall(projectID, rule); err != nil {
+			return err
+		}
+	}
+	return p.deleteFirewall(projectID, gcePortsRule(name))
+}
+
+// deleteFirewall deletes a firewall rule, a rule which does not exist is
+// not an error
+func (p *gceProvisioner) deleteFirewall(projectID, name string) error {
+	_, err := p.service.Firewalls.Delete(projectID, name).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// splitGCEID gives the instance name, zone, project and region from the
+// ID given to GCE hosts by cloud-provision
+func splitGCEID(id string) (name, zone, projectID, region string) {
+	fields := strings.Split(id, "|")
+	if len(fields) != 4 {
+		return "", "", "", ""
+	}
+	return fields[0], fields[1], fields[2], fields[3]
+}
+
+// gceReservedIPs manages static external addresses. cloud-provision gives
+// each instance an ephemeral address, which is swapped for the static one
+// once the instance is running.
+type gceReservedIPs struct {
+	service   *compute.Service
+	projectID string
+}
+
+func newGCEReservedIPs(accessKey, projectID string) (*gceReservedIPs, error) {
+	service, err := newComputeService(accessKey)
+	if err != nil {
+		return nil, err
+	}
+	return &gceReservedIPs{service: service, projectID: projectID}, nil
+}
+
+func (r *gceReservedIPs) Allocate(name, region, zone string) (string, error) {
+	region = gceRegion(region, zone)
+
+	op, err := r.service.Addresses.Insert(r.projectID, region, &compute.Address{
+		Name:        gcePortsRule(name),
+		Description: "Reserved IP for the inlets exit-server " + name,
+		Labels:      map[string]string{"inlets": "exit-node"},
+	}).Do()
+	if err != nil {
+		return "", err
+	}
+	if err := gceOperationErr(r.service.RegionOperations.Wait(r.projectID, region, op.Name).Do()); err != nil {
+		return "", err
+	}
+
+	address, err := r.service.Addresses.Get(r.projectID, region, gcePortsRule(name)).Do()
+	if err != nil {
+		return "", err
+	}
+	return address.Address, nil
+}
+
+// Attach replaces the instance's ephemeral access config with one for the
+// static address
+func (r *gceReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	name, zone, projectID, _ := splitGCEID(host.ID)
+
+	instance, err := r.service.Instances.Get(projectID, zone, name).Do()
+	if err != nil {
+		return err
+	}
+	if len(instance.NetworkInterfaces) == 0 {
+		return fmt.Errorf("instance %s has no network interface", name)
+	}
+	nic := instance.NetworkInterfaces[0]
+
+	for _, config := range nic.AccessConfigs {
+		op, err := r.service.Instances.DeleteAccessConfig(projectID, zone, name, config.Name, nic.Name).Do()
+		if err != nil {
+			return err
+		}
+		if err := gceOperationErr(r.service.ZoneOperations.Wait(projectID, zone, op.Name).Do()); err != nil {
+			return err
+		}
+	}
+
+	op, err := r.service.Instances.AddAccessConfig(projectID, zone, name, nic.Name, &compute.AccessConfig{
+		Name:  "External NAT",
+		Type:  "ONE_TO_ONE_NAT",
+		NatIP: ip,
+	}).Do()
+	if err != nil {
+		return err
+	}
+	return gceOperationErr(r.service.ZoneOperations.Wait(projectID, zone, op.Name).Do())
+}
+
+func (r *gceReservedIPs) Release(ip, region, zone string) error {
+	region = gceRegion(region, zone)
+
+	addresses, err := r.service.Addresses.List(r.projectID, region).Filter(fmt.Sprintf("address=%q", ip)).Do()
+	if err != nil {
+		return err
+	}
+	if len(addresses.Items) == 0 {
+		return fmt.Errorf("no static address %s in %s", ip, region)
+	}
+
+	_, err = r.service.Addresses.Delete(r.projectID, region, addresses.Items[0].Name).Do()
+	return err
+}
+
+// gceOperationErr gives the error of an operation which has been waited
+// for, the request itself succeeds when the operation fails
+func gceOperationErr(op *compute.Operation, err error) error {
+	if err != nil {
+		return err
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return errors.New(op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+// gceRegion gives the region, or the region of the zone when it is not
+// set, i.e. us-central1 for us-central1-a
+func gceRegion(region, zone string) string {
+	if len(region) > 0 {
+		return region
+	}
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
@@ -0,0 +1,258 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// hetznerProvisioner extends the Hetzner provisioner from cloud-provision,
+// which looks up the OS image by name alone and so always gets the x86
+// build. Here the image is looked up for the architecture of the plan, so
+// that arm64 (CAX) server types can be used. The ports of a TCP tunnel
+// are recorded in the inlets-ports label, and a primary IP given in the
+// "primary-ip" Additional key is assigned to the server, as is the SSH key
+// named by the "ssh-key" key.
+type hetznerProvisioner struct {
+	*provision.HetznerProvisioner
+	client *hcloud.Client
+}
+
+func newHetznerProvisioner(accessToken string) (*hetznerProvisioner, error) {
+	p, err := provision.NewHetznerProvisioner(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hetznerProvisioner{
+		HetznerProvisioner: p,
+		client:             hcloud.NewClient(hcloud.WithToken(accessToken)),
+	}, nil
+}
+
+// Provision creates a server in the same way as cloud-provision, with the
+// image for the plan's architecture
+func (p *hetznerProvisioner) Provision(host provision.BasicHost) (*provision.ProvisionedHost, error) {
+	ctx := context.Background()
+
+	plan, _, err := p.client.ServerType.GetByName(ctx, host.Plan)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("no Hetzner server type named %q", host.Plan)
+	}
+
+	image, _, err := p.client.Image.GetByNameAndArchitecture(ctx, host.OS, plan.Architecture)
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, fmt.Errorf("no Hetzner image named %q for %s", host.OS, plan.Architecture)
+	}
+
+	location, _, err := p.client.Location.GetByName(ctx, host.Region)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		return nil, fmt.Errorf("no Hetzner location named %q", host.Region)
+	}
+
+	labels := map[string]string{
+		"managed-by": "inlets",
+	}
+	// Label values cannot contain commas
+	if ports := host.Additional["ports"]; len(ports) > 0 {
+		labels[portsTag] = strings.ReplaceAll(ports, ",", "-")
+	}
+
+	// A primary IP can only be assigned to a server which is powered off,
+	// so it is given when the server is created
+	var publicNet *hcloud.ServerCreatePublicNet
+	if ip := host.Additional["primary-ip"]; len(ip) > 0 {
+		primaryIP, _, err := p.client.PrimaryIP.GetByIP(ctx, ip)
+		if err != nil {
+			return nil, err
+		}
+		if primaryIP == nil {
+			return nil, fmt.Errorf("no Hetzner primary IP with the address %s", ip)
+		}
+		publicNet = &hcloud.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true, IPv4: primaryIP}
+	}
+
+	var keys []*hcloud.SSHKey
+	if name := host.Additional["ssh-key"]; len(name) > 0 {
+		key, _, err := p.client.SSHKey.GetByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("no Hetzner SSH key named %q", name)
+		}
+		keys = append(keys, key)
+	}
+
+	res, _, err := p.client.Server.Create(ctx, hcloud.ServerCreateOpts{
+		Name:             host.Name,
+		ServerType:       plan,
+		Image:            image,
+		Location:         location,
+		UserData:         host.UserData,
+		StartAfterCreate: hcloud.Bool(true),
+		Labels:           labels,
+		PublicNet:        publicNet,
+		SSHKeys:          keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &provision.ProvisionedHost{
+		IP:     res.Server.PublicNet.IPv4.IP.String(),
+		ID:     strconv.Itoa(res.Server.ID),
+		Status: "creating",
+	}, nil
+}
+
+// hetznerReservedIPs manages Hetzner primary IPs, which are kept when the
+// server is deleted. They are assigned by hetznerProvisioner when the server
+// is created, so Attach has nothing to do.
+type hetznerReservedIPs struct {
+	client *hcloud.Client
+}
+
+func newHetznerReservedIPs(accessToken string) *hetznerReservedIPs {
+	return &hetznerReservedIPs{client: hcloud.NewClient(hcloud.WithToken(accessToken))}
+}
+
+// Allocate creates a primary IP in the first datacenter of the location,
+// which is where cloud-provision places the server
+func (r *hetznerReservedIPs) Allocate(name, region, zone string) (string, error) {
+	ctx := context.Background()
+
+	datacenters, err := r.client.Datacenter.All(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var datacenter string
+	for _, dc := range datacenters {
+		if dc.Location != nil && dc.Location.Name == region {
+			datacenter = dc.Name
+			break
+		}
+	}
+	if len(datacenter) == 0 {
+		return "", fmt.Errorf("no Hetzner datacenter in the location %q", region)
+	}
+
+	res, _, err := r.client.PrimaryIP.Create(ctx, hcloud.PrimaryIPCreateOpts{
+		Name:         "inlets-" + name,
+		Type:         hcloud.PrimaryIPTypeIPv4,
+		AssigneeType: "server",
+		Datacenter:   datacenter,
+		AutoDelete:   hcloud.Bool(false),
+		Labels:       map[string]string{"managed-by": "inlets"},
+	})
+	if err != nil {
+		return "", err
+	}
+	return res.PrimaryIP.IP.String(), nil
+}
+
+func (r *hetznerReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	return nil
+}
+
+func (r *hetznerReservedIPs) Release(ip, region, zone string) error {
+	ctx := context.Background()
+
+	primaryIP, _, err := r.client.PrimaryIP.GetByIP(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if primaryIP == nil {
+		return fmt.Errorf("no Hetzner primary IP with the address %s", ip)
+	}
+	if primaryIP.AssigneeID != 0 {
+		return fmt.Errorf("primary IP %s is still assigned to server %d", ip, primaryIP.AssigneeID)
+	}
+
+	_, err = r.client.PrimaryIP.Delete(ctx, primaryIP)
+	return err
+}
+
+// hetznerFirewalls manages Hetzner firewalls, which allow every outbound
+// connection when they have no outbound rules
+type hetznerFirewalls struct {
+	client *hcloud.Client
+}
+
+func newHetznerFirewalls(accessToken string) *hetznerFirewalls {
+	return &hetznerFirewalls{client: hcloud.NewClient(hcloud.WithToken(accessToken))}
+}
+
+func (f *hetznerFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	serverID, err := strconv.Atoi(host.ID)
+	if err != nil {
+		return fmt.Errorf("invalid server ID %q: %w", host.ID, err)
+	}
+
+	var inbound []hcloud.FirewallRule
+	for _, rule := range rules {
+		var sources []net.IPNet
+		for _, cidr := range rule.Sources {
+			_, source, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, *source)
+		}
+
+		for _, ports := range rule.Ports {
+			inbound = append(inbound, hcloud.FirewallRule{
+				Direction: hcloud.FirewallRuleDirectionIn,
+				SourceIPs: sources,
+				Protocol:  hcloud.FirewallRuleProtocolTCP,
+				Port:      hcloud.String(ports),
+			})
+		}
+	}
+
+	_, _, err = f.client.Firewall.Create(context.Background(), hcloud.FirewallCreateOpts{
+		Name:   name,
+		Labels: map[string]string{"managed-by": "inlets"},
+		Rules:  inbound,
+		ApplyTo: []hcloud.FirewallResource{{
+			Type:   hcloud.FirewallResourceTypeServer,
+			Server: &hcloud.FirewallResourceServer{ID: serverID},
+		}},
+	})
+	return err
+}
+
+// Remove deletes the firewall, which Hetzner only allows once it is no
+// longer applied to a server
+func (f *hetznerFirewalls) Remove(name string) error {
+	ctx := context.Background()
+
+	firewall, _, err := f.client.Firewall.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if firewall == nil {
+		return nil
+	}
+
+	_, err = f.client.Firewall.Delete(ctx, firewall)
+	return err
+}
@@ -0,0 +1,213 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/linode/linodego"
+	"golang.org/x/oauth2"
+)
+
+// linodeReservedIPs manages Linode reserved IPs. An address assigned to a
+// running Linode is only configured by Network Helper when it boots, so the
+// Linode is rebooted once the address has been assigned.
+type linodeReservedIPs struct {
+	client linodego.Client
+}
+
+func newLinodeReservedIPs(accessToken string) *linodeReservedIPs {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &linodeReservedIPs{
+		client: linodego.NewClient(&http.Client{Transport: &oauth2.Transport{Source: tokenSource}}),
+	}
+}
+
+func (r *linodeReservedIPs) Allocate(name, region, zone string) (string, error) {
+	ip, err := r.client.ReserveIPAddress(context.Background(), linodego.ReserveIPOptions{Region: region})
+	if err != nil {
+		return "", err
+	}
+	return ip.Address, nil
+}
+
+func (r *linodeReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	ctx := context.Background()
+
+	linodeID, err := strconv.Atoi(host.ID)
+	if err != nil {
+		return fmt.Errorf("invalid Linode ID %q: %w", host.ID, err)
+	}
+
+	reserved, err := r.client.GetReservedIPAddress(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	if err := r.client.InstancesAssignIPs(ctx, linodego.LinodesAssignIPsOptions{
+		Region:      reserved.Region,
+		Assignments: []linodego.LinodeIPAssignment{{Address: ip, LinodeID: linodeID}},
+	}); err != nil {
+		return err
+	}
+
+	return r.client.RebootInstance(ctx, linodeID, 0)
+}
+
+func (r *linodeReservedIPs) Release(ip, region, zone string) error {
+	return r.client.DeleteReservedIPAddress(context.Background(), ip)
+}
+
+// linodeFirewalls manages Linode cloud firewalls, whose labels are at most
+// 32 characters, so a longer name is truncated
+type linodeFirewalls struct {
+	client linodego.Client
+}
+
+func newLinodeFirewalls(accessToken string) *linodeFirewalls {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &linodeFirewalls{
+		client: linodego.NewClient(&http.Client{Transport: &oauth2.Transport{Source: tokenSource}}),
+	}
+}
+
+// linodeFirewallLabel gives the label of the firewall with the name
+func linodeFirewallLabel(name string) string {
+	if len(name) > 32 {
+		return name[:32]
+	}
+	return name
+}
+
+func (f *linodeFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	linodeID, err := strconv.Atoi(host.ID)
+	if err != nil {
+		return fmt.Errorf("invalid Linode ID %q: %w", host.ID, err)
+	}
+
+	var inbound []linodego.FirewallRule
+	for i, rule := range rules {
+		var ipv4, ipv6 []string
+		for _, cidr := range rule.Sources {
+			if strings.Contains(cidr, ":") {
+				ipv6 = append(ipv6, cidr)
+			} else {
+				ipv4 = append(ipv4, cidr)
+			}
+		}
+
+		addresses := linodego.NetworkAddresses{}
+		if len(ipv4) > 0 {
+			addresses.IPv4 = &ipv4
+		}
+		if len(ipv6) > 0 {
+			addresses.IPv6 = &ipv6
+		}
+
+		inbound = append(inbound, linodego.FirewallRule{
+			Action:    "ACCEPT",
+			Label:     fmt.Sprintf("inlets-%d", i+1),
+			Ports:     strings.Join(rule.Ports, ","),
+			Protocol:  linodego.TCP,
+			Addresses: addresses,
+		})
+	}
+
+	_, err = f.client.CreateFirewall(context.Background(), linodego.FirewallCreateOptions{
+		Label: linodeFirewallLabel(name),
+		Rules: linodego.FirewallRuleSet{
+			Inbound:        inbound,
+			InboundPolicy:  "DROP",
+			OutboundPolicy: "ACCEPT",
+		},
+		Tags:    []string{"inlets"},
+		Devices: linodego.DevicesCreationOptions{Linodes: []int{linodeID}},
+	})
+	return err
+}
+
+func (f *linodeFirewalls) Remove(name string) error {
+	ctx := context.Background()
+
+	label := linodeFirewallLabel(name)
+	firewalls, err := f.client.ListFirewalls(ctx, linodego.NewListOptions(0, fmt.Sprintf(`{"label": %q}`, label)))
+	if err != nil {
+		return err
+	}
+	for _, firewall := range firewalls {
+		if firewall.Label == label {
+			if err := f.client.DeleteFirewall(ctx, firewall.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// linodeTagLength is the longest tag that Linode accepts
+const linodeTagLength = 50
+
+// linodePortTags adds an inlets-ports tag to the tags of the Linode
+type linodePortTags struct {
+	client linodego.Client
+}
+
+func newLinodePortTags(accessToken string) *linodePortTags {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &linodePortTags{
+		client: linodego.NewClient(&http.Client{Transport: &oauth2.Transport{Source: tokenSource}}),
+	}
+}
+
+func (t *linodePortTags) Tag(hostID string, ports []int) error {
+	ctx := context.Background()
+
+	linodeID, err := strconv.Atoi(hostID)
+	if err != nil {
+		return fmt.Errorf("invalid Linode ID %q: %w", hostID, err)
+	}
+
+	tag := portsTagValue(ports)
+	if len(tag) > linodeTagLength {
+		return fmt.Errorf("%s is longer than the %d characters of a Linode tag", tag, linodeTagLength)
+	}
+
+	instance, err := t.client.GetInstance(ctx, linodeID)
+	if err != nil {
+		return err
+	}
+	tags := append(instance.Tags, tag)
+	_, err = t.client.UpdateInstance(ctx, linodeID, linodego.InstanceUpdateOptions{Tags: &tags})
+	return err
+}
+
+// linodeSSHKeys looks up the keys of a Linode user's profile by label
+type linodeSSHKeys struct {
+	client linodego.Client
+}
+
+func newLinodeSSHKeys(accessToken string) *linodeSSHKeys {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	return &linodeSSHKeys{
+		client: linodego.NewClient(&http.Client{Transport: &oauth2.Transport{Source: tokenSource}}),
+	}
+}
+
+func (k *linodeSSHKeys) Get(name string) (string, error) {
+	keys, err := k.client.ListSSHKeys(context.Background(), linodego.NewListOptions(0, fmt.Sprintf(`{"label": %q}`, name)))
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.Label == name {
+			return key.SSHKey, nil
+		}
+	}
+	return "", fmt.Errorf("no Linode SSH key labelled %q", name)
+}
@@ -0,0 +1,214 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	inletsCmd.AddCommand(listCmd)
+	listCmd.Flags().StringP("provider", "p", "digitalocean", "The cloud provider - digitalocean, gce, ec2, scaleway, hetzner or vultr")
+	listCmd.Flags().StringP("region", "r", "lon1", "The region for your cloud provider")
+	listCmd.Flags().StringP("zone", "z", "us-central1-a", "The zone for the exit-servers (gce)")
+
+	listCmd.Flags().StringP("access-token", "a", "", "The access token for your cloud")
+	listCmd.Flags().StringP("access-token-file", "f", "", "Read this file for the access token for your cloud")
+	listCmd.Flags().String("access-token-cmd", "", "Run this command and read the access token for your cloud from its output, i.e. \"pass show inlets/do-token\"")
+
+	listCmd.Flags().String("secret-key", "", "The secret key for your cloud (scaleway, ec2)")
+	listCmd.Flags().String("secret-key-file", "", "Read this file for the secret key for your cloud (scaleway, ec2)")
+	listCmd.Flags().String("secret-key-cmd", "", "Run this command and read the secret key for your cloud from its output (scaleway, ec2)")
+	listCmd.Flags().String("session-token", "", "The session token for ec2 (when using with temporary credentials)")
+	listCmd.Flags().String("session-token-file", "", "Read this file for the session token for ec2 (when using with temporary credentials)")
+	listCmd.Flags().String("session-token-cmd", "", "Run this command and read the session token for ec2 from its output (when using with temporary credentials)")
+
+	listCmd.Flags().String("organisation-id", "", "Organisation ID (scaleway)")
+	listCmd.Flags().String("project-id", "", "Project ID (gce)")
+
+	listCmd.Flags().StringP("output", "o", "table", "Output format - table or json")
+}
+
+// listCmd represents the list sub command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List exit-servers",
+	Long: `List the exit-servers created by inletsctl for a cloud provider, using
+the tags or labels that were applied when each host was created. Names
+are taken from the local inventory, see also: inletsctl show.
+
+Listing is not available for linode, azure or ovh yet.`,
+	Example: `  inletsctl list --provider digitalocean
+  inletsctl list --provider ec2 --region eu-west-1 --output json
+  inletsctl list --provider gce --project-id my-project --zone us-central1-a
+`,
+	RunE:          runList,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+// hostLister is implemented by the provisioners which are able to find
+// the exit-servers they created. It is not part of provision.Provisioner
+// since not every provider supports it.
+type hostLister interface {
+	List(provision.ListFilter) ([]*provision.ProvisionedHost, error)
+}
+
+// listedHost is a row in the output of inletsctl list
+type listedHost struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	IP     string `json:"ip"`
+	Region string `json:"region"`
+	Status string `json:"status"`
+}
+
+func runList(cmd *cobra.Command, _ []string) error {
+	provider, err := cmd.Flags().GetString("provider")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'provider' value.")
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'output' value.")
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("--output must be table or json")
+	}
+
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return err
+	}
+
+	filter, err := getListFilter(provider)
+	if err != nil {
+		return err
+	}
+
+	region := providerRegion(cmd.Flags(), spec)
+	zone := providerZone(cmd.Flags(), spec)
+
+	creds, err := readCredentials(cmd.Flags(), spec, region, true)
+	if err != nil {
+		return err
+	}
+	creds.report(os.Stderr)
+	if err := creds.check(provider, spec.RequiredFlags); err != nil {
+		return err
+	}
+
+	if provider == "gce" {
+		if err := creds.check(provider, []string{"project-id"}); err != nil {
+			return err
+		}
+		filter.ProjectID = creds.ProjectID
+		filter.Zone = zone
+		filter.Region = region
+	}
+
+	provisioner, err := getProvisioner(provider, creds)
+	if err != nil {
+		return err
+	}
+
+	lister, ok := provisioner.(hostLister)
+	if !ok {
+		return fmt.Errorf("listing exit-servers is not supported by the %s provider yet", provider)
+	}
+
+	hosts, err := lister.List(filter)
+	if err != nil {
+		return err
+	}
+
+	// The inventory is only used to fill in names, so it is not an error
+	// when it cannot be read.
+	inv, err := loadInventory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to read the inventory: %s\n", err)
+	}
+
+	listed := make([]listedHost, 0, len(hosts))
+	for _, host := range hosts {
+		item := listedHost{
+			ID:     host.ID,
+			IP:     host.IP,
+			Status: host.Status,
+		}
+
+		// DigitalOcean, Hetzner and Vultr list hosts across all regions
+		// and do not report which one each host is in.
+		if provider == "ec2" || provider == "scaleway" {
+			item.Region = region
+		} else if provider == "gce" {
+			item.Name, item.Region = gceNameAndLocation(host.ID, zone)
+		}
+
+		if inv != nil {
+			if tunnel, ok := inv.Find(provider, host.ID, host.IP); ok {
+				item.Name = tunnel.Name
+			}
+		}
+		listed = append(listed, item)
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, listed)
+	}
+	return printHostsTable(os.Stdout, listed)
+}
+
+// getListFilter returns the filter that matches the tags or labels each
+// provisioner applies to the hosts it creates.
+func getListFilter(provider string) (provision.ListFilter, error) {
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return provision.ListFilter{}, err
+	}
+	if spec.ListFilter == nil {
+		return provision.ListFilter{}, fmt.Errorf("listing exit-servers is not supported by the %s provider yet, use the cloud dashboard instead", provider)
+	}
+	return *spec.ListFilter, nil
+}
+
+// gceNameAndLocation extracts the instance name and zone from the
+// composite ID used by the GCE provisioner: name|zone|project|region
+func gceNameAndLocation(id, zone string) (string, string) {
+	fields := strings.Split(id, "|")
+	if len(fields) != 4 {
+		return "", zone
+	}
+	return fields[0], fields[1]
+}
+
+func printHostsTable(w io.Writer, hosts []listedHost) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tID\tIP\tREGION\tSTATUS")
+	for _, host := range hosts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			valueOrDash(host.Name),
+			host.ID,
+			valueOrDash(host.IP),
+			valueOrDash(host.Region),
+			valueOrDash(host.Status))
+	}
+	return tw.Flush()
+}
+
+func valueOrDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_GetListFilter_MatchesProvisionerTags(t *testing.T) {
+	cases := map[string]string{
+		"digitalocean": "inlets",
+		"scaleway":     "inlets",
+		"ec2":          "tag:inlets,exit-node",
+		"gce":          "labels.inlets=exit-node",
+		"vultr":        "inlets-exit-node",
+		"hetzner":      "",
+	}
+
+	for provider, want := range cases {
+		filter, err := getListFilter(provider)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", provider, err)
+		}
+		if filter.Filter != want {
+			t.Errorf("%s: want filter %q, but got %q", provider, want, filter.Filter)
+		}
+	}
+}
+
+func Test_GetListFilter_UnsupportedProvider(t *testing.T) {
+	for _, provider := range []string{"linode", "azure", "ovh"} {
+		_, err := getListFilter(provider)
+		if err == nil {
+			t.Fatalf("%s: want error for unsupported provider", provider)
+		}
+		want := "listing exit-servers is not supported by the " + provider + " provider yet, use the cloud dashboard instead"
+		if err.Error() != want {
+			t.Errorf("want: %q, but got: %q", want, err.Error())
+		}
+	}
+}
+
+func Test_GCENameAndLocation(t *testing.T) {
+	name, zone := gceNameAndLocation("tunnel-1|us-east1-b|my-project|us-east1", "us-central1-a")
+	if name != "tunnel-1" {
+		t.Errorf("want name: tunnel-1, but got: %s", name)
+	}
+	if zone != "us-east1-b" {
+		t.Errorf("want zone: us-east1-b, but got: %s", zone)
+	}
+}
+
+func Test_PrintHostsTable(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := printHostsTable(&buf, []listedHost{
+		{ID: "1234", IP: "192.0.2.1", Status: "active"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `NAME  ID    IP         REGION  STATUS
+-     1234  192.0.2.1  -       active
+`
+	if buf.String() != want {
+		t.Fatalf("want\n%s\nbut got\n%s\n", want, buf.String())
+	}
+}
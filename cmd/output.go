@@ -0,0 +1,81 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// outputText is the default, human readable output format
+const outputText = "text"
+
+// outputFormats are the values accepted by --output for create and delete
+var outputFormats = []string{outputText, "json", "yaml", "env"}
+
+// envField is a single NAME=value line of the env output format
+type envField struct {
+	Name  string
+	Value string
+}
+
+// envDocument is implemented by results which can be printed in the env
+// output format, for use with eval or a .env file
+type envDocument interface {
+	envFields() []envField
+}
+
+func validateOutput(format string) error {
+	if !contains(outputFormats, format) {
+		return fmt.Errorf("--output must be one of: %s", strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// progressWriter returns where progress messages should be written. When a
+// structured format is requested, stdout only holds the document, so
+// progress goes to stderr instead.
+func progressWriter(format string) io.Writer {
+	if format == outputText {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// printDocument writes v as a json, yaml or env document
+func printDocument(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case "json":
+		return printJSON(w, v)
+	case "yaml":
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case "env":
+		doc, ok := v.(envDocument)
+		if !ok {
+			return fmt.Errorf("the env output format is not available for this result")
+		}
+		for _, field := range doc.envFields() {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", field.Name, shellQuote(field.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// shellQuote wraps s in single quotes so that it can be sourced by a shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
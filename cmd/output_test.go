@@ -0,0 +1,149 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func makeTestSummary() tunnelSummary {
+	return tunnelSummary{
+		Name:             "tunnel-1",
+		HostID:           "1234",
+		IP:               "192.0.2.1",
+		Provider:         "digitalocean",
+		Region:           "lon1",
+		Mode:             "https",
+		Domains:          []string{"a.example.com", "b.example.com"},
+		ControlPort:      8123,
+		Token:            "it's-a-secret",
+		InletsProVersion: "0.11.5",
+		Upstream:         defaultHTTPUpstream,
+		ClientCommand:    makeClientCommand("https", "192.0.2.1", 8123, "token", defaultHTTPUpstream, nil),
+		TimeToReady:      "1m32s",
+	}
+}
+
+func Test_PrintDocument_JSON(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := printDocument(&buf, "json", makeTestSummary()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %s", err)
+	}
+
+	if got["ip"] != "192.0.2.1" {
+		t.Errorf("want ip: 192.0.2.1, but got: %v", got["ip"])
+	}
+	if got["control_port"] != float64(8123) {
+		t.Errorf("want control_port: 8123, but got: %v", got["control_port"])
+	}
+	if _, ok := got["zone"]; ok {
+		t.Errorf("want zone to be omitted when empty")
+	}
+}
+
+func Test_PrintDocument_YAML(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := printDocument(&buf, "yaml", makeTestSummary()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := tunnelSummary{}
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid YAML: %s", err)
+	}
+
+	if got.Token != "it's-a-secret" {
+		t.Errorf("want token to round-trip, but got: %q", got.Token)
+	}
+	if got.ClientCommand != makeTestSummary().ClientCommand {
+		t.Errorf("want client command to round-trip, but got: %q", got.ClientCommand)
+	}
+}
+
+func Test_PrintDocument_Env(t *testing.T) {
+	buf := bytes.Buffer{}
+	s := makeTestSummary()
+	s.ClientCommand = "inlets-pro http client"
+	if err := printDocument(&buf, "env", s); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `INLETS_NAME='tunnel-1'
+INLETS_HOST_ID='1234'
+INLETS_IP='192.0.2.1'
+INLETS_RESERVED_IP=''
+INLETS_PROVIDER='digitalocean'
+INLETS_REGION='lon1'
+INLETS_ZONE=''
+INLETS_MODE='https'
+INLETS_DOMAINS='a.example.com,b.example.com'
+INLETS_DNS_PROVIDER=''
+INLETS_CERTIFICATES=''
+INLETS_CONTROL_PORT='8123'
+INLETS_PORTS=''
+INLETS_UPSTREAM='http://127.0.0.1:8080'
+INLETS_TOKEN='it'"'"'s-a-secret'
+INLETS_PRO_VERSION='0.11.5'
+INLETS_CLIENT_COMMAND='inlets-pro http client'
+INLETS_SSH_USER=''
+INLETS_CLIENT_ARTIFACTS=''
+INLETS_TIME_TO_READY='1m32s'
+`
+	if buf.String() != want {
+		t.Fatalf("want\n%s\nbut got\n%s\n", want, buf.String())
+	}
+}
+
+func Test_PrintDocument_EnvNotAvailableForLists(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := printDocument(&buf, "env", []tunnelSummary{makeTestSummary()})
+	if err == nil {
+		t.Fatalf("want error for a list in the env format")
+	}
+}
+
+func Test_MakeClientCommand_TCP(t *testing.T) {
+	got := makeClientCommand("tcp", "192.0.2.1", 8123, "token", defaultTCPUpstream, nil)
+	want := `inlets-pro tcp client --url "wss://192.0.2.1:8123" \
+  --token "token" \
+  --upstream 127.0.0.1 \
+  --ports 2222`
+
+	if got != want {
+		t.Fatalf("want\n%s\nbut got\n%s\n", want, got)
+	}
+}
+
+func Test_MakeClientCommand_TCPPorts(t *testing.T) {
+	got := makeClientCommand("tcp", "192.0.2.1", 8123, "token", "192.168.0.10", []int{22, 443, 5432})
+	want := `inlets-pro tcp client --url "wss://192.0.2.1:8123" \
+  --token "token" \
+  --upstream 192.168.0.10 \
+  --ports 22,443,5432`
+
+	if got != want {
+		t.Fatalf("want\n%s\nbut got\n%s\n", want, got)
+	}
+}
+
+func Test_ValidateOutput(t *testing.T) {
+	for _, format := range []string{"text", "json", "yaml", "env"} {
+		if err := validateOutput(format); err != nil {
+			t.Errorf("%s: unexpected error: %s", format, err)
+		}
+	}
+
+	if err := validateOutput("xml"); err == nil {
+		t.Errorf("want error for xml")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	ovhsdk "github.com/dirien/ovh-go-sdk/pkg/sdk"
+)
+
+// ovhSSHKeys looks up the keys of an OVH public cloud project
+type ovhSSHKeys struct {
+	client *ovhsdk.OVHcloud
+}
+
+func newOVHSSHKeys(endpoint, appKey, appSecret, consumerKey, region, projectID string) (*ovhSSHKeys, error) {
+	client, err := ovhsdk.NewOVHClient(endpoint, appKey, appSecret, consumerKey, region, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return &ovhSSHKeys{client: client}, nil
+}
+
+func (k *ovhSSHKeys) Get(name string) (string, error) {
+	keys, err := k.client.ListSSHKeys(context.Background())
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.Name == name {
+			return key.PublicKey, nil
+		}
+	}
+	return "", fmt.Errorf("no OVH SSH key named %q in project %s", name, k.client.ServiceName)
+}
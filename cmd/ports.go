@@ -0,0 +1,91 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// portsTag is the tag or label which records the TCP ports of a tunnel on
+// its host. OVH instances cannot be tagged by inletsctl, so their ports are
+// only recorded in the inventory, see inletsctl show.
+const portsTag = "inlets-ports"
+
+// portsTagValue gives the tag for providers whose tags are plain strings,
+// i.e. "inlets-ports:22-443", as some of them do not allow commas
+func portsTagValue(ports []int) string {
+	return portsTag + ":" + joinPorts(ports, "-")
+}
+
+// portTags records the TCP ports of a tunnel on its host, for providers
+// whose provisioner does not do so when the host is created
+type portTags interface {
+	// Tag adds the ports to the host with the ID
+	Tag(hostID string, ports []int) error
+}
+
+// parseTCPPorts parses the comma separated ports given with --tcp-ports,
+// they are returned sorted and without duplicates. The control port of
+// inlets-pro cannot be given, since it is always open.
+func parseTCPPorts(value string) ([]int, error) {
+	seen := map[int]bool{}
+	var ports []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		port, err := strconv.Atoi(part)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("--tcp-ports must be a comma separated list of ports from 1 to 65535, but got: %q", part)
+		}
+		if port == inletsProControlPort {
+			return nil, fmt.Errorf("--tcp-ports cannot include %d, the control port of inlets-pro", port)
+		}
+
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+
+	sort.Ints(ports)
+	return ports, nil
+}
+
+// validateUpstream checks an --upstream, which is a URL for a HTTPS tunnel
+// and a host name or IP address for a TCP tunnel
+func validateUpstream(upstream string, tcp bool) error {
+	if len(upstream) == 0 {
+		return nil
+	}
+
+	if tcp {
+		if strings.Contains(upstream, "://") || strings.ContainsAny(upstream, " /") {
+			return fmt.Errorf("--upstream must be a host name or IP address for a TCP tunnel, but got: %q", upstream)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(upstream)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("--upstream must be a http(s) URL for a HTTPS tunnel, but got: %q", upstream)
+	}
+	return nil
+}
+
+// joinPorts formats ports with the given separator, since some providers do
+// not allow commas in tags or labels
+func joinPorts(ports []int, sep string) string {
+	parts := make([]string, 0, len(ports))
+	for _, port := range ports {
+		parts = append(parts, strconv.Itoa(port))
+	}
+	return strings.Join(parts, sep)
+}
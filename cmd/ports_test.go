@@ -0,0 +1,124 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+)
+
+type fakePortTags struct {
+	err    error
+	tagged map[string][]int
+}
+
+func (f *fakePortTags) Tag(hostID string, ports []int) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.tagged == nil {
+		f.tagged = map[string][]int{}
+	}
+	f.tagged[hostID] = ports
+	return nil
+}
+
+func Test_ParseTCPPorts(t *testing.T) {
+	got, err := parseTCPPorts("5432, 22,443,22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{22, 443, 5432}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+
+	if got, err := parseTCPPorts(""); err != nil || len(got) != 0 {
+		t.Errorf("want no ports for an empty value, but got: %v, %v", got, err)
+	}
+}
+
+func Test_ParseTCPPorts_Invalid(t *testing.T) {
+	for _, value := range []string{"ssh", "0", "65536", "22-25", "8123"} {
+		if _, err := parseTCPPorts(value); err == nil {
+			t.Errorf("%s: want error", value)
+		}
+	}
+}
+
+func Test_ValidateUpstream(t *testing.T) {
+	cases := []struct {
+		upstream string
+		tcp      bool
+		valid    bool
+	}{
+		{"192.168.0.10", true, true},
+		{"db.internal", true, true},
+		{"http://127.0.0.1:3000", true, false},
+		{"http://127.0.0.1:3000", false, true},
+		{"https://grafana.internal", false, true},
+		{"127.0.0.1:3000", false, false},
+		{"", false, true},
+	}
+
+	for _, c := range cases {
+		err := validateUpstream(c.upstream, c.tcp)
+		if c.valid && err != nil {
+			t.Errorf("%q (tcp: %v): unexpected error: %s", c.upstream, c.tcp, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%q (tcp: %v): want error", c.upstream, c.tcp)
+		}
+	}
+}
+
+func Test_PortsTagValue(t *testing.T) {
+	if got, want := portsTagValue([]int{22, 443, 5432}), "inlets-ports:22-443-5432"; got != want {
+		t.Errorf("want %q, but got %q", want, got)
+	}
+}
+
+func Test_ProvisionTunnel_TagsPorts(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{{status: "active"}}}
+	plan, inv := makeRollbackTest(t, p)
+	plan.Name = "tunnel-2"
+	plan.Host = &provision.BasicHost{Name: "tunnel-2"}
+	plan.Poll = time.Millisecond * 10
+	plan.Timeout = time.Second
+	plan.TCPPorts = []int{22, 443}
+	tags := &fakePortTags{}
+	plan.portTags = tags
+
+	if _, err := provisionTunnel(context.Background(), plan, inv, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tags.tagged["1234"]; !reflect.DeepEqual(got, []int{22, 443}) {
+		t.Errorf("want host 1234 tagged with its ports, but got: %v", tags.tagged)
+	}
+}
+
+func Test_ProvisionTunnel_TagFailureWarns(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{{status: "active"}}}
+	plan, inv := makeRollbackTest(t, p)
+	plan.Name = "tunnel-2"
+	plan.Host = &provision.BasicHost{Name: "tunnel-2"}
+	plan.Poll = time.Millisecond * 10
+	plan.Timeout = time.Second
+	plan.TCPPorts = []int{22}
+	plan.portTags = &fakePortTags{err: errors.New("403 forbidden")}
+
+	var progress bytes.Buffer
+	if _, err := provisionTunnel(context.Background(), plan, inv, &progress); err != nil {
+		t.Fatalf("want the host to be kept, but got: %s", err)
+	}
+	if !strings.Contains(progress.String(), "Warning: unable to tag host 1234 with its ports: 403 forbidden") {
+		t.Errorf("want a warning on the progress writer, but got: %q", progress.String())
+	}
+}
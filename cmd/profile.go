@@ -0,0 +1,58 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/inlets/inletsctl/pkg/env"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// profileEnvVar names a profile to use when --profile is not given
+const profileEnvVar = "INLETSCTL_PROFILE"
+
+// applyProfile sets flags from the profile named by --profile, or by the
+// INLETSCTL_PROFILE environment variable, in the user's config file. Flags
+// which have already been set take precedence over the profile.
+func applyProfile(flags *pflag.FlagSet, progress io.Writer) error {
+	name, err := flags.GetString("profile")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'profile' value")
+	}
+	if len(name) == 0 {
+		name = os.Getenv(profileEnvVar)
+	}
+	if len(name) == 0 {
+		return nil
+	}
+
+	path, err := env.DefaultConfigPath()
+	if err != nil {
+		return err
+	}
+
+	config, err := env.LoadConfig(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("profile %q was given, but there is no config file at %s", name, path)
+		}
+		return err
+	}
+
+	profile, err := config.GetProfile(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := env.ApplyProfile(flags, profile); err != nil {
+		return fmt.Errorf("%s: profiles.%s: %w", path, name, err)
+	}
+
+	fmt.Fprintf(progress, "Using profile: %s\n", name)
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	inletsCmd.AddCommand(providersCmd)
+	providersCmd.Flags().StringP("output", "o", "table", "Output format - table or json")
+}
+
+// providersCmd represents the providers sub command
+var providersCmd = &cobra.Command{
+	Use:   "providers [NAME]",
+	Short: "Show the defaults and requirements of each cloud provider",
+	Long: `Show the default region, plan and OS image used by inletsctl create for
+each cloud provider, along with an estimate of its cost, the credentials
+and flags it needs, and which other commands it supports. When a name is
+given, the details of that provider are shown.
+
+Costs are estimates of the list price of the default plan, without storage
+or bandwidth, check the provider's pricing before relying on them.`,
+	Example: `  inletsctl providers
+  inletsctl providers scaleway
+  inletsctl providers --output json
+`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          runProviders,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+// providerInfo describes a provider for inletsctl providers, it is made
+// from the providerSpec used by create, delete and list
+type providerInfo struct {
+	Name          string         `json:"name"`
+	DefaultRegion string         `json:"default_region,omitempty"`
+	DefaultZone   string         `json:"default_zone,omitempty"`
+	Plans         []planInfo     `json:"plans"`
+	Credentials   []secretSource `json:"credentials"`
+	RequiredFlags []string       `json:"required_flags"`
+	CloudInit     bool           `json:"cloud_init"`
+	List          bool           `json:"list"`
+	DeleteByIP    bool           `json:"delete_by_ip"`
+	TCPPorts      bool           `json:"tcp_ports_firewall"`
+	AllowCIDR     bool           `json:"allow_cidr"`
+	ReservedIP    bool           `json:"reserved_ip"`
+	SSHUser       string         `json:"ssh_user"`
+	SSHKeyName    bool           `json:"ssh_key_name"`
+}
+
+// planInfo is the default plan and OS image for one architecture
+type planInfo struct {
+	Arch     string  `json:"arch"`
+	Plan     string  `json:"plan"`
+	OS       string  `json:"os"`
+	Hourly   float64 `json:"hourly_cost"`
+	Monthly  float64 `json:"monthly_cost"`
+	Currency string  `json:"currency"`
+}
+
+func runProviders(cmd *cobra.Command, args []string) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'output' value.")
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("--output must be table or json")
+	}
+
+	if len(args) == 0 {
+		infos := make([]providerInfo, 0, len(providerSpecs))
+		for _, spec := range providerSpecs {
+			info, err := makeProviderInfo(spec)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+
+		if output == "json" {
+			return printJSON(os.Stdout, infos)
+		}
+		return printProvidersTable(os.Stdout, infos)
+	}
+
+	spec, err := getProviderSpec(args[0])
+	if err != nil {
+		return fmt.Errorf("unknown provider %q, use one of: %s", args[0], strings.Join(providerNames(), ", "))
+	}
+
+	info, err := makeProviderInfo(spec)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, info)
+	}
+	return printProvider(os.Stdout, info)
+}
+
+// makeProviderInfo describes a provider, the plans and OS images are taken
+// from the host requests that createHost makes for each architecture
+func makeProviderInfo(spec providerSpec) (providerInfo, error) {
+	info := providerInfo{
+		Name:          spec.Name,
+		DefaultRegion: spec.DefaultRegion,
+		DefaultZone:   spec.DefaultZone,
+		Credentials:   spec.secretSources(),
+		RequiredFlags: append(append([]string{}, spec.RequiredFlags...), spec.CreateFlags...),
+		CloudInit:     spec.CloudInit,
+		List:          spec.ListFilter != nil,
+		DeleteByIP:    spec.DeleteByIP,
+		TCPPorts:      spec.TCPPortsFirewall,
+		AllowCIDR:     spec.AllowCIDR,
+		ReservedIP:    spec.newReservedIPs != nil,
+		SSHUser:       spec.SSHUser,
+		SSHKeyName:    spec.SSHKeyName,
+	}
+
+	for _, arch := range archs {
+		image, ok := spec.Images[arch]
+		if !ok {
+			continue
+		}
+
+		host, err := createHost(spec.Name, hostOptions{Arch: arch})
+		if err != nil {
+			return info, err
+		}
+
+		info.Plans = append(info.Plans, planInfo{
+			Arch:     arch,
+			Plan:     host.Plan,
+			OS:       describeOS(host),
+			Hourly:   image.Hourly,
+			Monthly:  image.Monthly,
+			Currency: spec.Currency,
+		})
+	}
+
+	return info, nil
+}
+
+// describeOS gives the OS image of a host request. Azure images are made up
+// of several fields in Additional, which are given as an image URN.
+func describeOS(host *provision.BasicHost) string {
+	if host.OS != "Additional.imageOffer" {
+		return host.OS
+	}
+
+	return strings.Join([]string{
+		host.Additional["imagePublisher"],
+		host.Additional["imageOffer"],
+		host.Additional["imageSku"],
+		host.Additional["imageVersion"],
+	}, ":")
+}
+
+func printProvidersTable(w io.Writer, infos []providerInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tREGION\tPLAN\tCOST\tARCH\tLIST\tDELETE BY IP")
+	for _, info := range infos {
+		region := info.DefaultRegion
+		if len(info.DefaultZone) > 0 {
+			region = info.DefaultZone
+		}
+
+		var plan planInfo
+		var arches []string
+		for _, p := range info.Plans {
+			if p.Arch == "amd64" {
+				plan = p
+			}
+			arches = append(arches, p.Arch)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Name,
+			valueOrDash(region),
+			plan.Plan,
+			formatCost(plan.Monthly, plan.Currency)+"/month",
+			strings.Join(arches, ","),
+			yesNo(info.List),
+			yesNo(info.DeleteByIP))
+	}
+	return tw.Flush()
+}
+
+func printProvider(w io.Writer, info providerInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Provider:\t%s\n", info.Name)
+	if len(info.DefaultRegion) > 0 {
+		fmt.Fprintf(tw, "Default region:\t%s\n", info.DefaultRegion)
+	} else {
+		fmt.Fprintf(tw, "Default region:\tnone, --region is required\n")
+	}
+	if len(info.DefaultZone) > 0 {
+		fmt.Fprintf(tw, "Default zone:\t%s\n", info.DefaultZone)
+	}
+
+	for _, plan := range info.Plans {
+		fmt.Fprintf(tw, "Plan (%s):\t%s, %s/hour, %s/month\n", plan.Arch, plan.Plan, formatCost(plan.Hourly, plan.Currency), formatCost(plan.Monthly, plan.Currency))
+		fmt.Fprintf(tw, "OS image (%s):\t%s\n", plan.Arch, plan.OS)
+	}
+
+	for _, source := range info.Credentials {
+		requirement := "required"
+		if !source.Required {
+			requirement = "optional"
+		}
+
+		from := []string{"--" + source.Flag, "--" + source.FileFlag, "--" + source.CommandFlag}
+		for _, s := range source.Sources {
+			from = append(from, s.Name)
+		}
+		fmt.Fprintf(tw, "Credential:\t%s (%s)\n", strings.Join(from, ", "), requirement)
+	}
+
+	flags := append([]string{}, info.RequiredFlags...)
+	sort.Strings(flags)
+	for i, flag := range flags {
+		flags[i] = "--" + flag
+	}
+	fmt.Fprintf(tw, "Required flags:\t%s\n", valueOrDash(strings.Join(flags, ", ")))
+
+	fmt.Fprintf(tw, "cloud-init:\t%s\n", yesNo(info.CloudInit))
+	fmt.Fprintf(tw, "List:\t%s\n", yesNo(info.List))
+	fmt.Fprintf(tw, "Delete by IP:\t%s\n", yesNo(info.DeleteByIP))
+	fmt.Fprintf(tw, "Firewall narrowed by --tcp-ports:\t%s\n", yesNo(info.TCPPorts))
+	fmt.Fprintf(tw, "Firewall restricted by --*-allow-cidr:\t%s\n", yesNo(info.AllowCIDR))
+	fmt.Fprintf(tw, "Reserved IP:\t%s\n", yesNo(info.ReservedIP))
+	fmt.Fprintf(tw, "SSH login user:\t%s\n", valueOrDash(info.SSHUser))
+	fmt.Fprintf(tw, "SSH key by --ssh-key-name:\t%s\n", yesNo(info.SSHKeyName))
+	return tw.Flush()
+}
+
+// formatCost gives a cost in USD or EUR with the currency's symbol, and
+// enough precision for hourly rates
+func formatCost(cost float64, currency string) string {
+	symbol := map[string]string{"USD": "$", "EUR": "€"}[currency]
+	if cost < 1 {
+		return fmt.Sprintf("%s%.4f", symbol, cost)
+	}
+	return fmt.Sprintf("%s%.2f", symbol, cost)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
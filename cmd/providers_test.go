@@ -0,0 +1,78 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_MakeProviderInfo_Azure(t *testing.T) {
+	spec, err := getProviderSpec("azure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := makeProviderInfo(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Plans) != 2 {
+		t.Fatalf("want plans for amd64 and arm64, but got: %d", len(info.Plans))
+	}
+
+	want := "Canonical:0001-com-ubuntu-server-jammy:22_04-lts-arm64:latest"
+	if got := info.Plans[1].OS; got != want {
+		t.Errorf("want OS image %q, but got: %q", want, got)
+	}
+	if info.List {
+		t.Errorf("want azure to not support list")
+	}
+}
+
+func Test_PrintProvider_RequiredFlags(t *testing.T) {
+	spec, err := getProviderSpec("scaleway")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := makeProviderInfo(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := printProvider(buf, info); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"fr-par-1\n",
+		"--organisation-id\n",
+		"--secret-key, --secret-key-file, --secret-key-cmd, $INLETS_SECRET_KEY (required)",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in output, but got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func Test_FormatCost(t *testing.T) {
+	cases := []struct {
+		cost     float64
+		currency string
+		want     string
+	}{
+		{cost: 0.0052, currency: "USD", want: "$0.0052"},
+		{cost: 3.49, currency: "EUR", want: "€3.49"},
+	}
+
+	for _, c := range cases {
+		if got := formatCost(c.cost, c.currency); got != c.want {
+			t.Errorf("want %q, but got: %q", c.want, got)
+		}
+	}
+}
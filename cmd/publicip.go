@@ -0,0 +1,108 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultIPSource is the chain used by --ip-source, the provider's metadata
+// service is tried first, then checkip.amazonaws.com
+const defaultIPSource = "metadata,checkip"
+
+// metadataIPCommands read the public IPv4 address of the exit-server from
+// each provider's metadata service. Linode and OVH have no entry, since
+// their metadata services do not give the public IP without extra setup,
+// but the address is assigned to the host's interface instead.
+var metadataIPCommands = map[string]string{
+	"digitalocean": `curl -sf --connect-timeout 2 -m 5 http://169.254.169.254/metadata/v1/interfaces/public/0/ipv4/address`,
+	"ec2":          `curl -sf --connect-timeout 2 -m 5 -H "X-aws-ec2-metadata-token: $(curl -sf --connect-timeout 2 -m 5 -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token)" http://169.254.169.254/latest/meta-data/public-ipv4`,
+	"gce":          `curl -sf --connect-timeout 2 -m 5 -H "Metadata-Flavor: Google" http://metadata.google.internal/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip`,
+	"hetzner":      `curl -sf --connect-timeout 2 -m 5 http://169.254.169.254/hetzner/v1/metadata/public-ipv4`,
+	"azure":        `curl -sf --connect-timeout 2 -m 5 -H "Metadata: true" "http://169.254.169.254/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress?api-version=2021-02-01&format=text"`,
+	"vultr":        `curl -sf --connect-timeout 2 -m 5 http://169.254.169.254/latest/meta-data/public-ipv4`,
+	"scaleway":     `curl -sf --connect-timeout 2 -m 5 http://169.254.42.42/conf | sed -n 's/^PUBLIC_IP_ADDRESS=//p'`,
+}
+
+// interfaceIPCommand gives the source address of the default route, which
+// is the public IP on providers that assign it to the host directly, but a
+// private IP on ec2, gce and azure.
+const interfaceIPCommand = `ip -4 route get 1.1.1.1 | sed -n 's/.* src \([0-9.]*\).*/\1/p'`
+
+// parseIPSource splits a comma separated --ip-source chain and validates
+// each of its entries. An entry is "metadata", "interface", "checkip" or
+// the URL of a service which echoes the caller's IP.
+func parseIPSource(chain string) ([]string, error) {
+	var sources []string
+	for _, source := range strings.Split(chain, ",") {
+		source = strings.TrimSpace(source)
+		if len(source) == 0 {
+			continue
+		}
+
+		switch source {
+		case "metadata", "interface", "checkip":
+		default:
+			u, err := url.Parse(source)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+				return nil, fmt.Errorf("--ip-source entries must be metadata, interface, checkip or a http(s) URL, but got: %q", source)
+			}
+		}
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("--ip-source must give at least one source")
+	}
+	return sources, nil
+}
+
+// validatePublicIP checks an address given with --public-ip, an empty
+// value means that the address is detected at boot time instead
+func validatePublicIP(ip string) error {
+	if len(ip) == 0 {
+		return nil
+	}
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		return fmt.Errorf("--public-ip must be an IPv4 address, but got: %q", ip)
+	}
+	return nil
+}
+
+// makeIPCommand gives a POSIX shell command which prints the public IP of
+// the exit-server. Each source is tried in turn until one prints a non-empty
+// value, or an error is written to stderr when none do. Sources which do
+// not apply to the provider are skipped. When publicIP is set, it is
+// printed as-is and no detection takes place.
+func makeIPCommand(provider, publicIP string, sources []string) string {
+	if len(publicIP) > 0 {
+		return fmt.Sprintf("echo %s", publicIP)
+	}
+
+	var commands []string
+	for _, source := range sources {
+		var command string
+		switch source {
+		case "metadata":
+			command = metadataIPCommands[provider]
+		case "interface":
+			command = interfaceIPCommand
+		case "checkip":
+			command = "curl -sfSL -m 10 https://checkip.amazonaws.com"
+		default:
+			command = fmt.Sprintf("curl -sfSL -m 10 %s", shellQuote(source))
+		}
+
+		if len(command) > 0 {
+			// grep fails on empty output, so the next source is tried
+			commands = append(commands, fmt.Sprintf("{ %s | grep . ; }", command))
+		}
+	}
+
+	commands = append(commands, `{ echo "unable to detect the public IP, see: inletsctl create --ip-source" >&2; }`)
+	return strings.Join(commands, " || ")
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_ParseIPSource(t *testing.T) {
+	got, err := parseIPSource(" metadata, interface,https://ifconfig.me ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"metadata", "interface", "https://ifconfig.me"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+}
+
+func Test_ParseIPSource_Invalid(t *testing.T) {
+	for _, chain := range []string{"", ",", "dns", "ftp://example.com"} {
+		if _, err := parseIPSource(chain); err == nil {
+			t.Errorf("%q: want error", chain)
+		}
+	}
+}
+
+func Test_ValidatePublicIP(t *testing.T) {
+	if err := validatePublicIP(""); err != nil {
+		t.Errorf("unexpected error for an empty IP: %s", err)
+	}
+	if err := validatePublicIP("203.0.113.10"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	for _, ip := range []string{"2001:db8::1", "example.com"} {
+		if err := validatePublicIP(ip); err == nil {
+			t.Errorf("%q: want error", ip)
+		}
+	}
+}
+
+func Test_MakeIPCommand_PublicIP(t *testing.T) {
+	got := makeIPCommand("ec2", "203.0.113.10", []string{"metadata"})
+	if want := "echo 203.0.113.10"; got != want {
+		t.Fatalf("want %q, but got %q", want, got)
+	}
+}
+
+func Test_MakeIPCommand_SkipsMetadataWithoutService(t *testing.T) {
+	got := makeIPCommand("linode", "", []string{"metadata", "checkip"})
+	want := `{ curl -sfSL -m 10 https://checkip.amazonaws.com | grep . ; } || { echo "unable to detect the public IP, see: inletsctl create --ip-source" >&2; }`
+
+	if got != want {
+		t.Fatalf("want\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func Test_MakeIPCommand_FallsBack(t *testing.T) {
+	// Run the chain with sh, overriding the sources so that the first
+	// prints nothing and the second prints an address
+	command := makeIPCommand("digitalocean", "", []string{"metadata", "checkip"})
+	command = strings.Replace(command, metadataIPCommands["digitalocean"], "echo", 1)
+	command = strings.Replace(command, "curl -sfSL -m 10 https://checkip.amazonaws.com", "echo 203.0.113.10", 1)
+
+	out, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "203.0.113.10" {
+		t.Fatalf("want 203.0.113.10, but got %q", got)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// probeTimeout bounds a single attempt of a readiness probe
+const probeTimeout = time.Second * 5
+
+// readinessProbe checks that one port of the exit-server is serving
+type readinessProbe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// makeReadinessProbes returns the probes for a tunnel server. The control
+// port must answer a HTTP request over TLS for all tunnels. HTTPS tunnels
+// must also answer on port 80 and accept connections on port 443, the
+// certificate is only issued once DNS points at the exit-server, so a TLS
+// handshake is not attempted there.
+func makeReadinessProbes(mode, ip string, controlPort int) []readinessProbe {
+	probes := []readinessProbe{
+		{
+			Name: fmt.Sprintf("control-plane (%d)", controlPort),
+			Check: func(ctx context.Context) error {
+				return probeHTTPS(ctx, net.JoinHostPort(ip, strconv.Itoa(controlPort)))
+			},
+		},
+	}
+
+	if mode == "https" {
+		probes = append(probes,
+			readinessProbe{
+				Name: "http (80)",
+				Check: func(ctx context.Context) error {
+					return probeHTTP(ctx, net.JoinHostPort(ip, "80"))
+				},
+			},
+			readinessProbe{
+				Name: "https (443)",
+				Check: func(ctx context.Context) error {
+					return probeTCP(ctx, net.JoinHostPort(ip, "443"))
+				},
+			})
+	}
+
+	return probes
+}
+
+// waitForReady runs each probe in turn until it passes, waiting interval
+// between attempts. An error is returned naming the first probe which has
+// not passed when the timeout is reached or ctx is cancelled.
+func waitForReady(ctx context.Context, probes []readinessProbe, timeout, interval time.Duration, progress io.Writer) error {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, probe := range probes {
+		for attempt := 1; ; attempt++ {
+			err := probe.Check(ctx)
+			if err == nil {
+				fmt.Fprintf(progress, "Ready: %s\n", probe.Name)
+				break
+			}
+
+			fmt.Fprintf(progress, "[%d] Waiting for %s: %s\n", attempt, probe.Name, err)
+
+			select {
+			case <-ctx.Done():
+				if parent.Err() != nil {
+					return fmt.Errorf("interrupted while waiting for %s", probe.Name)
+				}
+				return fmt.Errorf("%s was not ready within %s, last error: %w", probe.Name, timeout, err)
+			case <-time.After(interval):
+			}
+		}
+	}
+
+	return nil
+}
+
+// probeHTTPS passes when the address completes a TLS handshake and answers
+// a HTTP request with any status. The control-plane of inlets-pro uses a
+// self-signed certificate, so it is not verified.
+func probeHTTPS(ctx context.Context, addr string) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	return probeURL(ctx, client, "https://"+addr+"/")
+}
+
+// checkCertificateIP connects to the control-plane at addr and checks that
+// the certificate generated by inlets-pro with --auto-tls is valid for ip,
+// the address reported by the provider. Otherwise the exit-server detected
+// a different public IP when it booted and clients will reject it.
+func checkCertificateIP(ctx context.Context, addr, ip string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate was presented by %s", addr)
+	}
+
+	return certs[0].VerifyHostname(ip)
+}
+
+// probeHTTP passes when the address answers a plain HTTP request with any
+// status
+func probeHTTP(ctx context.Context, addr string) error {
+	client := &http.Client{
+		Transport: &http.Transport{},
+		// A redirect to HTTPS still shows that the server is up
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	return probeURL(ctx, client, "http://"+addr+"/")
+}
+
+func probeURL(ctx context.Context, client *http.Client, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// probeTCP passes when the address accepts a TCP connection
+func probeTCP(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,118 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ProbeHTTPS_SelfSignedServer(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if err := probeHTTPS(context.Background(), srv.Listener.Addr().String()); err != nil {
+		t.Fatalf("want probe to pass for any status, but got: %s", err)
+	}
+}
+
+func Test_ProbeHTTPS_PlainHTTPServerFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if err := probeHTTPS(context.Background(), srv.Listener.Addr().String()); err == nil {
+		t.Fatalf("want probe to fail without TLS")
+	}
+}
+
+func Test_CheckCertificateIP(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	addr := srv.Listener.Addr().String()
+	if err := checkCertificateIP(context.Background(), addr, "127.0.0.1"); err != nil {
+		t.Fatalf("want certificate to be valid for 127.0.0.1, but got: %s", err)
+	}
+	if err := checkCertificateIP(context.Background(), addr, "203.0.113.10"); err == nil {
+		t.Fatalf("want error for an IP missing from the certificate")
+	}
+}
+
+func Test_ProbeHTTP_Redirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/", http.StatusMovedPermanently)
+	}))
+	defer srv.Close()
+
+	if err := probeHTTP(context.Background(), srv.Listener.Addr().String()); err != nil {
+		t.Fatalf("want probe to pass for a redirect, but got: %s", err)
+	}
+}
+
+func Test_WaitForReady_PassesOnceServing(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	// Start serving after the first attempt has failed
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		srv := &http.Server{Handler: http.NotFoundHandler()}
+		t.Cleanup(func() { srv.Close() })
+		srv.Serve(l)
+	}()
+
+	probes := []readinessProbe{
+		{Name: "http", Check: func(ctx context.Context) error { return probeHTTP(ctx, addr) }},
+	}
+
+	if err := waitForReady(context.Background(), probes, time.Second*5, time.Millisecond*50, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func Test_WaitForReady_TimeoutNamesTheProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	probes := []readinessProbe{
+		{Name: "https (443)", Check: func(ctx context.Context) error { return probeTCP(ctx, addr) }},
+	}
+
+	err = waitForReady(context.Background(), probes, time.Millisecond*200, time.Millisecond*50, io.Discard)
+	if err == nil {
+		t.Fatalf("want timeout error")
+	}
+	if !strings.Contains(err.Error(), "https (443) was not ready within 200ms") {
+		t.Fatalf("want error naming the probe, but got: %s", err)
+	}
+}
+
+func Test_MakeReadinessProbes(t *testing.T) {
+	if got := len(makeReadinessProbes("tcp", "192.0.2.1", 8123)); got != 1 {
+		t.Errorf("want 1 probe for a TCP tunnel, but got: %d", got)
+	}
+	if got := len(makeReadinessProbes("https", "192.0.2.1", 8123)); got != 3 {
+		t.Errorf("want 3 probes for a HTTPS tunnel, but got: %d", got)
+	}
+}
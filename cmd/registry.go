@@ -0,0 +1,786 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/inlets/inletsctl/pkg/env"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// providerSpec describes what inletsctl knows about a cloud provider, the
+// create, delete and list commands all read from it
+type providerSpec struct {
+	Name string
+
+	// DefaultRegion is used when --region is not given, when it is empty
+	// create requires --region
+	DefaultRegion string
+
+	// DefaultZone is used when --zone is not given, it is only set for
+	// providers which place hosts in a zone
+	DefaultZone string
+
+	// Images gives the default plan and OS image for each architecture
+	// the provider supports
+	Images map[string]providerImage
+
+	// Currency of the cost estimates in Images
+	Currency string
+
+	// SecretKey is true when a secret key is needed as well as the
+	// access token
+	SecretKey bool
+
+	// SessionToken is true when an optional session token is accepted
+	SessionToken bool
+
+	// NativeSources are where the provider's own SDK and CLI look for a
+	// credential, keyed by the name of the flag which gives it. They are
+	// tried when the flag is not given, after the INLETS_* variables.
+	NativeSources map[string][]env.Source
+
+	// RequiredFlags must be given to create the provisioner
+	RequiredFlags []string
+
+	// CreateFlags must also be given to create a host
+	CreateFlags []string
+
+	// TCPPortsFirewall is true when --tcp-ports narrows the firewall that
+	// the provisioner creates for the host
+	TCPPortsFirewall bool
+
+	// AllowCIDR is true when --control-allow-cidr and --data-allow-cidr
+	// restrict the source addresses of the firewall for the host
+	AllowCIDR bool
+
+	// SSHUser is the user which logs in with the key from --ssh-key-file
+	// or --ssh-key-name
+	SSHUser string
+
+	// SSHKeyName is true when --ssh-key-name is supported. The key is
+	// attached to the host by the provisioner when customiseHost gives it
+	// the name, or else it is looked up with newSSHKeys and written by the
+	// user-data.
+	SSHKeyName bool
+
+	// CloudInit is true when the provider passes user-data to cloud-init,
+	// GCE runs it as a startup-script, Linode as a StackScript and Vultr
+	// as a boot script, so they can only be given bash
+	CloudInit bool
+
+	// DeleteByIP is true when the provisioner can find a host to delete
+	// from its IP alone
+	DeleteByIP bool
+
+	// ListFilter matches the tags or labels that the provisioner applies
+	// to the hosts it creates, it is nil when listing is not supported
+	ListFilter *provision.ListFilter
+
+	newProvisioner func(creds providerCredentials) (provision.Provisioner, error)
+
+	// newReservedIPs creates the client for the provider's reserved IPs,
+	// it is nil when they are not supported
+	newReservedIPs func(creds providerCredentials) (reservedIPs, error)
+
+	// newFirewalls creates the client for the provider's cloud firewalls,
+	// it is set for providers whose provisioner leaves every port open, so
+	// that create makes a firewall for each tunnel
+	newFirewalls func(creds providerCredentials) (cloudFirewalls, error)
+
+	// newSSHKeys creates the client which looks up --ssh-key-name, it is
+	// nil when the provisioner attaches the key itself
+	newSSHKeys func(creds providerCredentials) (sshKeys, error)
+
+	// newPortTags creates the client which records --tcp-ports on the
+	// host, it is nil when the provisioner records them itself
+	newPortTags func(creds providerCredentials) (portTags, error)
+
+	// customiseHost fills in the provider specific fields of the host
+	// request, after the plan, OS and user-data have been set
+	customiseHost func(host *provision.BasicHost, opts hostOptions)
+}
+
+// providerImage is the default plan and OS image for one architecture.
+// Additional is merged into provision.BasicHost.Additional. Hourly and
+// Monthly are estimates of the list price of the plan, without storage or
+// bandwidth, and will drift from the provider's pricing over time.
+type providerImage struct {
+	Plan       string
+	OS         string
+	Additional map[string]string
+	Hourly     float64
+	Monthly    float64
+}
+
+// providerCredentials are passed to a provider's constructor
+type providerCredentials struct {
+	AccessToken    string
+	SecretKey      string
+	SessionToken   string
+	OrganisationID string
+	ProjectID      string
+	SubscriptionID string
+	Region         string
+	Endpoint       string
+	ConsumerKey    string
+
+	// Sources records where each credential was read from, keyed by the
+	// name of its flag, see report
+	Sources map[string]string
+}
+
+// hostOptions are the settings for one exit-server, used by createHost
+type hostOptions struct {
+	Name        string
+	Region      string
+	Zone        string
+	ProjectID   string
+	Arch        string
+	UserData    string
+	ControlPort string
+	VPCID       string
+	SubnetID    string
+	TCP         bool
+	Domains     []string
+
+	// TCPPorts narrows the firewall of a TCP tunnel to these ports and
+	// the control port, when it is empty all ports are opened
+	TCPPorts []int
+
+	// ReservedIP is given to providers which assign it when the host is
+	// created, the others attach it once the host is active
+	ReservedIP string
+
+	// SSHKeyName is given to providers which attach a key stored with the
+	// provider when the host is created, see providerSpec.SSHKeyName
+	SSHKeyName string
+
+	// ControlAllowCIDRs and DataAllowCIDRs restrict the addresses which can
+	// reach the control port and the data ports, and SSHAllowCIDRs open SSH
+	// to those addresses. They are only recorded for providers with
+	// AllowCIDR.
+	ControlAllowCIDRs []string
+	DataAllowCIDRs    []string
+	SSHAllowCIDRs     []string
+}
+
+// providerSpecs lists the cloud providers which inletsctl can create
+// exit-servers on
+var providerSpecs = []providerSpec{
+	{
+		Name:          "digitalocean",
+		Currency:      "USD",
+		DefaultRegion: "lon1",
+		Images: map[string]providerImage{
+			"amd64": {Plan: "s-1vcpu-512mb-10gb", OS: "debian-13-x64", Hourly: 0.006, Monthly: 4.00},
+		},
+		NativeSources: map[string][]env.Source{
+			"access-token": {env.EnvVar("DIGITALOCEAN_TOKEN"), env.EnvVar("DIGITALOCEAN_ACCESS_TOKEN")},
+		},
+		SSHUser:          "root",
+		SSHKeyName:       true,
+		CloudInit:        true,
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{Filter: "inlets"},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return provision.NewDigitalOceanProvisioner(c.AccessToken)
+		},
+		newReservedIPs: func(c providerCredentials) (reservedIPs, error) {
+			return newDigitalOceanReservedIPs(c.AccessToken), nil
+		},
+		newFirewalls: func(c providerCredentials) (cloudFirewalls, error) {
+			return newDigitalOceanFirewalls(c.AccessToken), nil
+		},
+		newPortTags: func(c providerCredentials) (portTags, error) {
+			return newDigitalOceanPortTags(c.AccessToken), nil
+		},
+		newSSHKeys: func(c providerCredentials) (sshKeys, error) {
+			return newDigitalOceanSSHKeys(c.AccessToken), nil
+		},
+	},
+	{
+		Name:     "gce",
+		Currency: "USD",
+		// Tau T2A (arm64) machines are only offered in some zones
+		DefaultZone: "us-central1-a",
+		Images: map[string]providerImage{
+			"amd64": {Plan: "f1-micro", OS: "projects/ubuntu-os-cloud/global/images/ubuntu-minimal-2204-jammy-v20240606", Hourly: 0.0076, Monthly: 5.55},
+			"arm64": {Plan: "t2a-standard-1", OS: "projects/ubuntu-os-cloud/global/images/ubuntu-minimal-2204-jammy-arm64-v20240606", Hourly: 0.0385, Monthly: 28.11},
+		},
+		CreateFlags: []string{"project-id"},
+		NativeSources: map[string][]env.Source{
+			"access-token": {env.EnvVarFile("GOOGLE_APPLICATION_CREDENTIALS")},
+			"project-id":   {env.EnvVar("GOOGLE_CLOUD_PROJECT")},
+		},
+		// The user-data creates the user when the image has none
+		SSHUser:          "ubuntu",
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{Filter: "labels.inlets=exit-node"},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return newGCEProvisioner(c.AccessToken)
+		},
+		newReservedIPs: func(c providerCredentials) (reservedIPs, error) {
+			return newGCEReservedIPs(c.AccessToken, c.ProjectID)
+		},
+		customiseHost: func(host *provision.BasicHost, opts hostOptions) {
+			host.Additional["projectid"] = opts.ProjectID
+			host.Additional["zone"] = opts.Zone
+			host.Additional["firewall-name"] = "inlets"
+			host.Additional["firewall-port"] = opts.ControlPort
+			host.Additional["pro"] = fmt.Sprint(opts.TCP)
+
+			// The rule named "inlets" is shared by every exit-server in the
+			// project, so one is made for this instance alone, see gceProvisioner
+			if opts.TCP && len(opts.TCPPorts) > 0 {
+				host.Additional["firewall-name"] = gcePortsRule(opts.Name)
+				host.Additional["pro"] = "false"
+				host.Additional["ports"] = joinPorts(opts.TCPPorts, ",")
+			}
+			if len(opts.ControlAllowCIDRs) > 0 || len(opts.DataAllowCIDRs) > 0 || len(opts.SSHAllowCIDRs) > 0 {
+				host.Additional["firewall-name"] = gcePortsRule(opts.Name)
+			}
+		},
+	},
+	{
+		Name:          "ec2",
+		Currency:      "USD",
+		DefaultRegion: "eu-west-1",
+		// Ubuntu images can be found here https://cloud-images.ubuntu.com/locator/ec2/
+		// Name is used in the OS field so the ami can be lookup up in the region specified
+		Images: map[string]providerImage{
+			"amd64": {Plan: "t3.nano", OS: "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20250822", Hourly: 0.0052, Monthly: 3.80},
+			"arm64": {Plan: "t4g.nano", OS: "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-20250822", Hourly: 0.0042, Monthly: 3.07},
+		},
+		SecretKey:    true,
+		SessionToken: true,
+		NativeSources: map[string][]env.Source{
+			"access-token":  {env.EnvVar("AWS_ACCESS_KEY_ID"), env.AWSCredentials("aws_access_key_id")},
+			"secret-key":    {env.EnvVar("AWS_SECRET_ACCESS_KEY"), env.AWSCredentials("aws_secret_access_key")},
+			"session-token": {env.EnvVar("AWS_SESSION_TOKEN"), env.AWSCredentials("aws_session_token")},
+		},
+		SSHUser:          "ubuntu",
+		SSHKeyName:       true,
+		CloudInit:        true,
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{Filter: "tag:inlets,exit-node"},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return newEC2Provisioner(c.Region, c.AccessToken, c.SecretKey, c.SessionToken)
+		},
+		newReservedIPs: func(c providerCredentials) (reservedIPs, error) {
+			return newEC2ReservedIPs(c.Region, c.AccessToken, c.SecretKey, c.SessionToken)
+		},
+		newPortTags: func(c providerCredentials) (portTags, error) {
+			return newEC2PortTags(c.Region, c.AccessToken, c.SecretKey, c.SessionToken)
+		},
+		customiseHost: func(host *provision.BasicHost, opts hostOptions) {
+			host.UserData = base64.StdEncoding.EncodeToString([]byte(host.UserData))
+
+			host.Additional["inlets-port"] = opts.ControlPort
+			host.Additional["pro"] = fmt.Sprint(opts.TCP)
+
+			// Giving ports closes the range of high ports opened for TCP
+			if len(opts.Domains) > 0 {
+				host.Additional["ports"] = "80,443"
+			} else if opts.TCP && len(opts.TCPPorts) > 0 {
+				host.Additional["ports"] = joinPorts(opts.TCPPorts, ",")
+			}
+			if len(opts.SSHKeyName) > 0 {
+				host.Additional["key-name"] = opts.SSHKeyName
+			}
+			if len(opts.VPCID) > 0 {
+				host.Additional["vpc-id"] = opts.VPCID
+			}
+			if len(opts.SubnetID) > 0 {
+				host.Additional["subnet-id"] = opts.SubnetID
+			}
+		},
+	},
+	{
+		Name:     "azure",
+		Currency: "USD",
+		// Ubuntu images can be found here https://docs.microsoft.com/en-us/azure/virtual-machines/linux/cli-ps-findimage#list-popular-images
+		// An image includes more than one property, it has publisher, offer, sku and version.
+		// So they have to be in "Additional" instead of just "OS".
+		Images: map[string]providerImage{
+			"amd64": {Plan: "Standard_B1ls", OS: "Additional.imageOffer", Additional: map[string]string{"imageSku": "22_04-lts-gen2"}, Hourly: 0.0052, Monthly: 3.80},
+			"arm64": {Plan: "Standard_B2pts_v2", OS: "Additional.imageOffer", Additional: map[string]string{"imageSku": "22_04-lts-arm64"}, Hourly: 0.0084, Monthly: 6.13},
+		},
+		NativeSources: map[string][]env.Source{
+			"access-token":    {env.EnvVarFile("AZURE_AUTH_LOCATION"), azureEnvCredentials()},
+			"subscription-id": {env.EnvVar("AZURE_SUBSCRIPTION_ID")},
+		},
+		// The admin user which cloud-provision gives the VM
+		SSHUser:          "inletsuser",
+		CloudInit:        true,
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return newAzureProvisioner(c.SubscriptionID, c.AccessToken)
+		},
+		customiseHost: func(host *provision.BasicHost, opts hostOptions) {
+			host.Additional["inlets-port"] = opts.ControlPort
+			host.Additional["pro"] = fmt.Sprint(opts.TCP)
+			host.Additional["imagePublisher"] = "Canonical"
+			host.Additional["imageOffer"] = "0001-com-ubuntu-server-jammy"
+			host.Additional["imageVersion"] = "latest"
+
+			if opts.TCP && len(opts.TCPPorts) > 0 {
+				host.Additional["ports"] = joinPorts(opts.TCPPorts, ",")
+			}
+		},
+	},
+	{
+		Name:          "scaleway",
+		Currency:      "EUR",
+		DefaultRegion: "fr-par-1",
+		// The image is resolved for the architecture of the plan
+		Images: map[string]providerImage{
+			"amd64": {Plan: "DEV1-S", OS: "ubuntu-jammy", Hourly: 0.0088, Monthly: 6.42},
+			"arm64": {Plan: "COPARM1-2C-8G", OS: "ubuntu-jammy", Hourly: 0.0426, Monthly: 31.10},
+		},
+		SecretKey:        true,
+		RequiredFlags:    []string{"organisation-id"},
+		SSHUser:          "root",
+		CloudInit:        true,
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{Filter: "inlets"},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return provision.NewScalewayProvisioner(c.AccessToken, c.SecretKey, c.OrganisationID, c.Region)
+		},
+		newFirewalls: func(c providerCredentials) (cloudFirewalls, error) {
+			return newScalewayFirewalls(c.AccessToken, c.SecretKey, c.OrganisationID, c.Region)
+		},
+		newPortTags: func(c providerCredentials) (portTags, error) {
+			return newScalewayPortTags(c.AccessToken, c.SecretKey, c.OrganisationID, c.Region)
+		},
+	},
+	{
+		Name:          "linode",
+		Currency:      "USD",
+		DefaultRegion: "eu-west",
+		// Image:
+		//  List of images can be retrieved using: https://api.linode.com/v4/images
+		//  Example response: .."id": "linode/ubuntu20.04", "label": "Ubuntu 20.04 LTS"..
+		// Type:
+		//  Type is the VM plan / size in linode.
+		//  List of type and price can be retrieved using curl https://api.linode.com/v4/linode/types
+		Images: map[string]providerImage{
+			"amd64": {Plan: "g6-nanode-1", OS: "linode/ubuntu22.04", Hourly: 0.0075, Monthly: 5.00},
+		},
+		NativeSources: map[string][]env.Source{
+			"access-token": {env.EnvVar("LINODE_TOKEN")},
+		},
+		SSHUser:          "root",
+		SSHKeyName:       true,
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return provision.NewLinodeProvisioner(c.AccessToken)
+		},
+		newReservedIPs: func(c providerCredentials) (reservedIPs, error) {
+			return newLinodeReservedIPs(c.AccessToken), nil
+		},
+		newFirewalls: func(c providerCredentials) (cloudFirewalls, error) {
+			return newLinodeFirewalls(c.AccessToken), nil
+		},
+		newPortTags: func(c providerCredentials) (portTags, error) {
+			return newLinodePortTags(c.AccessToken), nil
+		},
+		newSSHKeys: func(c providerCredentials) (sshKeys, error) {
+			return newLinodeSSHKeys(c.AccessToken), nil
+		},
+		customiseHost: func(host *provision.BasicHost, opts hostOptions) {
+			host.Additional["inlets-port"] = opts.ControlPort
+			host.Additional["pro"] = fmt.Sprint(opts.TCP)
+		},
+	},
+	{
+		Name:          "hetzner",
+		Currency:      "EUR",
+		DefaultRegion: "hel1",
+		// Easiest way to get the information of available images and server types is through
+		// the Hetzner API, but it requires auth for any type of call.
+		// Images can be fetched from https://api.hetzner.cloud/v1/images
+		// Server types can be fetched from https://api.hetzner.cloud/v1/server_types
+		// The regions available are hel1 (Helsinki), nur1 (Nuremberg), fsn1 (Falkenstein)
+		// The image is looked up for the architecture of the plan, CAX plans are arm64
+		Images: map[string]providerImage{
+			"amd64": {Plan: "cx23", OS: "debian-13", Hourly: 0.0056, Monthly: 3.49},
+			"arm64": {Plan: "cax11", OS: "debian-13", Hourly: 0.0061, Monthly: 3.79},
+		},
+		NativeSources: map[string][]env.Source{
+			"access-token": {env.EnvVar("HCLOUD_TOKEN")},
+		},
+		SSHUser:          "root",
+		SSHKeyName:       true,
+		CloudInit:        true,
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return newHetznerProvisioner(c.AccessToken)
+		},
+		newReservedIPs: func(c providerCredentials) (reservedIPs, error) {
+			return newHetznerReservedIPs(c.AccessToken), nil
+		},
+		newFirewalls: func(c providerCredentials) (cloudFirewalls, error) {
+			return newHetznerFirewalls(c.AccessToken), nil
+		},
+		customiseHost: func(host *provision.BasicHost, opts hostOptions) {
+			if opts.TCP && len(opts.TCPPorts) > 0 {
+				host.Additional["ports"] = joinPorts(opts.TCPPorts, ",")
+			}
+			if len(opts.ReservedIP) > 0 {
+				host.Additional["primary-ip"] = opts.ReservedIP
+			}
+			if len(opts.SSHKeyName) > 0 {
+				host.Additional["ssh-key"] = opts.SSHKeyName
+			}
+		},
+	},
+	{
+		Name:          "ovh",
+		Currency:      "EUR",
+		DefaultRegion: "DE1",
+		Images: map[string]providerImage{
+			"amd64": {Plan: "s1-2", OS: "Ubuntu 22.04", Hourly: 0.0090, Monthly: 6.50},
+		},
+		SecretKey:     true,
+		RequiredFlags: []string{"project-id"},
+		SSHUser:       "ubuntu",
+		SSHKeyName:    true,
+		CloudInit:     true,
+		DeleteByIP:    true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return provision.NewOVHProvisioner(c.Endpoint, c.AccessToken, c.SecretKey, c.ConsumerKey, c.Region, c.ProjectID)
+		},
+		newSSHKeys: func(c providerCredentials) (sshKeys, error) {
+			return newOVHSSHKeys(c.Endpoint, c.AccessToken, c.SecretKey, c.ConsumerKey, c.Region, c.ProjectID)
+		},
+	},
+	{
+		Name:          "vultr",
+		Currency:      "USD",
+		DefaultRegion: "LHR", // London
+		// OS:
+		//  A complete list of available OS is available using: https://api.vultr.com/v1/os/list
+		//  1743 = Ubuntu 22.04 x64
+		// Plans:
+		//  A complete list of available OS is available using: https://api.vultr.com/v1/plans/list
+		//  201 = 1024 MB RAM,25 GB SSD,1.00 TB BW
+		Images: map[string]providerImage{
+			"amd64": {Plan: "201", OS: "1743", Hourly: 0.007, Monthly: 5.00},
+		},
+		NativeSources: map[string][]env.Source{
+			"access-token": {env.EnvVar("VULTR_API_KEY")},
+		},
+		SSHUser:          "root",
+		SSHKeyName:       true,
+		DeleteByIP:       true,
+		ListFilter:       &provision.ListFilter{Filter: "inlets-exit-node"},
+		TCPPortsFirewall: true,
+		AllowCIDR:        true,
+		newProvisioner: func(c providerCredentials) (provision.Provisioner, error) {
+			return provision.NewVultrProvisioner(c.AccessToken)
+		},
+		newFirewalls: func(c providerCredentials) (cloudFirewalls, error) {
+			return newVultrFirewalls(c.AccessToken), nil
+		},
+		newPortTags: func(c providerCredentials) (portTags, error) {
+			return newVultrPortTags(c.AccessToken), nil
+		},
+		newSSHKeys: func(c providerCredentials) (sshKeys, error) {
+			return newVultrSSHKeys(c.AccessToken), nil
+		},
+	},
+}
+
+// getProviderSpec looks up a provider by name
+func getProviderSpec(provider string) (providerSpec, error) {
+	for _, spec := range providerSpecs {
+		if spec.Name == provider {
+			return spec, nil
+		}
+	}
+	return providerSpec{}, fmt.Errorf("no provisioner for provider: %q", provider)
+}
+
+func isKnownProvider(provider string) bool {
+	_, err := getProviderSpec(provider)
+	return err == nil
+}
+
+// providerNames gives the names of all providers, in the order of
+// providerSpecs
+func providerNames() []string {
+	names := make([]string, 0, len(providerSpecs))
+	for _, spec := range providerSpecs {
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+// providersWith gives the names of the providers for which match is true
+func providersWith(match func(providerSpec) bool) []string {
+	var names []string
+	for _, spec := range providerSpecs {
+		if match(spec) {
+			names = append(names, spec.Name)
+		}
+	}
+	return names
+}
+
+// getProvisioner creates the provisioner for the provider with the given
+// credentials
+func getProvisioner(provider string, creds providerCredentials) (provision.Provisioner, error) {
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return nil, err
+	}
+	return spec.newProvisioner(creds)
+}
+
+// providerRegion gives --region when it was set, otherwise the provider's
+// default region
+func providerRegion(flags *pflag.FlagSet, spec providerSpec) string {
+	if flags.Changed("region") {
+		if region, _ := flags.GetString("region"); len(region) > 0 {
+			return region
+		}
+	}
+	return spec.DefaultRegion
+}
+
+// providerZone gives --zone when it was set, otherwise the provider's
+// default zone
+func providerZone(flags *pflag.FlagSet, spec providerSpec) string {
+	if flags.Changed("zone") {
+		if zone, _ := flags.GetString("zone"); len(zone) > 0 {
+			return zone
+		}
+	}
+	return spec.DefaultZone
+}
+
+// secretSource describes where readCredentials looks for a secret, the
+// file named by FileFlag is read first, then the output of CommandFlag,
+// then Flag, then each of Sources
+type secretSource struct {
+	Flag        string       `json:"flag"`
+	FileFlag    string       `json:"file_flag"`
+	CommandFlag string       `json:"command_flag"`
+	Sources     []env.Source `json:"sources,omitempty"`
+	Required    bool         `json:"required"`
+}
+
+// secretSources gives the secrets that the provider reads, the INLETS_*
+// environment variables are tried before the provider's native sources
+func (s providerSpec) secretSources() []secretSource {
+	sources := []secretSource{
+		{Flag: "access-token", FileFlag: "access-token-file", Sources: []env.Source{env.EnvVar("INLETS_ACCESS_TOKEN")}, Required: true},
+	}
+	if s.SecretKey {
+		sources = append(sources, secretSource{Flag: "secret-key", FileFlag: "secret-key-file", Sources: []env.Source{env.EnvVar("INLETS_SECRET_KEY")}, Required: true})
+	}
+	if s.SessionToken {
+		sources = append(sources, secretSource{Flag: "session-token", FileFlag: "session-token-file"})
+	}
+
+	for i := range sources {
+		sources[i].CommandFlag = env.CommandFlag(sources[i].Flag)
+		sources[i].Sources = append(sources[i].Sources, s.NativeSources[sources[i].Flag]...)
+	}
+	return sources
+}
+
+// readCredentials reads the credentials that the provider needs from the
+// flags, files and environment. Secrets are only required when required is
+// true, as they are not needed for a dry-run. Flags which the command does
+// not define are left empty, and other flags fall back to the provider's
+// native sources when not given. RequiredFlags are not checked here, see
+// providerCredentials.check.
+func readCredentials(flags *pflag.FlagSet, spec providerSpec, region string, required bool) (providerCredentials, error) {
+	creds := providerCredentials{Region: region, Sources: map[string]string{}}
+
+	secrets := map[string]*string{
+		"access-token":  &creds.AccessToken,
+		"secret-key":    &creds.SecretKey,
+		"session-token": &creds.SessionToken,
+	}
+	for _, source := range spec.secretSources() {
+		value, from, err := env.LookupFileOrString(flags, source.FileFlag, source.Flag, required && source.Required, source.Sources...)
+		if err != nil {
+			return creds, err
+		}
+		*secrets[source.Flag] = value
+		if len(from) > 0 {
+			creds.Sources[source.Flag] = from
+		}
+	}
+
+	// A session token is only valid for the key it was issued with, so one
+	// found in the provider's environment or credentials file is not used
+	// with a key which came from somewhere else
+	if from, ok := creds.Sources["session-token"]; ok && !sameNativeSource(spec, creds.Sources, "session-token", from) {
+		creds.SessionToken = ""
+		delete(creds.Sources, "session-token")
+	}
+
+	for name, value := range map[string]*string{
+		"organisation-id": &creds.OrganisationID,
+		"project-id":      &creds.ProjectID,
+		"subscription-id": &creds.SubscriptionID,
+		"endpoint":        &creds.Endpoint,
+		"consumer-key":    &creds.ConsumerKey,
+	} {
+		if flags.Lookup(name) == nil {
+			continue
+		}
+		var err error
+		if *value, err = flags.GetString(name); err != nil {
+			return creds, errors.Wrap(err, "failed to get '"+name+"' value")
+		}
+		if flags.Changed(name) {
+			continue
+		}
+
+		for _, source := range spec.NativeSources[name] {
+			v, err := source.Get()
+			if err != nil {
+				return creds, err
+			}
+			if len(v) > 0 {
+				*value = v
+				creds.Sources[name] = source.Describe()
+				break
+			}
+		}
+	}
+
+	return creds, nil
+}
+
+// sameNativeSource is true when the secret named flag was read from
+// outside of the provider's native sources, or when the access token and
+// secret key were read from the same native source as it, i.e. the same
+// environment variables or the same credentials file
+func sameNativeSource(spec providerSpec, sources map[string]string, flag, from string) bool {
+	for i, source := range spec.NativeSources[flag] {
+		if source.Describe() != from {
+			continue
+		}
+		for _, name := range []string{"access-token", "secret-key"} {
+			native := spec.NativeSources[name]
+			if i >= len(native) || sources[name] != native[i].Describe() {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// report writes where each credential was read from, without its value
+func (c providerCredentials) report(w io.Writer) {
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "Using %s from %s\n", name, c.Sources[name])
+	}
+}
+
+// check returns an error naming the first of the flags which has no value
+func (c providerCredentials) check(provider string, flags []string) error {
+	values := map[string]string{
+		"organisation-id": c.OrganisationID,
+		"project-id":      c.ProjectID,
+		"subscription-id": c.SubscriptionID,
+		"endpoint":        c.Endpoint,
+		"consumer-key":    c.ConsumerKey,
+	}
+
+	for _, flag := range flags {
+		if len(values[flag]) == 0 {
+			return fmt.Errorf("--%s flag must be set for the %s provider", flag, provider)
+		}
+	}
+	return nil
+}
+
+// createHost makes the host request for an exit-server from the provider's
+// default plan and OS image for the architecture
+func createHost(provider string, opts hostOptions) (*provision.BasicHost, error) {
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	image, ok := spec.Images[opts.Arch]
+	if !ok {
+		return nil, fmt.Errorf("the %s provider has no %s plan", provider, opts.Arch)
+	}
+
+	host := &provision.BasicHost{
+		Name:       opts.Name,
+		OS:         image.OS,
+		Plan:       image.Plan,
+		Region:     opts.Region,
+		UserData:   opts.UserData,
+		Additional: map[string]string{},
+	}
+	for k, v := range image.Additional {
+		host.Additional[k] = v
+	}
+
+	if spec.customiseHost != nil {
+		spec.customiseHost(host, opts)
+	}
+
+	// The firewall is made by create rather than the provisioner, from the
+	// same keys which the provisioners with a firewall read
+	if spec.newFirewalls != nil {
+		host.Additional["firewall-name"] = firewallName(opts.Name)
+		host.Additional["inlets-port"] = opts.ControlPort
+		host.Additional["pro"] = fmt.Sprint(opts.TCP)
+		if opts.TCP && len(opts.TCPPorts) > 0 {
+			host.Additional["ports"] = joinPorts(opts.TCPPorts, ",")
+		}
+	}
+
+	if spec.AllowCIDR {
+		if len(opts.ControlAllowCIDRs) > 0 {
+			host.Additional[controlAllowCIDRKey] = strings.Join(opts.ControlAllowCIDRs, ",")
+		}
+		if len(opts.DataAllowCIDRs) > 0 {
+			host.Additional[dataAllowCIDRKey] = strings.Join(opts.DataAllowCIDRs, ",")
+		}
+		if len(opts.SSHAllowCIDRs) > 0 {
+			host.Additional[sshAllowCIDRKey] = strings.Join(opts.SSHAllowCIDRs, ",")
+		}
+	}
+
+	return host, nil
+}
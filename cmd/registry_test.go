@@ -0,0 +1,270 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func Test_ProviderSpecs_Complete(t *testing.T) {
+	for _, spec := range providerSpecs {
+		if _, ok := spec.Images["amd64"]; !ok {
+			t.Errorf("%s: want an amd64 image", spec.Name)
+		}
+		if spec.newProvisioner == nil {
+			t.Errorf("%s: want a constructor", spec.Name)
+		}
+		if len(spec.SSHUser) == 0 {
+			t.Errorf("%s: want a SSH login user", spec.Name)
+		}
+	}
+}
+
+func Test_GetProviderSpec_Unknown(t *testing.T) {
+	if _, err := getProviderSpec("openstack"); err == nil {
+		t.Fatalf("want error for an unknown provider")
+	}
+}
+
+func Test_ProviderRegion(t *testing.T) {
+	spec, err := getProviderSpec("hetzner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("region", "lon1", "")
+
+	if got := providerRegion(flags, spec); got != "hel1" {
+		t.Errorf("want default region hel1, but got: %s", got)
+	}
+
+	flags.Set("region", "fsn1")
+	if got := providerRegion(flags, spec); got != "fsn1" {
+		t.Errorf("want region fsn1 from the flag, but got: %s", got)
+	}
+}
+
+func Test_ReadCredentials_SecretKeyFromEnv(t *testing.T) {
+	t.Setenv("INLETS_ACCESS_TOKEN", "access")
+	t.Setenv("INLETS_SECRET_KEY", "secret")
+
+	spec, err := getProviderSpec("scaleway")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	for _, name := range []string{"access-token", "access-token-file", "secret-key", "secret-key-file", "organisation-id"} {
+		flags.String(name, "", "")
+	}
+	flags.Set("organisation-id", "org-1")
+
+	creds, err := readCredentials(flags, spec, "fr-par-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := providerCredentials{
+		AccessToken:    "access",
+		SecretKey:      "secret",
+		OrganisationID: "org-1",
+		Region:         "fr-par-1",
+		Sources: map[string]string{
+			"access-token": "$INLETS_ACCESS_TOKEN",
+			"secret-key":   "$INLETS_SECRET_KEY",
+		},
+	}
+	if !reflect.DeepEqual(creds, want) {
+		t.Errorf("want %+v, but got %+v", want, creds)
+	}
+	if err := creds.check("scaleway", spec.RequiredFlags); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func Test_ReadCredentials_SessionTokenFromSameSource(t *testing.T) {
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+
+	spec, err := getProviderSpec("ec2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "AWS variables",
+			env:  map[string]string{"AWS_ACCESS_KEY_ID": "access", "AWS_SECRET_ACCESS_KEY": "secret"},
+			want: "token",
+		},
+		{
+			name: "INLETS variables",
+			env:  map[string]string{"INLETS_ACCESS_TOKEN": "access", "INLETS_SECRET_KEY": "secret"},
+		},
+		{
+			name: "mixed",
+			env:  map[string]string{"AWS_ACCESS_KEY_ID": "access", "INLETS_SECRET_KEY": "secret"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "INLETS_ACCESS_TOKEN", "INLETS_SECRET_KEY"} {
+				t.Setenv(name, tc.env[name])
+			}
+
+			creds, err := readCredentials(credentialFlags(), spec, "eu-west-1", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.SessionToken != tc.want {
+				t.Errorf("want session token %q, but got %q", tc.want, creds.SessionToken)
+			}
+			if _, ok := creds.Sources["session-token"]; ok != (len(tc.want) > 0) {
+				t.Errorf("want the session token source only when it is used, but got: %v", creds.Sources)
+			}
+		})
+	}
+}
+
+func Test_ProviderCredentials_Check(t *testing.T) {
+	err := providerCredentials{}.check("ovh", []string{"project-id"})
+	if err == nil {
+		t.Fatalf("want error for a missing --project-id")
+	}
+	if want := "--project-id flag must be set for the ovh provider"; err.Error() != want {
+		t.Errorf("want %q, but got %q", want, err.Error())
+	}
+}
+
+func Test_CreateHost_EC2(t *testing.T) {
+	host, err := createHost("ec2", hostOptions{
+		Name:        "tunnel-1",
+		Region:      "eu-west-1",
+		Arch:        "amd64",
+		UserData:    "#!/bin/bash",
+		ControlPort: "8123",
+		SSHKeyName:  "maintenance",
+		Domains:     []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host.Plan != "t3.nano" {
+		t.Errorf("want plan t3.nano, but got: %s", host.Plan)
+	}
+	if got, _ := base64.StdEncoding.DecodeString(host.UserData); string(got) != "#!/bin/bash" {
+		t.Errorf("want base64 encoded user-data, but got: %s", host.UserData)
+	}
+
+	want := map[string]string{
+		"inlets-port": "8123",
+		"pro":         "false",
+		"ports":       "80,443",
+		"key-name":    "maintenance",
+	}
+	for k, v := range want {
+		if host.Additional[k] != v {
+			t.Errorf("want Additional[%s] %q, but got %q", k, v, host.Additional[k])
+		}
+	}
+}
+
+func Test_CreateHost_TCPPorts(t *testing.T) {
+	opts := hostOptions{
+		Name:        "tunnel-1",
+		Arch:        "amd64",
+		ControlPort: "8123",
+		TCP:         true,
+		TCPPorts:    []int{22, 443, 5432},
+	}
+
+	cases := map[string]map[string]string{
+		"ec2":   {"pro": "true", "ports": "22,443,5432"},
+		"azure": {"pro": "true", "ports": "22,443,5432"},
+		"gce":   {"pro": "false", "ports": "22,443,5432", "firewall-name": "inlets-tunnel-1"},
+	}
+	for provider, want := range cases {
+		host, err := createHost(provider, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range want {
+			if host.Additional[k] != v {
+				t.Errorf("%s: want Additional[%s] %q, but got %q", provider, k, v, host.Additional[k])
+			}
+		}
+	}
+}
+
+func Test_CreateHost_AllowCIDRs(t *testing.T) {
+	opts := hostOptions{
+		Name:              "tunnel-1",
+		Arch:              "amd64",
+		ControlPort:       "8123",
+		TCP:               true,
+		ControlAllowCIDRs: []string{"203.0.113.10/32"},
+		DataAllowCIDRs:    []string{"198.51.100.0/24", "192.0.2.0/24"},
+	}
+
+	for _, provider := range []string{"ec2", "gce", "azure"} {
+		host, err := createHost(provider, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := host.Additional[controlAllowCIDRKey]; got != "203.0.113.10/32" {
+			t.Errorf("%s: want the control CIDRs, but got %q", provider, got)
+		}
+		if got := host.Additional[dataAllowCIDRKey]; got != "198.51.100.0/24,192.0.2.0/24" {
+			t.Errorf("%s: want the data CIDRs, but got %q", provider, got)
+		}
+	}
+
+	host, err := createHost("gce", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := host.Additional["firewall-name"]; got != "inlets-tunnel-1" {
+		t.Errorf("want the firewall rule for the instance, but got %q", got)
+	}
+}
+
+func Test_CreateHost_HetznerReservedIP(t *testing.T) {
+	host, err := createHost("hetzner", hostOptions{Arch: "amd64", ReservedIP: "203.0.113.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := host.Additional["primary-ip"]; got != "203.0.113.10" {
+		t.Errorf("want primary-ip 203.0.113.10, but got: %q", got)
+	}
+}
+
+func Test_CreateHost_SSHKeyName(t *testing.T) {
+	cases := map[string]string{"ec2": "key-name", "hetzner": "ssh-key"}
+	for provider, key := range cases {
+		host, err := createHost(provider, hostOptions{Arch: "amd64", SSHKeyName: "maintenance"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := host.Additional[key]; got != "maintenance" {
+			t.Errorf("%s: want Additional[%s] %q, but got %q", provider, key, "maintenance", got)
+		}
+	}
+}
+
+func Test_CreateHost_NoPlanForArch(t *testing.T) {
+	if _, err := createHost("digitalocean", hostOptions{Arch: "arm64"}); err == nil {
+		t.Fatalf("want error for arm64 on digitalocean")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+)
+
+// reservedIPTimeout limits how long to wait for a reserved IP to be
+// attached or released, the provider may still be detaching it from a
+// host which is being deleted
+const reservedIPTimeout = 2 * time.Minute
+
+// reservedIPs manages the addresses which a provider keeps when a host is
+// deleted, such as a DigitalOcean reserved IP or an AWS Elastic IP, so that
+// a tunnel can be recreated without its address changing
+type reservedIPs interface {
+	// Allocate reserves a new address in the region or zone where the
+	// named exit-server will be created
+	Allocate(name, region, zone string) (string, error)
+
+	// Attach moves the address to the host, once it is active
+	Attach(ip string, host *provision.ProvisionedHost) error
+
+	// Release gives the address back to the provider, once it is no
+	// longer attached to a host
+	Release(ip, region, zone string) error
+}
+
+// validateReservedIP checks the --reserved-ip and --allocate-reserved-ip
+// flags of create for the provider
+func validateReservedIP(reservedIP string, allocate bool, publicIP string, spec providerSpec) error {
+	if len(reservedIP) == 0 && !allocate {
+		return nil
+	}
+
+	if len(reservedIP) > 0 && allocate {
+		return fmt.Errorf("give either --reserved-ip or --allocate-reserved-ip, not both")
+	}
+	if spec.newReservedIPs == nil {
+		return fmt.Errorf("reserved IPs are not supported for the %s provider, use one of: %s",
+			spec.Name, strings.Join(providersWith(func(s providerSpec) bool { return s.newReservedIPs != nil }), ", "))
+	}
+	if len(reservedIP) > 0 {
+		if ip := net.ParseIP(reservedIP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("--reserved-ip must be an IPv4 address, but got: %q", reservedIP)
+		}
+	}
+	if len(publicIP) > 0 {
+		return fmt.Errorf("--public-ip cannot be combined with a reserved IP, which is used as the public IP")
+	}
+	return nil
+}
+
+// getReservedIPs creates the reserved IP client for the provider
+func getReservedIPs(provider string, creds providerCredentials) (reservedIPs, error) {
+	spec, err := getProviderSpec(provider)
+	if err != nil {
+		return nil, err
+	}
+	if spec.newReservedIPs == nil {
+		return nil, fmt.Errorf("reserved IPs are not supported for the %s provider", provider)
+	}
+	return spec.newReservedIPs(creds)
+}
+
+// releaseReservedIP releases an address, retrying until the provider has
+// detached it from a deleted host or the timeout is reached
+func releaseReservedIP(r reservedIPs, ip, region, zone string, timeout, poll time.Duration, progress io.Writer) error {
+	fmt.Fprintf(progress, "Releasing reserved IP: %s\n", ip)
+
+	return waitUntil(timeout, poll, func() (bool, error) {
+		if err := r.Release(ip, region, zone); err != nil {
+			fmt.Fprintf(progress, "Reserved IP %s is not yet released: %s\n", ip, err)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// releaseAllocatedIP releases the reserved IP which was allocated for a
+// tunnel that then failed, an address given with --reserved-ip is the
+// user's to keep
+func releaseAllocatedIP(plan *tunnelPlan, progress io.Writer) {
+	if !plan.ReservedIPAllocated || plan.reservedIPs == nil {
+		return
+	}
+	releaseOrWarn(plan.reservedIPs, plan.ReservedIP, plan.Provider, plan.Region, plan.Zone, plan.Poll, progress)
+}
+
+// releaseOrWarn releases an address when a tunnel has failed, where a
+// failure to release it is only a warning
+func releaseOrWarn(r reservedIPs, ip, provider, region, zone string, poll time.Duration, progress io.Writer) {
+	if err := releaseReservedIP(r, ip, region, zone, reservedIPTimeout, poll, progress); err != nil {
+		fmt.Fprintf(progress, "Warning: unable to release reserved IP %s, release it from the %s console: %s\n",
+			ip, provider, err)
+	}
+}
+
+// waitUntil calls done every poll interval until it returns true or an
+// error, or the timeout is reached
+func waitUntil(timeout, poll time.Duration, done func() (bool, error)) error {
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("gave up after %s", timeout)
+		}
+		time.Sleep(poll)
+	}
+}
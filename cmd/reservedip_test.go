@@ -0,0 +1,93 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+)
+
+type fakeReservedIPs struct {
+	releaseErrs []error
+	released    []string
+}
+
+func (f *fakeReservedIPs) Allocate(name, region, zone string) (string, error) {
+	return "203.0.113.10", nil
+}
+
+func (f *fakeReservedIPs) Attach(ip string, host *provision.ProvisionedHost) error {
+	return nil
+}
+
+func (f *fakeReservedIPs) Release(ip, region, zone string) error {
+	if len(f.releaseErrs) > 0 {
+		err := f.releaseErrs[0]
+		f.releaseErrs = f.releaseErrs[1:]
+		return err
+	}
+	f.released = append(f.released, ip)
+	return nil
+}
+
+func Test_ValidateReservedIP(t *testing.T) {
+	do, _ := getProviderSpec("digitalocean")
+	vultr, _ := getProviderSpec("vultr")
+
+	cases := []struct {
+		name       string
+		reservedIP string
+		allocate   bool
+		publicIP   string
+		spec       providerSpec
+		valid      bool
+	}{
+		{"none", "", false, "", vultr, true},
+		{"existing", "203.0.113.10", false, "", do, true},
+		{"allocate", "", true, "", do, true},
+		{"both", "203.0.113.10", true, "", do, false},
+		{"unsupported", "", true, "", vultr, false},
+		{"not an IPv4 address", "2001:db8::1", false, "", do, false},
+		{"with public IP", "203.0.113.10", false, "203.0.113.10", do, false},
+	}
+
+	for _, c := range cases {
+		err := validateReservedIP(c.reservedIP, c.allocate, c.publicIP, c.spec)
+		if c.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: want error", c.name)
+		}
+	}
+}
+
+func Test_ReleaseReservedIP_Retries(t *testing.T) {
+	r := &fakeReservedIPs{releaseErrs: []error{errors.New("still assigned")}}
+
+	if err := releaseReservedIP(r, "203.0.113.10", "lon1", "", time.Second, time.Millisecond, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.released) != 1 {
+		t.Errorf("want the address to be released once, but got: %v", r.released)
+	}
+}
+
+func Test_ReleaseAllocatedIP_KeepsUserAddress(t *testing.T) {
+	r := &fakeReservedIPs{}
+
+	releaseAllocatedIP(&tunnelPlan{ReservedIP: "203.0.113.10", reservedIPs: r}, io.Discard)
+	if len(r.released) != 0 {
+		t.Errorf("want an address given with --reserved-ip to be kept, but got: %v", r.released)
+	}
+
+	releaseAllocatedIP(&tunnelPlan{ReservedIP: "203.0.113.10", ReservedIPAllocated: true, reservedIPs: r}, io.Discard)
+	if len(r.released) != 1 {
+		t.Errorf("want an allocated address to be released, but got: %v", r.released)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/inlets/inletsctl/pkg/inventory"
+)
+
+// rollbackHost deletes a host which was provisioned for a tunnel that then
+// failed, and removes it from the inventory. The returned error wraps cause
+// and says whether the host was deleted, so that the user knows if anything
+// is still being billed. A reserved IP allocated for the tunnel is released
+// with the host, and the records made by --dns-provider and the tunnel's
+// firewall are removed. With --keep-on-failure they are all left in place.
+func rollbackHost(plan *tunnelPlan, hostID, ip string, inv *inventory.Inventory, progress io.Writer, cause error) error {
+	if plan.KeepOnFailure {
+		fmt.Fprintf(progress, "Keeping host %s for debugging, as --keep-on-failure was given\n", hostID)
+		return fmt.Errorf("%w (host %s was kept, delete it with: inletsctl delete %s)", cause, hostID, plan.Name)
+	}
+
+	fmt.Fprintf(progress, "Rolling back: deleting host %s from %s\n", hostID, plan.Provider)
+
+	err := plan.provisioner.Delete(provision.HostDeleteRequest{
+		ID:        hostID,
+		IP:        ip,
+		ProjectID: plan.ProjectID,
+		Zone:      plan.Zone,
+		Region:    plan.Region,
+	})
+	if err != nil {
+		fmt.Fprintf(progress, "Rollback failed: %s\n", err)
+		live := ""
+		if resources := liveResources(plan, ip); len(resources) > 0 {
+			live = ", also still live: " + strings.Join(resources, ", ")
+		}
+		return fmt.Errorf("%w (rollback failed, host %s could not be deleted: %s%s, delete it with: inletsctl delete %s)",
+			cause, hostID, err, live, plan.Name)
+	}
+
+	fmt.Fprintf(progress, "Rollback complete: host %s was deleted\n", hostID)
+	releaseAllocatedIP(plan, progress)
+	if plan.firewalls != nil {
+		removeFirewallOrWarn(plan.firewalls, plan.Firewall, plan.Provider, plan.Poll, progress)
+	}
+	if plan.dns != nil && len(ip) > 0 {
+		removeDNSRecords(plan.dns, plan.DNSProvider, plan.Domains, ip, progress)
+	}
+
+	if inv.Remove(plan.Name) {
+		if err := inv.Save(); err != nil {
+			fmt.Fprintf(progress, "Warning: unable to remove %s from the inventory: %s\n", plan.Name, err)
+		}
+	}
+
+	return fmt.Errorf("%w (rolled back, host %s was deleted)", cause, hostID)
+}
+
+// liveResources lists what was made for the tunnel besides the host, which
+// is left in place when the host cannot be deleted
+func liveResources(plan *tunnelPlan, ip string) []string {
+	var resources []string
+	if plan.ReservedIPAllocated && plan.reservedIPs != nil {
+		resources = append(resources, "reserved IP "+plan.ReservedIP)
+	}
+	if plan.firewalls != nil {
+		resources = append(resources, "firewall "+plan.Firewall)
+	}
+	if plan.dns != nil && len(ip) > 0 && len(plan.Domains) > 0 {
+		resources = append(resources, "DNS records for "+strings.Join(plan.Domains, ", "))
+	}
+	return resources
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/inlets/inletsctl/pkg/inventory"
+	"github.com/pkg/errors"
+)
+
+func makeRollbackTest(t *testing.T, p *fakeProvisioner) (*tunnelPlan, *inventory.Inventory) {
+	t.Helper()
+
+	inv, err := inventory.Load(filepath.Join(t.TempDir(), "tunnels.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Put(inventory.Tunnel{Name: "tunnel-1", Provider: "digitalocean", HostID: "1234"})
+
+	plan := &tunnelPlan{
+		Name:        "tunnel-1",
+		Provider:    "digitalocean",
+		Region:      "lon1",
+		provisioner: p,
+	}
+	return plan, inv
+}
+
+func Test_RollbackHost_Deletes(t *testing.T) {
+	p := &fakeProvisioner{}
+	plan, inv := makeRollbackTest(t, p)
+
+	cause := errors.New("status failed")
+	err := rollbackHost(plan, "1234", "192.0.2.1", inv, io.Discard, cause)
+
+	if !errors.Is(err, cause) {
+		t.Errorf("want the cause to be wrapped, but got: %s", err)
+	}
+	if !strings.Contains(err.Error(), "rolled back, host 1234 was deleted") {
+		t.Errorf("want error to report the rollback, but got: %s", err)
+	}
+	if len(p.deleted) != 1 || p.deleted[0] != "1234" {
+		t.Errorf("want host 1234 to be deleted, but got: %v", p.deleted)
+	}
+	if _, ok := inv.Get("tunnel-1"); ok {
+		t.Errorf("want tunnel-1 to be removed from the inventory")
+	}
+}
+
+func Test_RollbackHost_DeleteFails(t *testing.T) {
+	p := &fakeProvisioner{deleteErr: errors.New("403 forbidden")}
+	plan, inv := makeRollbackTest(t, p)
+
+	err := rollbackHost(plan, "1234", "192.0.2.1", inv, io.Discard, errors.New("status failed"))
+
+	want := "rollback failed, host 1234 could not be deleted: 403 forbidden, delete it with: inletsctl delete tunnel-1"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, but got: %s", want, err)
+	}
+	if _, ok := inv.Get("tunnel-1"); !ok {
+		t.Errorf("want tunnel-1 to stay in the inventory")
+	}
+}
+
+func Test_RollbackHost_DeleteFailsListsLiveResources(t *testing.T) {
+	p := &fakeProvisioner{deleteErr: errors.New("403 forbidden")}
+	plan, inv := makeRollbackTest(t, p)
+	plan.ReservedIP = "203.0.113.10"
+	plan.ReservedIPAllocated = true
+	plan.reservedIPs = &fakeReservedIPs{}
+	plan.Firewall = "inlets-tunnel-1"
+	plan.firewalls = &fakeFirewalls{}
+	plan.Domains = []string{"example.com"}
+	plan.dns = &fakeDNS{}
+
+	err := rollbackHost(plan, "1234", "203.0.113.10", inv, io.Discard, errors.New("status failed"))
+
+	want := "also still live: reserved IP 203.0.113.10, firewall inlets-tunnel-1, DNS records for example.com"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, but got: %s", want, err)
+	}
+}
+
+func Test_RollbackHost_KeepOnFailure(t *testing.T) {
+	p := &fakeProvisioner{}
+	plan, inv := makeRollbackTest(t, p)
+	plan.KeepOnFailure = true
+
+	err := rollbackHost(plan, "1234", "192.0.2.1", inv, io.Discard, errors.New("status failed"))
+
+	if len(p.deleted) != 0 {
+		t.Errorf("want no hosts deleted, but got: %v", p.deleted)
+	}
+	if !strings.Contains(err.Error(), "host 1234 was kept") {
+		t.Errorf("want error to say the host was kept, but got: %s", err)
+	}
+}
+
+func Test_ProvisionTunnel_RollsBackOnTimeout(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{{status: "new"}}}
+	plan, inv := makeRollbackTest(t, p)
+	plan.Name = "tunnel-2"
+	plan.Host = &provision.BasicHost{Name: "tunnel-2"}
+	plan.Poll = time.Millisecond * 10
+	plan.Timeout = time.Millisecond * 50
+
+	_, err := provisionTunnel(context.Background(), plan, inv, io.Discard)
+	if !errors.Is(err, errHostTimeout) {
+		t.Fatalf("want timeout error, but got: %v", err)
+	}
+	if len(p.deleted) != 1 {
+		t.Errorf("want the host to be rolled back, but got: %v", p.deleted)
+	}
+}
+
+func Test_ProvisionTunnel_KeepsHostWhenReadinessMayBeBlocked(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{{status: "active"}}}
+	plan, inv := makeRollbackTest(t, p)
+	plan.Name = "tunnel-2"
+	plan.Host = &provision.BasicHost{Name: "tunnel-2"}
+	plan.Poll = time.Millisecond * 10
+	plan.Timeout = time.Second
+	plan.ReadyTimeout = time.Millisecond * 50
+	plan.ReadyMayBeBlocked = true
+
+	summary, err := provisionTunnel(context.Background(), plan, inv, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "host 1234 was kept") {
+		t.Fatalf("want an error saying the host was kept, but got: %v", err)
+	}
+	if summary == nil {
+		t.Errorf("want a summary for the host which was kept")
+	}
+	if len(p.deleted) != 0 {
+		t.Errorf("want no hosts deleted, but got: %v", p.deleted)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// scalewayFirewalls manages Scaleway security groups. cloud-provision
+// creates the server in the project's default security group, which is
+// replaced with one for the tunnel.
+type scalewayFirewalls struct {
+	api  *instance.API
+	zone scw.Zone
+}
+
+func newScalewayFirewalls(accessKey, secretKey, organisationID, region string) (*scalewayFirewalls, error) {
+	api, zone, err := newScalewayInstanceAPI(accessKey, secretKey, organisationID, region)
+	if err != nil {
+		return nil, err
+	}
+	return &scalewayFirewalls{api: api, zone: zone}, nil
+}
+
+// newScalewayInstanceAPI creates a client for the zone that cloud-provision
+// creates servers in, which is given as the region
+func newScalewayInstanceAPI(accessKey, secretKey, organisationID, region string) (*instance.API, scw.Zone, error) {
+	if len(region) == 0 {
+		region = "fr-par-1"
+	}
+	zone, err := scw.ParseZone(region)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := scw.NewClient(
+		scw.WithAuth(accessKey, secretKey),
+		scw.WithDefaultOrganizationID(organisationID),
+		scw.WithDefaultZone(zone),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return instance.NewAPI(client), zone, nil
+}
+
+func (f *scalewayFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	group, err := f.api.CreateSecurityGroup(&instance.CreateSecurityGroupRequest{
+		Zone:                  f.zone,
+		Name:                  name,
+		Description:           "Firewall for the inlets exit-server",
+		Tags:                  []string{"inlets"},
+		Stateful:              true,
+		InboundDefaultPolicy:  instance.SecurityGroupPolicyDrop,
+		OutboundDefaultPolicy: instance.SecurityGroupPolicyAccept,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		for _, cidr := range rule.Sources {
+			_, source, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return err
+			}
+
+			for _, ports := range rule.Ports {
+				req := &instance.CreateSecurityGroupRuleRequest{
+					Zone:            f.zone,
+					SecurityGroupID: group.SecurityGroup.ID,
+					Protocol:        instance.SecurityGroupRuleProtocolTCP,
+					Direction:       instance.SecurityGroupRuleDirectionInbound,
+					Action:          instance.SecurityGroupRuleActionAccept,
+					IPRange:         scw.IPNet{IPNet: *source},
+				}
+
+				// The last port is only given for a range
+				from, to := splitPorts(ports)
+				if req.DestPortFrom, err = parsePort(from); err != nil {
+					return err
+				}
+				if to != from {
+					if req.DestPortTo, err = parsePort(to); err != nil {
+						return err
+					}
+				}
+
+				if _, err := f.api.CreateSecurityGroupRule(req); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	_, err = f.api.UpdateServer(&instance.UpdateServerRequest{
+		Zone:          f.zone,
+		ServerID:      host.ID,
+		SecurityGroup: &instance.SecurityGroupTemplate{ID: group.SecurityGroup.ID},
+	})
+	return err
+}
+
+// Remove deletes the security group, which Scaleway only allows once no
+// server is in it
+func (f *scalewayFirewalls) Remove(name string) error {
+	groups, err := f.api.ListSecurityGroups(&instance.ListSecurityGroupsRequest{
+		Zone: f.zone,
+		Name: &name,
+	}, scw.WithAllPages())
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups.SecurityGroups {
+		if group.Name != name {
+			continue
+		}
+		if err := f.api.DeleteSecurityGroup(&instance.DeleteSecurityGroupRequest{
+			Zone:            f.zone,
+			SecurityGroupID: group.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scalewayPortTags adds an inlets-ports tag to the tags of the server
+type scalewayPortTags struct {
+	api  *instance.API
+	zone scw.Zone
+}
+
+func newScalewayPortTags(accessKey, secretKey, organisationID, region string) (*scalewayPortTags, error) {
+	api, zone, err := newScalewayInstanceAPI(accessKey, secretKey, organisationID, region)
+	if err != nil {
+		return nil, err
+	}
+	return &scalewayPortTags{api: api, zone: zone}, nil
+}
+
+func (t *scalewayPortTags) Tag(hostID string, ports []int) error {
+	server, err := t.api.GetServer(&instance.GetServerRequest{Zone: t.zone, ServerID: hostID})
+	if err != nil {
+		return err
+	}
+
+	tags := append(server.Server.Tags, portsTagValue(ports))
+	_, err = t.api.UpdateServer(&instance.UpdateServerRequest{
+		Zone:     t.zone,
+		ServerID: hostID,
+		Tags:     &tags,
+	})
+	return err
+}
+
+// parsePort parses a port of a firewall rule
+func parsePort(port string) (*uint32, error) {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	res := uint32(n)
+	return &res, nil
+}
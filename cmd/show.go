@@ -0,0 +1,163 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/inlets/inletsctl/pkg/inventory"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	inletsCmd.AddCommand(showCmd)
+	showCmd.Flags().StringP("output", "o", "table", "Output format - table or json")
+}
+
+// showCmd represents the show sub command
+var showCmd = &cobra.Command{
+	Use:   "show [NAME]",
+	Short: "Show the tunnels recorded by inletsctl",
+	Long: `Show the details of an exit-server created by inletsctl, including its
+IP address and auth token. When no name is given, all of the tunnels in the
+local inventory are listed.
+
+The inventory is kept in $HOME/.inletsctl/tunnels.json, or in the directory
+given by the INLETSCTL_HOME environment variable.`,
+	Example: `  inletsctl show
+  inletsctl show tunnel-richardcase
+  inletsctl show tunnel-richardcase --output json
+`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          runShow,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return errors.Wrap(err, "failed to get 'output' value.")
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("--output must be table or json")
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if output == "json" {
+			return printJSON(os.Stdout, inv.Tunnels)
+		}
+		return printTunnelsTable(os.Stdout, inv.Tunnels)
+	}
+
+	tunnel, ok := inv.Get(args[0])
+	if !ok {
+		return fmt.Errorf("no tunnel named %q found in the inventory", args[0])
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, tunnel)
+	}
+	return printTunnel(os.Stdout, tunnel)
+}
+
+// loadInventory loads the local inventory of tunnels from its default
+// location
+func loadInventory() (*inventory.Inventory, error) {
+	path, err := inventory.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return inventory.Load(path)
+}
+
+// saveTunnel records the tunnel in the inventory. A failure is only
+// reported as a warning, since the host exists by the time this is called.
+func saveTunnel(inv *inventory.Inventory, tunnel inventory.Tunnel) {
+	inv.Put(tunnel)
+	if err := inv.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to record tunnel %s in the inventory: %s\n", tunnel.Name, err)
+	}
+}
+
+func printTunnel(w io.Writer, tunnel inventory.Tunnel) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", tunnel.Name)
+	fmt.Fprintf(tw, "Provider:\t%s\n", tunnel.Provider)
+	fmt.Fprintf(tw, "Region:\t%s\n", valueOrDash(tunnel.Region))
+	if len(tunnel.Zone) > 0 {
+		fmt.Fprintf(tw, "Zone:\t%s\n", tunnel.Zone)
+	}
+	if len(tunnel.ProjectID) > 0 {
+		fmt.Fprintf(tw, "Project:\t%s\n", tunnel.ProjectID)
+	}
+	fmt.Fprintf(tw, "Host ID:\t%s\n", tunnel.HostID)
+	fmt.Fprintf(tw, "IP:\t%s\n", valueOrDash(tunnel.IP))
+	if len(tunnel.ReservedIP) > 0 {
+		fmt.Fprintf(tw, "Reserved IP:\t%s\n", tunnel.ReservedIP)
+	}
+	fmt.Fprintf(tw, "Mode:\t%s\n", tunnel.Mode)
+	if len(tunnel.Domains) > 0 {
+		fmt.Fprintf(tw, "Domains:\t%s\n", strings.Join(tunnel.Domains, ", "))
+	}
+	if len(tunnel.DNSProvider) > 0 {
+		fmt.Fprintf(tw, "DNS provider:\t%s\n", tunnel.DNSProvider)
+	}
+	if len(tunnel.Ports) > 0 {
+		fmt.Fprintf(tw, "Ports:\t%s\n", joinPorts(tunnel.Ports, ", "))
+	}
+	if len(tunnel.Upstream) > 0 {
+		fmt.Fprintf(tw, "Upstream:\t%s\n", tunnel.Upstream)
+	}
+	if len(tunnel.Firewall) > 0 {
+		fmt.Fprintf(tw, "Firewall:\t%s\n", tunnel.Firewall)
+	}
+	if len(tunnel.SSHUser) > 0 {
+		fmt.Fprintf(tw, "SSH:\tssh %s@%s\n", tunnel.SSHUser, valueOrDash(tunnel.IP))
+	}
+	fmt.Fprintf(tw, "inlets-pro version:\t%s\n", tunnel.InletsProVersion)
+	fmt.Fprintf(tw, "Auth-token:\t%s\n", tunnel.Token)
+	fmt.Fprintf(tw, "Created:\t%s\n", tunnel.CreatedAt.Local().Format(time.RFC1123))
+	return tw.Flush()
+}
+
+func printTunnelsTable(w io.Writer, tunnels []inventory.Tunnel) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPROVIDER\tREGION\tIP\tMODE\tCREATED")
+	for _, tunnel := range tunnels {
+		region := tunnel.Region
+		if len(tunnel.Zone) > 0 {
+			region = tunnel.Zone
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			tunnel.Name,
+			tunnel.Provider,
+			valueOrDash(region),
+			valueOrDash(tunnel.IP),
+			tunnel.Mode,
+			tunnel.CreatedAt.Local().Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
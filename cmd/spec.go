@@ -0,0 +1,334 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// tunnelSpec holds the values for one tunnel read from a spec file, keyed
+// by the name of the inletsctl create flag that each one sets. The special
+// "name" key gives the name of the exit-server.
+type tunnelSpec map[string]interface{}
+
+// specOnlyFields are keys accepted in a spec file which are not flags
+var specOnlyFields = []string{"name"}
+
+// specExcludedFlags are flags of inletsctl create which cannot be set from
+// within a spec file, as they are read once for every tunnel in the file
+var specExcludedFlags = []string{"file", "output", "dry-run"}
+
+// loadTunnelSpecs reads a YAML or JSON spec file which either describes a
+// single tunnel at the top level, or a list of them under "tunnels". Each
+// tunnel is validated against the flags of inletsctl create.
+func loadTunnelSpecs(path string, flags *pflag.FlagSet) ([]tunnelSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseSpecDocument(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []tunnelSpec
+	if tunnels, ok := doc["tunnels"]; ok {
+		if len(doc) > 1 {
+			return nil, fmt.Errorf("%s: \"tunnels\" cannot be combined with other top-level fields", path)
+		}
+
+		items, ok := tunnels.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: tunnels: must be a list", path)
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("%s: tunnels: must contain at least one tunnel", path)
+		}
+
+		for i, item := range items {
+			spec, err := toTunnelSpec(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: tunnels[%d]: %w", path, i, err)
+			}
+			specs = append(specs, spec)
+		}
+	} else {
+		specs = []tunnelSpec{doc}
+	}
+
+	names := map[string]int{}
+	for i, spec := range specs {
+		field := ""
+		if len(specs) > 1 {
+			field = fmt.Sprintf("tunnels[%d].", i)
+		}
+
+		if err := validateTunnelSpec(spec, flags, field); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if name, ok := spec["name"].(string); ok {
+			if prev, exists := names[name]; exists {
+				return nil, fmt.Errorf("%s: %sname: %q is already used by tunnels[%d]", path, field, name, prev)
+			}
+			names[name] = i
+		}
+	}
+
+	return specs, nil
+}
+
+// parseSpecDocument decodes JSON when the file has a .json extension,
+// and YAML otherwise
+func parseSpecDocument(path string, data []byte) (tunnelSpec, error) {
+	var doc interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON: %w", path, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: invalid YAML: %w", path, err)
+		}
+	}
+
+	if doc == nil {
+		return nil, fmt.Errorf("%s: the spec file is empty", path)
+	}
+
+	spec, err := toTunnelSpec(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// toTunnelSpec converts a decoded document into a tunnelSpec, YAML
+// decodes maps with interface{} keys whilst JSON uses strings.
+func toTunnelSpec(v interface{}) (tunnelSpec, error) {
+	spec := tunnelSpec{}
+	switch m := v.(type) {
+	case map[string]interface{}:
+		for k, val := range m {
+			spec[k] = val
+		}
+	case map[interface{}]interface{}:
+		for k, val := range m {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("field names must be strings, but got: %v", k)
+			}
+			spec[key] = val
+		}
+	default:
+		return nil, fmt.Errorf("expected a map of fields, but got: %T", v)
+	}
+	return spec, nil
+}
+
+// validateTunnelSpec checks that each field names a flag of inletsctl
+// create and holds a value of the right type. Errors are prefixed with
+// the path of the offending field.
+func validateTunnelSpec(spec tunnelSpec, flags *pflag.FlagSet, prefix string) error {
+	for _, key := range spec.keys() {
+		value := spec[key]
+		field := prefix + key
+
+		if contains(specOnlyFields, key) {
+			if s, ok := value.(string); !ok || len(s) == 0 {
+				return fmt.Errorf("%s: must be a non-empty string", field)
+			}
+			continue
+		}
+
+		flag := flags.Lookup(key)
+		if flag == nil {
+			return fmt.Errorf("%s: unknown field, fields are named after the flags of inletsctl create", field)
+		}
+		if contains(specExcludedFlags, key) {
+			return fmt.Errorf("%s: cannot be set in a spec file, give --%s to inletsctl create instead", field, key)
+		}
+
+		if _, err := specValues(flag, value); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+	}
+
+	if provider, ok := spec["provider"].(string); ok && !isKnownProvider(provider) {
+		return fmt.Errorf("%sprovider: unknown provider %q", prefix, provider)
+	}
+
+	if issuer, ok := spec["letsencrypt-issuer"].(string); ok && issuer != "prod" && issuer != "staging" {
+		return fmt.Errorf("%sletsencrypt-issuer: must be \"prod\" or \"staging\", but got %q", prefix, issuer)
+	}
+
+	if tcp, ok := spec["tcp"].(bool); ok && tcp {
+		if _, ok := spec["letsencrypt-domain"]; ok {
+			return fmt.Errorf("%stcp: cannot be combined with letsencrypt-domain", prefix)
+		}
+	}
+
+	return nil
+}
+
+// specValues converts a value from a spec file into the string form
+// accepted by the flag's Set method.
+func specValues(flag *pflag.Flag, value interface{}) ([]string, error) {
+	switch flag.Value.Type() {
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return []string{fmt.Sprint(b)}, nil
+
+	case "duration":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a duration such as \"2s\"")
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("must be a duration such as \"2s\": %w", err)
+		}
+		return []string{s}, nil
+
+	case "stringArray":
+		switch v := value.(type) {
+		case string:
+			return []string{v}, nil
+		case []interface{}:
+			var out []string
+			for i, item := range v {
+				s, ok := scalarString(item)
+				if !ok {
+					return nil, fmt.Errorf("item %d must be a string", i)
+				}
+				out = append(out, s)
+			}
+			return out, nil
+		default:
+			return nil, fmt.Errorf("must be a string or a list of strings")
+		}
+
+	default:
+		s, ok := scalarString(value)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		return []string{s}, nil
+	}
+}
+
+// applyTunnelSpec sets the flags named in the spec. Flags which were
+// given on the command line, as listed in userSet, take precedence and
+// are left alone. All other flags are reset to their defaults first, so
+// that values do not leak from one tunnel in the file into the next.
+func applyTunnelSpec(flags *pflag.FlagSet, spec tunnelSpec, userSet map[string]bool) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || userSet[flag.Name] {
+			return
+		}
+		err = resetFlag(flag)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range spec.keys() {
+		if contains(specOnlyFields, key) || userSet[key] {
+			continue
+		}
+
+		flag := flags.Lookup(key)
+		if flag == nil {
+			return fmt.Errorf("unknown field: %s", key)
+		}
+
+		values, err := specValues(flag, spec[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			if err := slice.Replace(values); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			flag.Changed = true
+			continue
+		}
+
+		if err := flags.Set(key, values[0]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// resetFlag restores a flag to its default value
+func resetFlag(flag *pflag.Flag) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		var values []string
+		if def := strings.Trim(flag.DefValue, "[]"); len(def) > 0 {
+			values = strings.Split(def, ",")
+		}
+		if err := slice.Replace(values); err != nil {
+			return err
+		}
+	} else if err := flag.Value.Set(flag.DefValue); err != nil {
+		return err
+	}
+
+	flag.Changed = false
+	return nil
+}
+
+// changedFlags returns the names of the flags given on the command line
+func changedFlags(flags *pflag.FlagSet) map[string]bool {
+	changed := map[string]bool{}
+	flags.Visit(func(flag *pflag.Flag) {
+		changed[flag.Name] = true
+	})
+	return changed
+}
+
+func (s tunnelSpec) keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case int, int64, float64:
+		return fmt.Sprint(val), true
+	default:
+		return "", false
+	}
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func makeSpecFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("create", pflag.ContinueOnError)
+	flags.StringP("provider", "p", "digitalocean", "")
+	flags.StringP("region", "r", "lon1", "")
+	flags.Bool("tcp", false, "")
+	flags.StringArray("letsencrypt-domain", []string{}, "")
+	flags.String("letsencrypt-issuer", "prod", "")
+	flags.DurationP("poll", "n", time.Second*2, "")
+	flags.String("file", "", "")
+	flags.StringP("output", "o", "text", "")
+	flags.Bool("dry-run", false, "")
+	return flags
+}
+
+func writeSpec(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_LoadTunnelSpecs_SingleYAML(t *testing.T) {
+	path := writeSpec(t, "tunnel.yaml", `name: tunnel-1
+provider: hetzner
+region: fsn1
+letsencrypt-domain:
+  - a.example.com
+  - b.example.com
+`)
+
+	specs, err := loadTunnelSpecs(path, makeSpecFlags())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(specs) != 1 {
+		t.Fatalf("want 1 tunnel, but got: %d", len(specs))
+	}
+	if specs[0]["name"] != "tunnel-1" {
+		t.Errorf("want name tunnel-1, but got: %v", specs[0]["name"])
+	}
+}
+
+func Test_LoadTunnelSpecs_ListJSON(t *testing.T) {
+	path := writeSpec(t, "tunnels.json", `{"tunnels": [
+  {"name": "a", "tcp": true},
+  {"name": "b", "letsencrypt-domain": "b.example.com"}
+]}`)
+
+	specs, err := loadTunnelSpecs(path, makeSpecFlags())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(specs) != 2 {
+		t.Fatalf("want 2 tunnels, but got: %d", len(specs))
+	}
+}
+
+func Test_LoadTunnelSpecs_ErrorsNameTheField(t *testing.T) {
+	cases := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"unknown field", "regoin: lon1\n", "regoin: unknown field"},
+		{"wrong type", "tcp: \"yes\"\n", "tcp: must be true or false"},
+		{"bad duration", "poll: 5\n", "poll: must be a duration"},
+		{"bad provider", "provider: aws\n", `provider: unknown provider "aws"`},
+		{"bad issuer", "letsencrypt-issuer: test\n", "letsencrypt-issuer: must be"},
+		{"excluded flag", "file: other.yaml\n", "file: cannot be set in a spec file"},
+		{"output", "output: json\n", "output: cannot be set in a spec file, give --output"},
+		{"dry-run", "tunnels:\n- name: a\n- name: b\n  dry-run: true\n", "tunnels[1].dry-run: cannot be set in a spec file"},
+		{"tcp and domains", "tcp: true\nletsencrypt-domain: a.example.com\n", "tcp: cannot be combined"},
+		{"list item", "tunnels:\n- name: a\n- name: b\n  region: [1, 2]\n", "tunnels[1].region: must be a string"},
+		{"duplicate name", "tunnels:\n- name: a\n- name: a\n", `tunnels[1].name: "a" is already used by tunnels[0]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := writeSpec(t, "tunnel.yaml", c.spec)
+			_, err := loadTunnelSpecs(path, makeSpecFlags())
+			if err == nil {
+				t.Fatalf("want error containing %q", c.want)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("want error containing %q, but got: %s", c.want, err)
+			}
+		})
+	}
+}
+
+func Test_ApplyTunnelSpec_CommandLineTakesPrecedence(t *testing.T) {
+	flags := makeSpecFlags()
+	if err := flags.Parse([]string{"--region", "ams3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := tunnelSpec{
+		"provider": "hetzner",
+		"region":   "fsn1",
+		"poll":     "5s",
+	}
+
+	if err := applyTunnelSpec(flags, spec, changedFlags(flags)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, _ := flags.GetString("region"); got != "ams3" {
+		t.Errorf("want region from the command line: ams3, but got: %s", got)
+	}
+	if got, _ := flags.GetString("provider"); got != "hetzner" {
+		t.Errorf("want provider from the spec: hetzner, but got: %s", got)
+	}
+	if got, _ := flags.GetDuration("poll"); got != time.Second*5 {
+		t.Errorf("want poll from the spec: 5s, but got: %s", got)
+	}
+	if !flags.Changed("provider") {
+		t.Errorf("want provider to be marked as changed")
+	}
+}
+
+func Test_ApplyTunnelSpec_ResetsBetweenTunnels(t *testing.T) {
+	flags := makeSpecFlags()
+	userSet := changedFlags(flags)
+
+	first := tunnelSpec{
+		"region":             "fsn1",
+		"letsencrypt-domain": []interface{}{"a.example.com", "b.example.com"},
+	}
+	if err := applyTunnelSpec(flags, first, userSet); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := flags.GetStringArray("letsencrypt-domain"); len(got) != 2 {
+		t.Fatalf("want 2 domains, but got: %v", got)
+	}
+
+	second := tunnelSpec{"tcp": true}
+	if err := applyTunnelSpec(flags, second, userSet); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := flags.GetStringArray("letsencrypt-domain"); len(got) != 0 {
+		t.Errorf("want domains to be reset, but got: %v", got)
+	}
+	if got, _ := flags.GetString("region"); got != "lon1" {
+		t.Errorf("want region to be reset to lon1, but got: %s", got)
+	}
+	if flags.Changed("region") {
+		t.Errorf("want region to be unchanged after reset")
+	}
+	if got, _ := flags.GetBool("tcp"); !got {
+		t.Errorf("want tcp to be set")
+	}
+}
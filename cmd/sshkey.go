@@ -0,0 +1,158 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// sshKeyTypes are the public key algorithms accepted by --ssh-key-file
+var sshKeyTypes = []string{
+	"ssh-ed25519",
+	"ssh-rsa",
+	"ecdsa-sha2-nistp256",
+	"ecdsa-sha2-nistp384",
+	"ecdsa-sha2-nistp521",
+	"sk-ssh-ed25519@openssh.com",
+	"sk-ecdsa-sha2-nistp256@openssh.com",
+}
+
+// sshKeys looks up the public keys which are stored with a provider, for
+// providers whose provisioner cannot attach a key when the host is created.
+// The key is written to the login user's authorized_keys by the user-data.
+type sshKeys interface {
+	// Get returns the public key with the name
+	Get(name string) (string, error)
+}
+
+// validateSSHKey checks that the provider can be given --ssh-key-name
+func validateSSHKey(keyName string, spec providerSpec) error {
+	if len(keyName) > 0 && !spec.SSHKeyName {
+		return fmt.Errorf("--ssh-key-name is not supported for the %s provider, give --ssh-key-file instead or use one of: %s",
+			spec.Name, strings.Join(providersWith(func(s providerSpec) bool { return s.SSHKeyName }), ", "))
+	}
+	return nil
+}
+
+// lookupSSHKey gets the public key named by --ssh-key-name with the
+// provider's API
+func lookupSSHKey(spec providerSpec, creds providerCredentials, name string) ([]string, error) {
+	keys, err := spec.newSSHKeys(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keys.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find --ssh-key-name %s: %w", name, err)
+	}
+	return parseSSHPublicKeys(key, "--ssh-key-name "+name)
+}
+
+// readSSHKeyFile reads the public keys from a file such as ~/.ssh/id_ed25519.pub
+func readSSHKeyFile(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read --ssh-key-file: %w", err)
+	}
+	return parseSSHPublicKeys(string(data), file)
+}
+
+// parseSSHPublicKeys parses one public key per line in the authorized_keys
+// format, without options. Blank lines and comments are skipped, and each
+// key is returned with its fields separated by a single space.
+func parseSSHPublicKeys(data, source string) ([]string, error) {
+	if strings.Contains(data, "PRIVATE KEY") {
+		return nil, fmt.Errorf("%s is a private key, give the public key instead, i.e. %s.pub", source, source)
+	}
+
+	var keys []string
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !contains(sshKeyTypes, fields[0]) {
+			return nil, fmt.Errorf("%s:%d is not a SSH public key, want one of: %s", source, i+1, strings.Join(sshKeyTypes, ", "))
+		}
+		if keyType, err := sshKeyBlobType(fields[1]); err != nil || keyType != fields[0] {
+			return nil, fmt.Errorf("%s:%d has an invalid %s key", source, i+1, fields[0])
+		}
+		keys = append(keys, strings.Join(fields, " "))
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no SSH public key", source)
+	}
+	return keys, nil
+}
+
+// sshKeyBlobType gives the algorithm encoded at the start of a base64 key,
+// which must match the algorithm named before it
+func sshKeyBlobType(encoded string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(blob) < 4 {
+		return "", fmt.Errorf("key is too short")
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if uint64(len(blob)-4) < uint64(n) {
+		return "", fmt.Errorf("key is too short")
+	}
+	return string(blob[4 : 4+n]), nil
+}
+
+// sshHome gives the home directory of the login user
+func sshHome(user string) string {
+	if user == "root" {
+		return "/root"
+	}
+	return path.Join("/home", user)
+}
+
+// makeSSHKeyCommands gives the shell commands which add the keys to the
+// user's authorized_keys. A user other than root is created with sudo when
+// the image has no such user. A key is only added once, as some providers
+// run the user-data on every boot.
+func makeSSHKeyCommands(user string, keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	home := sshHome(user)
+	authorizedKeys := home + "/.ssh/authorized_keys"
+
+	var commands []string
+	if user != "root" {
+		commands = append(commands, fmt.Sprintf(`id -u %[1]s >/dev/null 2>&1 || { useradd --create-home --shell /bin/bash %[1]s && echo "%[1]s ALL=(ALL) NOPASSWD:ALL" > /etc/sudoers.d/90-%[1]s; }`, user))
+	}
+	commands = append(commands, fmt.Sprintf("install -d -m 0700 -o %s -g %s %s/.ssh", user, user, home))
+	for _, key := range keys {
+		quoted := shellQuote(key)
+		commands = append(commands, fmt.Sprintf("grep -qxF %s %s 2>/dev/null || echo %s >> %s", quoted, authorizedKeys, quoted, authorizedKeys))
+	}
+	commands = append(commands, fmt.Sprintf("chown %s:%s %s && chmod 0600 %s", user, user, authorizedKeys, authorizedKeys))
+	return commands
+}
+
+// addSSHKeyCommands inserts the commands after the interpreter line of a
+// bash user-data script, so that the keys are installed even when the
+// rest of the script fails
+func addSSHKeyCommands(script string, commands []string) string {
+	if len(commands) == 0 {
+		return script
+	}
+
+	shebang, rest, _ := strings.Cut(script, "\n")
+	return shebang + "\n" + strings.Join(commands, "\n") + "\n\n" + rest
+}
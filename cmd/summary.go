@@ -0,0 +1,207 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tunnelSummary describes an exit-server once it has been created
+type tunnelSummary struct {
+	Name        string   `json:"name" yaml:"name"`
+	HostID      string   `json:"host_id" yaml:"host_id"`
+	IP          string   `json:"ip" yaml:"ip"`
+	ReservedIP  string   `json:"reserved_ip,omitempty" yaml:"reserved_ip,omitempty"`
+	Provider    string   `json:"provider" yaml:"provider"`
+	Region      string   `json:"region" yaml:"region"`
+	Zone        string   `json:"zone,omitempty" yaml:"zone,omitempty"`
+	Mode        string   `json:"mode" yaml:"mode"`
+	Domains     []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	DNSProvider string   `json:"dns_provider,omitempty" yaml:"dns_provider,omitempty"`
+
+	// Certificates is the status of each of Domains, it is empty when the
+	// check was skipped
+	Certificates []domainStatus `json:"certificates,omitempty" yaml:"certificates,omitempty"`
+
+	ControlPort      int    `json:"control_port" yaml:"control_port"`
+	Ports            []int  `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Upstream         string `json:"upstream" yaml:"upstream"`
+	Token            string `json:"token" yaml:"token"`
+	InletsProVersion string `json:"inlets_pro_version" yaml:"inlets_pro_version"`
+	ClientCommand    string `json:"client_command" yaml:"client_command"`
+
+	// SSHUser logs in with the keys given by --ssh-key-file and
+	// --ssh-key-name, it is empty when neither was given
+	SSHUser string `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty"`
+
+	// ClientArtifacts is the directory holding the files written by
+	// --client-artifacts
+	ClientArtifacts string `json:"client_artifacts,omitempty" yaml:"client_artifacts,omitempty"`
+
+	// TimeToReady is measured from the provisioning request until inlets-pro
+	// was serving, it is empty when the readiness check was skipped
+	TimeToReady string `json:"time_to_ready,omitempty" yaml:"time_to_ready,omitempty"`
+}
+
+func (s tunnelSummary) envFields() []envField {
+	return []envField{
+		{"INLETS_NAME", s.Name},
+		{"INLETS_HOST_ID", s.HostID},
+		{"INLETS_IP", s.IP},
+		{"INLETS_RESERVED_IP", s.ReservedIP},
+		{"INLETS_PROVIDER", s.Provider},
+		{"INLETS_REGION", s.Region},
+		{"INLETS_ZONE", s.Zone},
+		{"INLETS_MODE", s.Mode},
+		{"INLETS_DOMAINS", strings.Join(s.Domains, ",")},
+		{"INLETS_DNS_PROVIDER", s.DNSProvider},
+		{"INLETS_CERTIFICATES", formatDomainStatuses(s.Certificates)},
+		{"INLETS_CONTROL_PORT", fmt.Sprint(s.ControlPort)},
+		{"INLETS_PORTS", joinPorts(s.Ports, ",")},
+		{"INLETS_UPSTREAM", s.Upstream},
+		{"INLETS_TOKEN", s.Token},
+		{"INLETS_PRO_VERSION", s.InletsProVersion},
+		{"INLETS_CLIENT_COMMAND", s.ClientCommand},
+		{"INLETS_SSH_USER", s.SSHUser},
+		{"INLETS_CLIENT_ARTIFACTS", s.ClientArtifacts},
+		{"INLETS_TIME_TO_READY", s.TimeToReady},
+	}
+}
+
+// The upstreams that clients are given to connect to when --upstream is
+// not set, along with the ports exposed by a TCP tunnel without --tcp-ports
+const (
+	defaultHTTPUpstream = "http://127.0.0.1:8080"
+	defaultTCPUpstream  = "127.0.0.1"
+	defaultTCPPorts     = "2222"
+)
+
+// defaultUpstream gives the upstream for a tunnel of the given mode
+func defaultUpstream(mode string) string {
+	if mode == "tcp" {
+		return defaultTCPUpstream
+	}
+	return defaultHTTPUpstream
+}
+
+// clientPorts gives the ports for the client of a TCP tunnel
+func clientPorts(ports []int) string {
+	if len(ports) == 0 {
+		return defaultTCPPorts
+	}
+	return joinPorts(ports, ",")
+}
+
+// makeClientCommand gives the inlets-pro client command to connect to
+// the exit-server
+func makeClientCommand(mode, ip string, controlPort int, token, upstream string, ports []int) string {
+	if mode == "tcp" {
+		return fmt.Sprintf(`inlets-pro tcp client --url "wss://%s:%d" \
+  --token "%s" \
+  --upstream %s \
+  --ports %s`, ip, controlPort, token, upstream, clientPorts(ports))
+	}
+
+	return fmt.Sprintf(`inlets-pro http client --url "wss://%s:%d" \
+  --token "%s" \
+  --upstream %s`, ip, controlPort, token, upstream)
+}
+
+// printSummary writes the human readable summary of the exit-server
+func printSummary(w io.Writer, s tunnelSummary) {
+	if len(s.TimeToReady) > 0 {
+		fmt.Fprintf(w, "Ready in %s\n\n", s.TimeToReady)
+	}
+
+	ip := s.IP
+	if len(s.ReservedIP) > 0 {
+		ip += " (reserved)"
+	}
+
+	if s.Mode == "tcp" {
+		fmt.Fprintf(w, `inlets TCP (%s) server summary:
+  IP: %s
+`, s.InletsProVersion, ip)
+		if len(s.Ports) > 0 {
+			fmt.Fprintf(w, "  Ports: %s\n", joinPorts(s.Ports, ", "))
+		}
+		fmt.Fprintf(w, "  Auth-token: %s\n", s.Token)
+	} else {
+		fmt.Fprintf(w, `inlets HTTPS (%s) server summary:
+  IP: %s
+  HTTPS Domains: %v
+`, s.InletsProVersion, ip, s.Domains)
+		if len(s.DNSProvider) > 0 {
+			fmt.Fprintf(w, "  DNS records: %s\n", s.DNSProvider)
+		}
+		if len(s.Certificates) > 0 {
+			fmt.Fprintln(w, "  Certificates:")
+			for _, c := range s.Certificates {
+				if c.Certificate {
+					fmt.Fprintf(w, "    %s: valid until %s (%s)\n", c.Domain, c.Expires, valueOrDash(c.Issuer))
+				} else {
+					fmt.Fprintf(w, "    %s: not issued, %s\n", c.Domain, c.Error)
+				}
+			}
+		}
+		fmt.Fprintf(w, "  Auth-token: %s\n", s.Token)
+	}
+	if len(s.SSHUser) > 0 {
+		fmt.Fprintf(w, "  SSH: ssh %s@%s\n", s.SSHUser, s.IP)
+	}
+
+	var artifacts string
+	if len(s.ClientArtifacts) > 0 {
+		artifacts = fmt.Sprintf("Client artifacts for systemd, Kubernetes, Docker Compose and PowerShell:\n  %s\n\n", s.ClientArtifacts)
+	}
+
+	fmt.Fprintf(w, `
+Command:
+
+%s
+
+%sTo show the details again:
+  inletsctl show %s
+
+To delete:
+  inletsctl delete %s
+`, s.ClientCommand, artifacts, s.Name, s.Name)
+}
+
+// deleteSummary describes an exit-server which has been deleted
+type deleteSummary struct {
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Provider string `json:"provider" yaml:"provider"`
+	HostID   string `json:"host_id,omitempty" yaml:"host_id,omitempty"`
+	IP       string `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Deleted  bool   `json:"deleted" yaml:"deleted"`
+
+	ReservedIP         string `json:"reserved_ip,omitempty" yaml:"reserved_ip,omitempty"`
+	ReservedIPReleased bool   `json:"reserved_ip_released,omitempty" yaml:"reserved_ip_released,omitempty"`
+
+	// DNSRecordsRemoved are the domains whose records made by
+	// --dns-provider were removed
+	DNSRecordsRemoved []string `json:"dns_records_removed,omitempty" yaml:"dns_records_removed,omitempty"`
+
+	// Firewall is the firewall made for the tunnel by create
+	Firewall        string `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+	FirewallRemoved bool   `json:"firewall_removed,omitempty" yaml:"firewall_removed,omitempty"`
+}
+
+func (s deleteSummary) envFields() []envField {
+	return []envField{
+		{"INLETS_NAME", s.Name},
+		{"INLETS_PROVIDER", s.Provider},
+		{"INLETS_HOST_ID", s.HostID},
+		{"INLETS_IP", s.IP},
+		{"INLETS_DELETED", fmt.Sprint(s.Deleted)},
+		{"INLETS_RESERVED_IP", s.ReservedIP},
+		{"INLETS_RESERVED_IP_RELEASED", fmt.Sprint(s.ReservedIPReleased)},
+		{"INLETS_DNS_RECORDS_REMOVED", strings.Join(s.DNSRecordsRemoved, ",")},
+		{"INLETS_FIREWALL", s.Firewall},
+		{"INLETS_FIREWALL_REMOVED", fmt.Sprint(s.FirewallRemoved)},
+	}
+}
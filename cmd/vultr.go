@@ -0,0 +1,154 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/vultr/govultr/v2"
+	"golang.org/x/oauth2"
+)
+
+// vultrFirewalls manages Vultr firewall groups, which are named by their
+// description. Each rule allows one port range from one subnet, and a port
+// range is written as "from:to".
+type vultrFirewalls struct {
+	client *govultr.Client
+}
+
+func newVultrFirewalls(accessToken string) *vultrFirewalls {
+	config := &oauth2.Config{}
+	tokenSource := config.TokenSource(context.Background(), &oauth2.Token{AccessToken: accessToken})
+	return &vultrFirewalls{
+		client: govultr.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (f *vultrFirewalls) Create(name string, host *provision.ProvisionedHost, rules []firewallRule) error {
+	ctx := context.Background()
+
+	group, err := f.client.FirewallGroup.Create(ctx, &govultr.FirewallGroupReq{Description: name})
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		for _, cidr := range rule.Sources {
+			_, source, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return err
+			}
+			ipType := "v4"
+			if source.IP.To4() == nil {
+				ipType = "v6"
+			}
+			size, _ := source.Mask.Size()
+
+			for _, ports := range rule.Ports {
+				if _, err := f.client.FirewallRule.Create(ctx, group.ID, &govultr.FirewallRuleReq{
+					IPType:     ipType,
+					Protocol:   "tcp",
+					Subnet:     source.IP.String(),
+					SubnetSize: size,
+					Port:       strings.Replace(ports, "-", ":", 1),
+					Notes:      name,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	_, err = f.client.Instance.Update(ctx, host.ID, &govultr.InstanceUpdateReq{FirewallGroupID: group.ID})
+	return err
+}
+
+func (f *vultrFirewalls) Remove(name string) error {
+	ctx := context.Background()
+
+	opts := &govultr.ListOptions{PerPage: 100}
+	for {
+		groups, meta, err := f.client.FirewallGroup.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			if group.Description == name {
+				if err := f.client.FirewallGroup.Delete(ctx, group.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if meta == nil || meta.Links == nil || len(meta.Links.Next) == 0 {
+			return nil
+		}
+		opts.Cursor = meta.Links.Next
+	}
+}
+
+// vultrPortTags adds an inlets-ports tag to the tags of the instance
+type vultrPortTags struct {
+	client *govultr.Client
+}
+
+func newVultrPortTags(accessToken string) *vultrPortTags {
+	config := &oauth2.Config{}
+	tokenSource := config.TokenSource(context.Background(), &oauth2.Token{AccessToken: accessToken})
+	return &vultrPortTags{
+		client: govultr.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (t *vultrPortTags) Tag(hostID string, ports []int) error {
+	ctx := context.Background()
+
+	instance, err := t.client.Instance.Get(ctx, hostID)
+	if err != nil {
+		return err
+	}
+	_, err = t.client.Instance.Update(ctx, hostID, &govultr.InstanceUpdateReq{
+		Tags: append(instance.Tags, portsTagValue(ports)),
+	})
+	return err
+}
+
+// vultrSSHKeys looks up the keys of a Vultr account
+type vultrSSHKeys struct {
+	client *govultr.Client
+}
+
+func newVultrSSHKeys(accessToken string) *vultrSSHKeys {
+	config := &oauth2.Config{}
+	tokenSource := config.TokenSource(context.Background(), &oauth2.Token{AccessToken: accessToken})
+	return &vultrSSHKeys{
+		client: govultr.NewClient(oauth2.NewClient(context.Background(), tokenSource)),
+	}
+}
+
+func (k *vultrSSHKeys) Get(name string) (string, error) {
+	ctx := context.Background()
+
+	opts := &govultr.ListOptions{PerPage: 100}
+	for {
+		keys, meta, err := k.client.SSHKey.List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			if key.Name == name {
+				return key.SSHKey, nil
+			}
+		}
+
+		if meta == nil || meta.Links == nil || len(meta.Links.Next) == 0 {
+			return "", fmt.Errorf("no Vultr SSH key named %q", name)
+		}
+		opts.Cursor = meta.Links.Next
+	}
+}
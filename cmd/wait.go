@@ -0,0 +1,127 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+)
+
+// maxBackoff caps the delay between status requests after the cloud API
+// has rate-limited or failed a request
+const maxBackoff = time.Minute
+
+// retryableStatus matches the HTTP status codes which the cloud SDKs include
+// in their error messages for rate-limiting and server-side failures
+var retryableStatus = regexp.MustCompile(`\b(429|50[0-4])\b`)
+
+var retryableMessages = []string{
+	"too many requests",
+	"rate limit",
+	"rate_limit",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+}
+
+// errHostTimeout is returned by waitForActive when the deadline is reached
+var errHostTimeout = errors.New("timed out waiting for the host to become active")
+
+// waitForActive polls the status of the host every poll interval until it
+// is active, the timeout is reached or ctx is cancelled. Rate-limiting and
+// server errors from the cloud API are retried with exponential backoff and
+// jitter, any other error is returned straight away.
+func waitForActive(ctx context.Context, provisioner provision.Provisioner, hostID string, timeout, poll time.Duration, progress io.Writer) (*provision.ProvisionedHost, error) {
+	deadline := time.Now().Add(timeout)
+	lastStatus := "unknown"
+	failures := 0
+
+	for attempt := 1; ; attempt++ {
+		delay := poll
+		if failures > 0 {
+			delay = backoff(poll, failures)
+		}
+
+		if remaining := time.Until(deadline); delay > remaining {
+			delay = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("interrupted while waiting for host %s", hostID)
+		case <-time.After(delay):
+		}
+
+		hostStatus, err := provisioner.Status(hostID)
+		if err != nil {
+			if !isRetryable(err) {
+				return nil, err
+			}
+
+			failures++
+			fmt.Fprintf(progress, "[%d] Host: %s, retrying after error: %s\n", attempt, hostID, err)
+		} else {
+			failures = 0
+			lastStatus = hostStatus.Status
+			fmt.Fprintf(progress, "[%d] Host: %s, status: %s\n", attempt, hostStatus.ID, hostStatus.Status)
+
+			if hostStatus.Status == provision.ActiveStatus {
+				return hostStatus, nil
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w: host %s was left with status %q after %s",
+				errHostTimeout, hostID, lastStatus, timeout)
+		}
+	}
+}
+
+// backoff doubles the poll interval for each consecutive failure up to
+// maxBackoff, then picks a random delay between half and all of it so that
+// several clients do not retry in step
+func backoff(poll time.Duration, failures int) time.Duration {
+	delay := poll
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isRetryable reports whether err is a rate-limit or server-side error from
+// a cloud API. The SDKs used by the provisioners do not share an error type,
+// and some wrap the error as text, so the message is checked when no status
+// code is available.
+func isRetryable(err error) bool {
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		code := coded.StatusCode()
+		return code == 429 || code >= 500
+	}
+
+	msg := strings.ToLower(err.Error())
+	if retryableStatus.MatchString(msg) {
+		return true
+	}
+
+	for _, m := range retryableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inlets/cloud-provision/provision"
+	"github.com/pkg/errors"
+)
+
+// fakeProvisioner returns each of its statuses in turn, then the last one
+// for every further call
+type fakeProvisioner struct {
+	statuses []fakeStatus
+	calls    int
+
+	deleteErr error
+	deleted   []string
+}
+
+type fakeStatus struct {
+	status string
+	err    error
+}
+
+func (f *fakeProvisioner) Provision(host provision.BasicHost) (*provision.ProvisionedHost, error) {
+	return &provision.ProvisionedHost{ID: "1234", Status: "new"}, nil
+}
+
+func (f *fakeProvisioner) Status(id string) (*provision.ProvisionedHost, error) {
+	s := f.statuses[len(f.statuses)-1]
+	if f.calls < len(f.statuses) {
+		s = f.statuses[f.calls]
+	}
+	f.calls++
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &provision.ProvisionedHost{ID: id, IP: "192.0.2.1", Status: s.status}, nil
+}
+
+func (f *fakeProvisioner) Delete(req provision.HostDeleteRequest) error {
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	f.deleted = append(f.deleted, req.ID)
+	return nil
+}
+
+type codedError int
+
+func (e codedError) Error() string   { return fmt.Sprintf("request failed with status %d", int(e)) }
+func (e codedError) StatusCode() int { return int(e) }
+
+func Test_WaitForActive_RetriesRateLimits(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{
+		{status: "new"},
+		{err: codedError(429)},
+		{err: fmt.Errorf("could not get instance: googleapi: Error 503: backend error")},
+		{status: "active"},
+	}}
+
+	host, err := waitForActive(context.Background(), p, "1234", time.Second*5, time.Millisecond, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if host.IP != "192.0.2.1" {
+		t.Errorf("want IP 192.0.2.1, but got: %s", host.IP)
+	}
+	if p.calls != 4 {
+		t.Errorf("want 4 status calls, but got: %d", p.calls)
+	}
+}
+
+func Test_WaitForActive_OtherErrorsAreReturned(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{
+		{err: codedError(404)},
+	}}
+
+	_, err := waitForActive(context.Background(), p, "1234", time.Second*5, time.Millisecond, io.Discard)
+	if err == nil {
+		t.Fatalf("want error for a 404")
+	}
+	if p.calls != 1 {
+		t.Errorf("want no retries, but got %d calls", p.calls)
+	}
+}
+
+func Test_WaitForActive_TimeoutNamesTheHost(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{
+		{status: "new"},
+	}}
+
+	_, err := waitForActive(context.Background(), p, "1234", time.Millisecond*50, time.Millisecond*10, io.Discard)
+	if !errors.Is(err, errHostTimeout) {
+		t.Fatalf("want timeout error, but got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `host 1234 was left with status "new"`) {
+		t.Fatalf("want error naming the host, but got: %s", err)
+	}
+}
+
+func Test_WaitForActive_Interrupted(t *testing.T) {
+	p := &fakeProvisioner{statuses: []fakeStatus{
+		{status: "new"},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := waitForActive(ctx, p, "1234", time.Second*5, time.Millisecond*10, io.Discard)
+	if err == nil {
+		t.Fatalf("want error when interrupted")
+	}
+	if p.calls != 0 {
+		t.Errorf("want no status calls after the interrupt, but got: %d", p.calls)
+	}
+}
+
+func Test_Backoff_IsCapped(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		got := backoff(time.Second*2, i)
+		if got > maxBackoff {
+			t.Fatalf("want at most %s, but got: %s", maxBackoff, got)
+		}
+		if got < time.Second {
+			t.Fatalf("want at least half of the poll interval, but got: %s", got)
+		}
+	}
+}
+
+func Test_IsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{codedError(429), true},
+		{codedError(502), true},
+		{codedError(403), false},
+		{errors.New("GET https://api.digitalocean.com/v2/droplets/5001: 429 Too many requests"), true},
+		{errors.New("limit reached (rate_limit_exceeded)"), true},
+		{errors.New("GET https://api.digitalocean.com/v2/droplets/5001: 404 not found"), false},
+	}
+
+	for _, c := range cases {
+		if got := isRetryable(c.err); got != c.want {
+			t.Errorf("%q: want %v, but got %v", c.err, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CloudflareURL is the default BaseURL of Cloudflare
+const CloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare manages records in the zones which an API token can edit.
+// Records are created without Cloudflare's proxy, since the exit-server
+// terminates TLS itself.
+type Cloudflare struct {
+	Token string
+
+	// BaseURL overrides CloudflareURL
+	BaseURL string
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+	Proxied bool   `json:"proxied"`
+}
+
+func (c *Cloudflare) Upsert(ctx context.Context, name, ip string) error {
+	zoneID, recordType, records, err := c.records(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	record := cloudflareRecord{Type: recordType, Name: canonicalName(name), Content: ip, TTL: TTL}
+	if len(records) == 0 {
+		return c.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", record, nil)
+	}
+
+	if err := c.do(ctx, http.MethodPut, "/zones/"+zoneID+"/dns_records/"+records[0].ID, record, nil); err != nil {
+		return err
+	}
+	for _, r := range records[1:] {
+		if err := c.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cloudflare) Remove(ctx context.Context, name, ip string) error {
+	zoneID, _, records, err := c.records(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if r.Content != ip {
+			continue
+		}
+		if err := c.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records gives the ID of the zone of name and its records of the type
+// for ip
+func (c *Cloudflare) records(ctx context.Context, name, ip string) (string, string, []cloudflareRecord, error) {
+	recordType, err := RecordType(ip)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	zoneID, err := findZone(ctx, name, func(ctx context.Context, zone string) (string, bool, error) {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {zone}}.Encode(), nil, &zones); err != nil {
+			return "", false, err
+		}
+		if len(zones) == 0 {
+			return "", false, nil
+		}
+		return zones[0].ID, true, nil
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var records []cloudflareRecord
+	query := url.Values{"type": {recordType}, "name": {canonicalName(name)}, "per_page": {"100"}}
+	if err := c.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return "", "", nil, err
+	}
+	return zoneID, recordType, records, nil
+}
+
+// do sends a request, each response wraps its result in an envelope which
+// is decoded into out
+func (c *Cloudflare) do(ctx context.Context, method, path string, in, out interface{}) error {
+	baseURL := c.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = CloudflareURL
+	}
+
+	var res struct {
+		Result json.RawMessage `json:"result"`
+	}
+	err := doJSON(ctx, method, baseURL+path, map[string]string{"Authorization": "Bearer " + c.Token}, in, &res, func(data []byte) string {
+		var res struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		json.Unmarshal(data, &res)
+
+		var messages []string
+		for _, e := range res.Errors {
+			messages = append(messages, e.Message)
+		}
+		return strings.Join(messages, ", ")
+	})
+	if err != nil || out == nil || len(res.Result) == 0 {
+		return err
+	}
+	return json.Unmarshal(res.Result, out)
+}
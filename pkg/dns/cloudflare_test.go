@@ -0,0 +1,155 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCloudflare stands in for the zones API, with a single zone
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	zone    string
+	records []cloudflareRecord
+	nextID  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(status int, result interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": status < 300, "result": result})
+	}
+
+	if r.Header.Get("Authorization") != "Bearer cf-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"errors":  []map[string]interface{}{{"code": 9109, "message": "Invalid access token"}},
+		})
+		return
+	}
+
+	const records = "/zones/zone-1/dns_records"
+	switch {
+	case r.URL.Path == "/zones":
+		zones := []map[string]string{}
+		if r.URL.Query().Get("name") == f.zone {
+			zones = append(zones, map[string]string{"id": "zone-1", "name": f.zone})
+		}
+		reply(http.StatusOK, zones)
+
+	case r.URL.Path == records && r.Method == http.MethodGet:
+		res := []cloudflareRecord{}
+		for _, rec := range f.records {
+			if rec.Type == r.URL.Query().Get("type") && rec.Name == r.URL.Query().Get("name") {
+				res = append(res, rec)
+			}
+		}
+		reply(http.StatusOK, res)
+
+	case r.URL.Path == records && r.Method == http.MethodPost:
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = fmt.Sprint(f.nextID)
+		f.records = append(f.records, rec)
+		reply(http.StatusOK, rec)
+
+	case strings.HasPrefix(r.URL.Path, records+"/"):
+		id := strings.TrimPrefix(r.URL.Path, records+"/")
+		for i, rec := range f.records {
+			if rec.ID != id {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				reply(http.StatusOK, map[string]string{"id": id})
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&f.records[i])
+			f.records[i].ID = id
+			reply(http.StatusOK, f.records[i])
+			return
+		}
+		reply(http.StatusNotFound, nil)
+
+	default:
+		reply(http.StatusNotFound, nil)
+	}
+}
+
+func Test_Cloudflare_UpsertFindsParentZone(t *testing.T) {
+	fake := &fakeCloudflare{zone: "example.com"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := &Cloudflare{Token: "cf-token", BaseURL: srv.URL}
+	if err := c.Upsert(context.Background(), "a.b.example.com", "2001:db8::1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.records) != 1 {
+		t.Fatalf("want 1 record, but got: %v", fake.records)
+	}
+	got := fake.records[0]
+	if got.Name != "a.b.example.com" || got.Type != "AAAA" || got.Content != "2001:db8::1" || got.Proxied {
+		t.Errorf("want an unproxied AAAA record for a.b.example.com, but got: %+v", got)
+	}
+}
+
+func Test_Cloudflare_UpsertReplacesAllRecords(t *testing.T) {
+	fake := &fakeCloudflare{zone: "example.com", records: []cloudflareRecord{
+		{ID: "1", Type: "A", Name: "example.com", Content: "198.51.100.1"},
+		{ID: "2", Type: "A", Name: "example.com", Content: "198.51.100.2"},
+	}, nextID: 2}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := &Cloudflare{Token: "cf-token", BaseURL: srv.URL}
+	if err := c.Upsert(context.Background(), "example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.records) != 1 || fake.records[0].Content != "192.0.2.1" {
+		t.Errorf("want a single record for 192.0.2.1, but got: %+v", fake.records)
+	}
+}
+
+func Test_Cloudflare_RemoveLeavesOtherIP(t *testing.T) {
+	fake := &fakeCloudflare{zone: "example.com", records: []cloudflareRecord{
+		{ID: "1", Type: "A", Name: "tunnel.example.com", Content: "198.51.100.1"},
+	}, nextID: 1}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := &Cloudflare{Token: "cf-token", BaseURL: srv.URL}
+	if err := c.Remove(context.Background(), "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.records) != 1 {
+		t.Errorf("want the record for another IP to be kept, but got: %+v", fake.records)
+	}
+}
+
+func Test_Cloudflare_ErrorMessage(t *testing.T) {
+	srv := httptest.NewServer(&fakeCloudflare{zone: "example.com"})
+	defer srv.Close()
+
+	c := &Cloudflare{Token: "wrong", BaseURL: srv.URL}
+	err := c.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1")
+	if err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Errorf("want the error message from the API, but got: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DigitalOceanURL is the default BaseURL of DigitalOcean
+const DigitalOceanURL = "https://api.digitalocean.com"
+
+// DigitalOcean manages records in the domains of a DigitalOcean account
+type DigitalOcean struct {
+	Token string
+
+	// BaseURL overrides DigitalOceanURL
+	BaseURL string
+}
+
+type digitalOceanRecord struct {
+	ID   int    `json:"id,omitempty"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+	TTL  int    `json:"ttl,omitempty"`
+}
+
+func (d *DigitalOcean) Upsert(ctx context.Context, name, ip string) error {
+	zone, recordType, records, err := d.records(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	record := digitalOceanRecord{Type: recordType, Name: relativeName(name, zone), Data: ip, TTL: TTL}
+	if len(records) == 0 {
+		return d.do(ctx, http.MethodPost, "/v2/domains/"+zone+"/records", record, nil)
+	}
+
+	if err := d.do(ctx, http.MethodPut, fmt.Sprintf("/v2/domains/%s/records/%d", zone, records[0].ID), record, nil); err != nil {
+		return err
+	}
+	for _, r := range records[1:] {
+		if err := d.do(ctx, http.MethodDelete, fmt.Sprintf("/v2/domains/%s/records/%d", zone, r.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DigitalOcean) Remove(ctx context.Context, name, ip string) error {
+	zone, _, records, err := d.records(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if r.Data != ip {
+			continue
+		}
+		if err := d.do(ctx, http.MethodDelete, fmt.Sprintf("/v2/domains/%s/records/%d", zone, r.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records gives the zone of name and its records of the type for ip
+func (d *DigitalOcean) records(ctx context.Context, name, ip string) (string, string, []digitalOceanRecord, error) {
+	recordType, err := RecordType(ip)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	zone, err := findZone(ctx, name, func(ctx context.Context, zone string) (string, bool, error) {
+		err := d.do(ctx, http.MethodGet, "/v2/domains/"+zone, nil, nil)
+		if isNotFound(err) {
+			return "", false, nil
+		}
+		return zone, err == nil, err
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var res struct {
+		Records []digitalOceanRecord `json:"domain_records"`
+	}
+	query := url.Values{"type": {recordType}, "name": {canonicalName(name)}, "per_page": {"200"}}
+	if err := d.do(ctx, http.MethodGet, "/v2/domains/"+zone+"/records?"+query.Encode(), nil, &res); err != nil {
+		return "", "", nil, err
+	}
+	return zone, recordType, res.Records, nil
+}
+
+func (d *DigitalOcean) do(ctx context.Context, method, path string, in, out interface{}) error {
+	baseURL := d.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = DigitalOceanURL
+	}
+
+	return doJSON(ctx, method, baseURL+path, map[string]string{"Authorization": "Bearer " + d.Token}, in, out, func(data []byte) string {
+		var res struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &res)
+		return res.Message
+	})
+}
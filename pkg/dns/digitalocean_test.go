@@ -0,0 +1,149 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDigitalOcean stands in for the domains API, with a single domain
+type fakeDigitalOcean struct {
+	mu      sync.Mutex
+	domain  string
+	records []digitalOceanRecord
+	nextID  int
+}
+
+func (f *fakeDigitalOcean) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer do-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	prefix := "/v2/domains/" + f.domain
+	path := r.URL.Path
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "The resource you requested could not be found."})
+		return
+	}
+
+	switch {
+	case path == prefix && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"domain": map[string]string{"name": f.domain}})
+
+	case path == prefix+"/records" && r.Method == http.MethodGet:
+		var res []digitalOceanRecord
+		for _, rec := range f.records {
+			fqdn := rec.Name + "." + f.domain
+			if rec.Name == "@" {
+				fqdn = f.domain
+			}
+			if rec.Type == r.URL.Query().Get("type") && fqdn == r.URL.Query().Get("name") {
+				res = append(res, rec)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"domain_records": res})
+
+	case path == prefix+"/records" && r.Method == http.MethodPost:
+		var rec digitalOceanRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = f.nextID
+		f.records = append(f.records, rec)
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		var id int
+		fmt.Sscanf(strings.TrimPrefix(path, prefix+"/records/"), "%d", &id)
+		for i, rec := range f.records {
+			if rec.ID != id {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&f.records[i])
+			f.records[i].ID = id
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_DigitalOcean_UpsertCreatesThenUpdates(t *testing.T) {
+	fake := &fakeDigitalOcean{domain: "example.com"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	d := &DigitalOcean{Token: "do-token", BaseURL: srv.URL}
+	ctx := context.Background()
+
+	if err := d.Upsert(ctx, "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := d.Upsert(ctx, "tunnel.example.com", "192.0.2.2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.records) != 1 {
+		t.Fatalf("want 1 record, but got: %v", fake.records)
+	}
+	got := fake.records[0]
+	if got.Name != "tunnel" || got.Type != "A" || got.Data != "192.0.2.2" || got.TTL != TTL {
+		t.Errorf("want an A record for tunnel to 192.0.2.2, but got: %+v", got)
+	}
+}
+
+func Test_DigitalOcean_RemoveOnlyMatchingIP(t *testing.T) {
+	fake := &fakeDigitalOcean{domain: "example.com", records: []digitalOceanRecord{
+		{ID: 1, Type: "A", Name: "tunnel", Data: "192.0.2.1"},
+		{ID: 2, Type: "A", Name: "tunnel", Data: "198.51.100.1"},
+	}, nextID: 2}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	d := &DigitalOcean{Token: "do-token", BaseURL: srv.URL}
+	if err := d.Remove(context.Background(), "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.records) != 1 || fake.records[0].Data != "198.51.100.1" {
+		t.Errorf("want only the record for 198.51.100.1 to remain, but got: %+v", fake.records)
+	}
+}
+
+func Test_DigitalOcean_NoZone(t *testing.T) {
+	srv := httptest.NewServer(&fakeDigitalOcean{domain: "example.com"})
+	defer srv.Close()
+
+	d := &DigitalOcean{Token: "do-token", BaseURL: srv.URL}
+	err := d.Upsert(context.Background(), "tunnel.example.org", "192.0.2.1")
+	if err == nil || !strings.Contains(err.Error(), "no zone found for tunnel.example.org") {
+		t.Errorf("want no zone found error, but got: %v", err)
+	}
+}
+
+func Test_DigitalOcean_Unauthorized(t *testing.T) {
+	srv := httptest.NewServer(&fakeDigitalOcean{domain: "example.com"})
+	defer srv.Close()
+
+	d := &DigitalOcean{Token: "wrong", BaseURL: srv.URL}
+	err := d.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want a 401 APIError, but got: %v", err)
+	}
+}
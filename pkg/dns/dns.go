@@ -0,0 +1,205 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package dns creates the A and AAAA records which point the Let's Encrypt
+// domains of a tunnel at its exit-server, using the API of the DNS
+// provider which hosts each domain's zone.
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TTL is given to the records that are created, it is kept short as the
+// address changes whenever a tunnel is recreated
+const TTL = 60
+
+// Provider manages the records for a name in whichever of the provider's
+// zones holds it
+type Provider interface {
+	// Upsert points the A or AAAA record of name at ip, replacing any
+	// other addresses it had
+	Upsert(ctx context.Context, name, ip string) error
+
+	// Remove deletes ip from the record of name, a record which points
+	// elsewhere is left alone as it no longer belongs to the tunnel
+	Remove(ctx context.Context, name, ip string) error
+}
+
+// Credentials are given to New, Route 53 uses Token as the access key ID
+// along with SecretKey and SessionToken, the other providers only use Token
+type Credentials struct {
+	Token        string
+	SecretKey    string
+	SessionToken string
+}
+
+// providers makes the client for each supported provider
+var providers = map[string]func(Credentials) Provider{
+	"cloudflare": func(c Credentials) Provider {
+		return &Cloudflare{Token: c.Token}
+	},
+	"digitalocean": func(c Credentials) Provider {
+		return &DigitalOcean{Token: c.Token}
+	},
+	"hetzner": func(c Credentials) Provider {
+		return &Hetzner{Token: c.Token}
+	},
+	"route53": func(c Credentials) Provider {
+		return &Route53{AccessKeyID: c.Token, SecretAccessKey: c.SecretKey, SessionToken: c.SessionToken}
+	},
+}
+
+// Providers gives the names accepted by New, sorted
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New makes the client for the named provider
+func New(provider string, creds Credentials) (Provider, error) {
+	newProvider, ok := providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS provider %q, use one of: %s", provider, strings.Join(Providers(), ", "))
+	}
+	return newProvider(creds), nil
+}
+
+// RecordType gives "A" for an IPv4 address and "AAAA" for IPv6
+func RecordType(ip string) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address: %q", ip)
+	}
+	if parsed.To4() != nil {
+		return "A", nil
+	}
+	return "AAAA", nil
+}
+
+// zoneCandidates gives the zones which could hold name, the longest first
+// so that a delegated sub-zone is preferred to its parent. A top-level
+// domain is never a candidate.
+func zoneCandidates(name string) []string {
+	labels := strings.Split(canonicalName(name), ".")
+
+	var zones []string
+	for i := 0; i < len(labels)-1; i++ {
+		zones = append(zones, strings.Join(labels[i:], "."))
+	}
+	return zones
+}
+
+// relativeName gives name relative to its zone, with "@" for the apex
+func relativeName(name, zone string) string {
+	name = canonicalName(name)
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// canonicalName lower-cases name and removes any trailing dot
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// findZone returns the first of the candidate zones for name which the
+// provider reports it has
+func findZone[T any](ctx context.Context, name string, lookup func(ctx context.Context, zone string) (T, bool, error)) (T, error) {
+	for _, zone := range zoneCandidates(name) {
+		found, ok, err := lookup(ctx, zone)
+		if err != nil {
+			return found, err
+		}
+		if ok {
+			return found, nil
+		}
+	}
+
+	var none T
+	return none, fmt.Errorf("no zone found for %s", name)
+}
+
+// APIError is returned for a response with an unexpected status code
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d, %s", e.StatusCode, e.Message)
+}
+
+// isNotFound reports whether err is a 404 from the API
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// httpClient is used by all of the providers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// doJSON sends in as the JSON body of a request with the given headers,
+// and decodes the response into out when it is not nil. The message of a
+// failed request is found by errMessage.
+func doJSON(ctx context.Context, method, url string, headers map[string]string, in, out interface{}, errMessage func([]byte) string) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &APIError{StatusCode: res.StatusCode, Message: errMessage(data)}
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unable to parse response from %s: %w", url, err)
+		}
+	}
+	return nil
+}
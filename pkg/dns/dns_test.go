@@ -0,0 +1,104 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ZoneCandidates_LongestFirstWithoutTLD(t *testing.T) {
+	got := zoneCandidates("Tunnel.Dev.Example.com.")
+	want := []string{"tunnel.dev.example.com", "dev.example.com", "example.com"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got: %v", want, got)
+	}
+}
+
+func Test_RelativeName(t *testing.T) {
+	cases := []struct {
+		name, zone, want string
+	}{
+		{"tunnel.example.com", "example.com", "tunnel"},
+		{"a.b.example.com.", "example.com", "a.b"},
+		{"example.com", "example.com", "@"},
+	}
+
+	for _, c := range cases {
+		if got := relativeName(c.name, c.zone); got != c.want {
+			t.Errorf("%s in %s: want %q, but got: %q", c.name, c.zone, c.want, got)
+		}
+	}
+}
+
+func Test_RecordType(t *testing.T) {
+	cases := map[string]string{
+		"192.0.2.1":   "A",
+		"2001:db8::1": "AAAA",
+	}
+	for ip, want := range cases {
+		got, err := RecordType(ip)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", ip, err)
+		}
+		if got != want {
+			t.Errorf("%s: want %s, but got: %s", ip, want, got)
+		}
+	}
+
+	if _, err := RecordType("not-an-ip"); err == nil {
+		t.Errorf("want an error for an invalid address")
+	}
+}
+
+func Test_New_UnknownProvider(t *testing.T) {
+	_, err := New("gandi", Credentials{})
+	if err == nil {
+		t.Fatalf("want an error for an unknown provider")
+	}
+	if !strings.Contains(err.Error(), "cloudflare, digitalocean, hetzner, route53") {
+		t.Errorf("want the supported providers in the error, but got: %s", err)
+	}
+}
+
+func Test_WaitForAddress_PollsUntilAnswered(t *testing.T) {
+	calls := 0
+	err := waitForAddress(context.Background(), "192.0.2.1", time.Millisecond, func(ctx context.Context) ([]net.IP, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, errors.New("no such host")
+		case 2:
+			return []net.IP{net.ParseIP("198.51.100.1")}, nil
+		}
+		return []net.IP{net.ParseIP("192.0.2.1")}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("want 3 lookups, but got: %d", calls)
+	}
+}
+
+func Test_WaitForAddress_GivesUpWithLastError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := waitForAddress(ctx, "192.0.2.1", time.Millisecond, func(ctx context.Context) ([]net.IP, error) {
+		return nil, errors.New("no such host")
+	})
+	if err == nil {
+		t.Fatalf("want an error once the context is done")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "no such host") {
+		t.Errorf("want the deadline and the last lookup error, but got: %s", err)
+	}
+}
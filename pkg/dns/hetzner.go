@@ -0,0 +1,138 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// HetznerURL is the default BaseURL of Hetzner, whose DNS zones are managed
+// with the same API and token as its servers
+const HetznerURL = "https://api.hetzner.cloud/v1"
+
+// Hetzner manages the RRSets in the DNS zones of a Hetzner Cloud project
+type Hetzner struct {
+	Token string
+
+	// BaseURL overrides HetznerURL
+	BaseURL string
+}
+
+type hetznerRecord struct {
+	Value string `json:"value"`
+}
+
+type hetznerRRSet struct {
+	Name    string          `json:"name,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	TTL     int             `json:"ttl,omitempty"`
+	Records []hetznerRecord `json:"records"`
+}
+
+func (h *Hetzner) Upsert(ctx context.Context, name, ip string) error {
+	zoneID, want, rrset, err := h.rrset(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	records := []hetznerRecord{{Value: ip}}
+	if rrset == nil {
+		want.TTL = TTL
+		want.Records = records
+		return h.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%d/rrsets", zoneID), want, nil)
+	}
+	return h.do(ctx, http.MethodPost, rrsetPath(zoneID, want)+"/actions/set_records", hetznerRRSet{Records: records}, nil)
+}
+
+func (h *Hetzner) Remove(ctx context.Context, name, ip string) error {
+	zoneID, want, rrset, err := h.rrset(ctx, name, ip)
+	if err != nil || rrset == nil {
+		return err
+	}
+
+	var keep []hetznerRecord
+	for _, r := range rrset.Records {
+		if r.Value != ip {
+			keep = append(keep, r)
+		}
+	}
+
+	switch {
+	case len(keep) == len(rrset.Records):
+		return nil
+	case len(keep) == 0:
+		return h.do(ctx, http.MethodDelete, rrsetPath(zoneID, want), nil, nil)
+	default:
+		return h.do(ctx, http.MethodPost, rrsetPath(zoneID, want)+"/actions/set_records", hetznerRRSet{Records: keep}, nil)
+	}
+}
+
+// rrset gives the ID of the zone of name, the name and type of its RRSet
+// for ip, and the RRSet itself or nil when it does not exist yet
+func (h *Hetzner) rrset(ctx context.Context, name, ip string) (int64, hetznerRRSet, *hetznerRRSet, error) {
+	recordType, err := RecordType(ip)
+	if err != nil {
+		return 0, hetznerRRSet{}, nil, err
+	}
+
+	type zone struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	found, err := findZone(ctx, name, func(ctx context.Context, name string) (zone, bool, error) {
+		var res struct {
+			Zones []zone `json:"zones"`
+		}
+		if err := h.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {name}}.Encode(), nil, &res); err != nil {
+			return zone{}, false, err
+		}
+		if len(res.Zones) == 0 {
+			return zone{}, false, nil
+		}
+		return zone{ID: res.Zones[0].ID, Name: name}, true, nil
+	})
+	if err != nil {
+		return 0, hetznerRRSet{}, nil, err
+	}
+
+	want := hetznerRRSet{Name: relativeName(name, found.Name), Type: recordType}
+
+	var res struct {
+		RRSet hetznerRRSet `json:"rrset"`
+	}
+	err = h.do(ctx, http.MethodGet, rrsetPath(found.ID, want), nil, &res)
+	if isNotFound(err) {
+		return found.ID, want, nil, nil
+	}
+	if err != nil {
+		return 0, want, nil, err
+	}
+	return found.ID, want, &res.RRSet, nil
+}
+
+// rrsetPath gives the path of an RRSet from its name and type
+func rrsetPath(zoneID int64, rrset hetznerRRSet) string {
+	return fmt.Sprintf("/zones/%d/rrsets/%s/%s", zoneID, url.PathEscape(rrset.Name), rrset.Type)
+}
+
+func (h *Hetzner) do(ctx context.Context, method, path string, in, out interface{}) error {
+	baseURL := h.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = HetznerURL
+	}
+
+	return doJSON(ctx, method, baseURL+path, map[string]string{"Authorization": "Bearer " + h.Token}, in, out, func(data []byte) string {
+		var res struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &res)
+		return res.Error.Message
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHetzner stands in for the zones API, with a single zone whose
+// RRSets are keyed by "name/type"
+type fakeHetzner struct {
+	mu     sync.Mutex
+	zone   string
+	rrsets map[string]hetznerRRSet
+}
+
+func (f *fakeHetzner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]string{"code": "not_found", "message": "rrset not found"},
+		})
+	}
+
+	if r.Header.Get("Authorization") != "Bearer hcloud-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const rrsets = "/zones/42/rrsets"
+	path := r.URL.Path
+	switch {
+	case path == "/zones":
+		zones := []map[string]interface{}{}
+		if r.URL.Query().Get("name") == f.zone {
+			zones = append(zones, map[string]interface{}{"id": 42, "name": f.zone})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"zones": zones})
+
+	case path == rrsets && r.Method == http.MethodPost:
+		var rrset hetznerRRSet
+		json.NewDecoder(r.Body).Decode(&rrset)
+		f.rrsets[rrset.Name+"/"+rrset.Type] = rrset
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasSuffix(path, "/actions/set_records") && r.Method == http.MethodPost:
+		key := strings.TrimSuffix(strings.TrimPrefix(path, rrsets+"/"), "/actions/set_records")
+		rrset, ok := f.rrsets[key]
+		if !ok {
+			notFound()
+			return
+		}
+		var req hetznerRRSet
+		json.NewDecoder(r.Body).Decode(&req)
+		rrset.Records = req.Records
+		f.rrsets[key] = rrset
+
+	case strings.HasPrefix(path, rrsets+"/"):
+		key := strings.TrimPrefix(path, rrsets+"/")
+		rrset, ok := f.rrsets[key]
+		if !ok {
+			notFound()
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.rrsets, key)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"rrset": rrset})
+
+	default:
+		notFound()
+	}
+}
+
+func Test_Hetzner_UpsertCreatesApexRRSet(t *testing.T) {
+	fake := &fakeHetzner{zone: "example.com", rrsets: map[string]hetznerRRSet{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	h := &Hetzner{Token: "hcloud-token", BaseURL: srv.URL}
+	if err := h.Upsert(context.Background(), "example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := hetznerRRSet{Name: "@", Type: "A", TTL: TTL, Records: []hetznerRecord{{Value: "192.0.2.1"}}}
+	if got := fake.rrsets["@/A"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got: %+v", want, got)
+	}
+}
+
+func Test_Hetzner_UpsertSetsRecords(t *testing.T) {
+	fake := &fakeHetzner{zone: "example.com", rrsets: map[string]hetznerRRSet{
+		"tunnel/A": {Name: "tunnel", Type: "A", TTL: 3600, Records: []hetznerRecord{{Value: "198.51.100.1"}}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	h := &Hetzner{Token: "hcloud-token", BaseURL: srv.URL}
+	if err := h.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []hetznerRecord{{Value: "192.0.2.1"}}
+	if got := fake.rrsets["tunnel/A"].Records; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got: %v", want, got)
+	}
+}
+
+func Test_Hetzner_Remove(t *testing.T) {
+	fake := &fakeHetzner{zone: "example.com", rrsets: map[string]hetznerRRSet{
+		"tunnel/A": {Name: "tunnel", Type: "A", Records: []hetznerRecord{{Value: "192.0.2.1"}, {Value: "198.51.100.1"}}},
+		"other/A":  {Name: "other", Type: "A", Records: []hetznerRecord{{Value: "192.0.2.1"}}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	h := &Hetzner{Token: "hcloud-token", BaseURL: srv.URL}
+	ctx := context.Background()
+
+	if err := h.Remove(ctx, "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := h.Remove(ctx, "other.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := h.Remove(ctx, "missing.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("want no error for a missing RRSet, but got: %s", err)
+	}
+
+	want := []hetznerRecord{{Value: "198.51.100.1"}}
+	if got := fake.rrsets["tunnel/A"].Records; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v to remain, but got: %v", want, got)
+	}
+	if _, ok := fake.rrsets["other/A"]; ok {
+		t.Errorf("want the RRSet with no records left to be deleted")
+	}
+}
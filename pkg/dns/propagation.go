@@ -0,0 +1,99 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// WaitForPropagation polls each of the authoritative name servers of name
+// until they answer with ip, or ctx is done. Let's Encrypt queries the
+// authoritative servers, so there is no need to wait for caches to expire.
+func WaitForPropagation(ctx context.Context, name, ip string, poll time.Duration) error {
+	servers, err := authoritativeServers(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	for _, server := range servers {
+		err := waitForAddress(ctx, ip, poll, func(ctx context.Context) ([]net.IP, error) {
+			return lookupAt(ctx, server, name, ip)
+		})
+		if err != nil {
+			return fmt.Errorf("%s did not answer with %s for %s: %w", server, ip, name, err)
+		}
+	}
+	return nil
+}
+
+// waitForAddress calls lookup every poll interval until it gives ip
+func waitForAddress(ctx context.Context, ip string, poll time.Duration, lookup func(ctx context.Context) ([]net.IP, error)) error {
+	want := net.ParseIP(ip)
+	if want == nil {
+		return fmt.Errorf("invalid IP address: %q", ip)
+	}
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	var lastErr error
+	for {
+		addrs, err := lookup(ctx)
+		for _, addr := range addrs {
+			if addr.Equal(want) {
+				return nil
+			}
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w, last lookup: %s", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// authoritativeServers gives the name servers of the zone holding name,
+// found from the NS records of name or its closest parent
+func authoritativeServers(ctx context.Context, name string) ([]string, error) {
+	for _, zone := range zoneCandidates(name) {
+		records, err := net.DefaultResolver.LookupNS(ctx, zone)
+		if err != nil || len(records) == 0 {
+			continue
+		}
+
+		servers := make([]string, 0, len(records))
+		for _, ns := range records {
+			servers = append(servers, net.JoinHostPort(strings.TrimSuffix(ns.Host, "."), "53"))
+		}
+		return servers, nil
+	}
+	return nil, fmt.Errorf("no name servers found for %s", name)
+}
+
+// lookupAt queries the server for the addresses of name of the same
+// family as ip
+func lookupAt(ctx context.Context, server, name, ip string) ([]net.IP, error) {
+	network := "ip4"
+	if recordType, _ := RecordType(ip); recordType == "AAAA" {
+		network = "ip6"
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	return resolver.LookupIP(ctx, network, canonicalName(name))
+}
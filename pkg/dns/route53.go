@@ -0,0 +1,208 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// Route53URL is the default BaseURL of Route 53, which is a global service
+// signed for us-east-1
+const Route53URL = "https://route53.amazonaws.com"
+
+const route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+// Route53 manages the record sets in the public hosted zones of an AWS
+// account
+type Route53 struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// BaseURL overrides Route53URL
+	BaseURL string
+}
+
+type route53RecordSet struct {
+	Name            string               `xml:"Name"`
+	Type            string               `xml:"Type"`
+	TTL             int                  `xml:"TTL"`
+	ResourceRecords []route53RecordValue `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53RecordValue struct {
+	Value string `xml:"Value"`
+}
+
+type route53Change struct {
+	Action    string           `xml:"Action"`
+	RecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+func (r *Route53) Upsert(ctx context.Context, name, ip string) error {
+	zoneID, recordType, _, err := r.recordSet(ctx, name, ip)
+	if err != nil {
+		return err
+	}
+
+	return r.change(ctx, zoneID, "UPSERT", route53RecordSet{
+		Name:            canonicalName(name) + ".",
+		Type:            recordType,
+		TTL:             TTL,
+		ResourceRecords: []route53RecordValue{{Value: ip}},
+	})
+}
+
+func (r *Route53) Remove(ctx context.Context, name, ip string) error {
+	zoneID, _, set, err := r.recordSet(ctx, name, ip)
+	if err != nil || set == nil {
+		return err
+	}
+
+	var keep []route53RecordValue
+	for _, v := range set.ResourceRecords {
+		if v.Value != ip {
+			keep = append(keep, v)
+		}
+	}
+
+	switch {
+	case len(keep) == len(set.ResourceRecords):
+		return nil
+	case len(keep) == 0:
+		// A deletion must match the record set exactly
+		return r.change(ctx, zoneID, "DELETE", *set)
+	default:
+		set.ResourceRecords = keep
+		return r.change(ctx, zoneID, "UPSERT", *set)
+	}
+}
+
+// recordSet gives the ID of the hosted zone of name, and its record set
+// of the type for ip or nil when there is none
+func (r *Route53) recordSet(ctx context.Context, name, ip string) (string, string, *route53RecordSet, error) {
+	recordType, err := RecordType(ip)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	zoneID, err := findZone(ctx, name, func(ctx context.Context, zone string) (string, bool, error) {
+		// The zones are listed in order from dnsname, so the first is
+		// only the zone when its name matches
+		var res struct {
+			HostedZones []struct {
+				ID   string `xml:"Id"`
+				Name string `xml:"Name"`
+			} `xml:"HostedZones>HostedZone"`
+		}
+		query := url.Values{"dnsname": {zone}, "maxitems": {"1"}}
+		if err := r.do(ctx, http.MethodGet, "/2013-04-01/hostedzonesbyname?"+query.Encode(), nil, &res); err != nil {
+			return "", false, err
+		}
+		if len(res.HostedZones) == 0 || canonicalName(res.HostedZones[0].Name) != zone {
+			return "", false, nil
+		}
+		return strings.TrimPrefix(res.HostedZones[0].ID, "/hostedzone/"), true, nil
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var res struct {
+		RecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	query := url.Values{"name": {canonicalName(name)}, "type": {recordType}, "maxitems": {"1"}}
+	if err := r.do(ctx, http.MethodGet, "/2013-04-01/hostedzone/"+zoneID+"/rrset?"+query.Encode(), nil, &res); err != nil {
+		return "", "", nil, err
+	}
+
+	// The record sets are listed from name onwards, so the first may be
+	// for another name or type
+	if len(res.RecordSets) == 0 || canonicalName(res.RecordSets[0].Name) != canonicalName(name) || res.RecordSets[0].Type != recordType {
+		return zoneID, recordType, nil, nil
+	}
+	return zoneID, recordType, &res.RecordSets[0], nil
+}
+
+func (r *Route53) change(ctx context.Context, zoneID, action string, set route53RecordSet) error {
+	return r.do(ctx, http.MethodPost, "/2013-04-01/hostedzone/"+zoneID+"/rrset/", route53ChangeRequest{
+		Xmlns:   route53Namespace,
+		Changes: []route53Change{{Action: action, RecordSet: set}},
+	}, nil)
+}
+
+// do sends a request signed with AWS Signature Version 4, with in as its
+// XML body, and decodes the XML response into out when it is not nil
+func (r *Route53) do(ctx context.Context, method, path string, in, out interface{}) error {
+	baseURL := r.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = Route53URL
+	}
+
+	// Sign replaces the request's body with the one it was signed for
+	var body io.ReadSeeker
+	if in != nil {
+		data, err := xml.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(append([]byte(xml.Header), data...))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	signer := v4.NewSigner(credentials.NewStaticCredentials(r.AccessKeyID, r.SecretAccessKey, r.SessionToken))
+	if _, err := signer.Sign(req, body, "route53", "us-east-1", time.Now()); err != nil {
+		return err
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var errRes struct {
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(data, &errRes)
+		return &APIError{StatusCode: res.StatusCode, Message: errRes.Message}
+	}
+
+	if out != nil {
+		if err := xml.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unable to parse response from %s: %w", req.URL, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package dns
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRoute53 stands in for the Route 53 API, with a single hosted zone
+// whose record sets are keyed by "name type"
+type fakeRoute53 struct {
+	mu      sync.Mutex
+	zone    string
+	sets    map[string]route53RecordSet
+	changes []route53Change
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+		!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/route53/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Code>InvalidClientTokenId</Code><Message>The security token included in the request is invalid.</Message></Error></ErrorResponse>`)
+		return
+	}
+
+	const rrset = "/2013-04-01/hostedzone/Z123/rrset"
+	switch {
+	case r.URL.Path == "/2013-04-01/hostedzonesbyname":
+		// Zones are listed in order from dnsname, so another zone may be
+		// given
+		fmt.Fprintf(w, `<ListHostedZonesByNameResponse><HostedZones><HostedZone><Id>/hostedzone/Z123</Id><Name>%s.</Name></HostedZone></HostedZones></ListHostedZonesByNameResponse>`, f.zone)
+
+	case r.URL.Path == rrset && r.Method == http.MethodGet:
+		var res struct {
+			XMLName xml.Name           `xml:"ListResourceRecordSetsResponse"`
+			Sets    []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+		}
+		if set, ok := f.sets[r.URL.Query().Get("name")+". "+r.URL.Query().Get("type")]; ok {
+			res.Sets = append(res.Sets, set)
+		}
+		xml.NewEncoder(w).Encode(res)
+
+	case r.URL.Path == rrset+"/" && r.Method == http.MethodPost:
+		var req route53ChangeRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, c := range req.Changes {
+			f.changes = append(f.changes, c)
+			key := c.RecordSet.Name + " " + c.RecordSet.Type
+			if c.Action == "DELETE" {
+				delete(f.sets, key)
+			} else {
+				f.sets[key] = c.RecordSet
+			}
+		}
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_Route53_UpsertSignsAndUpserts(t *testing.T) {
+	fake := &fakeRoute53{zone: "example.com", sets: map[string]route53RecordSet{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	r := &Route53{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", BaseURL: srv.URL}
+	if err := r.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.changes) != 1 || fake.changes[0].Action != "UPSERT" {
+		t.Fatalf("want a single UPSERT, but got: %+v", fake.changes)
+	}
+	set := fake.changes[0].RecordSet
+	if set.Name != "tunnel.example.com." || set.Type != "A" || set.TTL != TTL ||
+		len(set.ResourceRecords) != 1 || set.ResourceRecords[0].Value != "192.0.2.1" {
+		t.Errorf("want an A record set for tunnel.example.com. to 192.0.2.1, but got: %+v", set)
+	}
+}
+
+func Test_Route53_RemoveDeletesExactRecordSet(t *testing.T) {
+	existing := route53RecordSet{Name: "tunnel.example.com.", Type: "A", TTL: 300, ResourceRecords: []route53RecordValue{{Value: "192.0.2.1"}}}
+	fake := &fakeRoute53{zone: "example.com", sets: map[string]route53RecordSet{"tunnel.example.com. A": existing}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	r := &Route53{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", BaseURL: srv.URL}
+	if err := r.Remove(context.Background(), "tunnel.example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(fake.changes) != 1 || fake.changes[0].Action != "DELETE" || fake.changes[0].RecordSet.TTL != 300 {
+		t.Errorf("want a DELETE matching the record set's TTL, but got: %+v", fake.changes)
+	}
+	if len(fake.sets) != 0 {
+		t.Errorf("want no record sets left, but got: %+v", fake.sets)
+	}
+}
+
+func Test_Route53_OtherZoneIsNotUsed(t *testing.T) {
+	fake := &fakeRoute53{zone: "example.net", sets: map[string]route53RecordSet{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	r := &Route53{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", BaseURL: srv.URL}
+	err := r.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1")
+	if err == nil || !strings.Contains(err.Error(), "no zone found") {
+		t.Errorf("want no zone found error, but got: %v", err)
+	}
+}
+
+func Test_Route53_ErrorMessage(t *testing.T) {
+	srv := httptest.NewServer(&fakeRoute53{zone: "example.com"})
+	defer srv.Close()
+
+	r := &Route53{AccessKeyID: "wrong", SecretAccessKey: "secret", BaseURL: srv.URL}
+	err := r.Upsert(context.Background(), "tunnel.example.com", "192.0.2.1")
+	if err == nil || !strings.Contains(err.Error(), "security token included in the request is invalid") {
+		t.Errorf("want the error message from the API, but got: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package env
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout is how long a credential helper command may run for,
+// which allows time to unlock a password manager
+var CommandTimeout = time.Minute
+
+// CommandFlag gives the name of the flag which runs a credential helper
+// command for a value flag, i.e. access-token-cmd for access-token
+func CommandFlag(value string) string {
+	return value + "-cmd"
+}
+
+// runCommand runs a credential helper command with the user's shell and
+// returns its standard output as the secret. stdin and stderr are passed
+// through so that the command can prompt to be unlocked. The output is
+// never included in an error.
+func runCommand(flag, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	// Children of the shell may hold stdout open after it has been killed
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("--%s did not finish within %s", flag, CommandTimeout)
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("--%s exited with status %d, see its output above", flag, exitErr.ExitCode())
+		}
+		return "", fmt.Errorf("--%s could not be run: %w", flag, err)
+	}
+
+	val := strings.TrimSpace(stdout.String())
+	if len(val) == 0 {
+		return "", fmt.Errorf("--%s printed nothing to stdout", flag)
+	}
+	return val, nil
+}
@@ -0,0 +1,63 @@
+package env
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func Test_LookupFileOrString_Command(t *testing.T) {
+	flags := pflag.FlagSet{}
+	flags.String("value-flag", "from-flag", "")
+	flags.String("value-flag-cmd", "echo from-command", "")
+
+	got, from, err := LookupFileOrString(&flags, "file-flag", "value-flag", true)
+	if err != nil {
+		t.Fatalf("got error when getting value: %s", err.Error())
+	}
+
+	if got != "from-command" {
+		t.Errorf("want: from-command, but got: %s", got)
+	}
+	if from != "--value-flag-cmd" {
+		t.Errorf("want source: --value-flag-cmd, but got: %s", from)
+	}
+}
+
+func Test_RunCommand_NonZeroExit(t *testing.T) {
+	_, err := runCommand("access-token-cmd", "echo secret-value; exit 3")
+	if err == nil {
+		t.Fatalf("expected error when the command fails")
+	}
+
+	want := "--access-token-cmd exited with status 3"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, but got: %s", want, err.Error())
+	}
+	if strings.Contains(err.Error(), "secret-value") {
+		t.Errorf("want the command's output to be left out of the error, but got: %s", err.Error())
+	}
+}
+
+func Test_RunCommand_NoOutput(t *testing.T) {
+	if _, err := runCommand("access-token-cmd", "true"); err == nil {
+		t.Errorf("expected error when the command prints nothing")
+	}
+}
+
+func Test_RunCommand_Timeout(t *testing.T) {
+	defer func(d time.Duration) { CommandTimeout = d }(CommandTimeout)
+	CommandTimeout = time.Millisecond * 100
+
+	_, err := runCommand("access-token-cmd", "exec sleep 5")
+	if err == nil {
+		t.Fatalf("expected error when the command times out")
+	}
+
+	want := "--access-token-cmd did not finish within 100ms"
+	if err.Error() != want {
+		t.Errorf("want error %q, but got: %s", want, err.Error())
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"testing"
 )
 
-func Test_GetFileOrString_NoEnvVar_File(t *testing.T) {
+func Test_LookupFileOrString_NoEnvVar_File(t *testing.T) {
 	want := "some contents in this file"
 	file := makeTempFile(want)
 
@@ -16,7 +17,7 @@ func Test_GetFileOrString_NoEnvVar_File(t *testing.T) {
 
 	flags.String("file-flag", file, "")
 
-	got, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", "TEST_INLETS_NOT_SET")
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar("TEST_INLETS_NOT_SET"))
 
 	if err != nil {
 		t.Errorf("got error when reading file: %s", err.Error())
@@ -28,13 +29,13 @@ func Test_GetFileOrString_NoEnvVar_File(t *testing.T) {
 
 }
 
-func Test_GetFileOrString_NoEnvVar_String(t *testing.T) {
+func Test_LookupFileOrString_NoEnvVar_String(t *testing.T) {
 	want := "this-value-is-set"
 	flags := pflag.FlagSet{}
 
 	flags.String("value-flag", want, "")
 
-	got, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", "TEST_INLETS_NOT_SET")
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar("TEST_INLETS_NOT_SET"))
 
 	if err != nil {
 		t.Errorf("got error when getting value: %s", err.Error())
@@ -45,10 +46,10 @@ func Test_GetFileOrString_NoEnvVar_String(t *testing.T) {
 	}
 }
 
-func Test_GetFileOrString_NoEnvVar_Nothing(t *testing.T) {
+func Test_LookupFileOrString_NoEnvVar_Nothing(t *testing.T) {
 	flags := pflag.FlagSet{}
 
-	_, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", "TEST_INLETS_NOT_SET")
+	_, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar("TEST_INLETS_NOT_SET"))
 
 	if err == nil {
 		t.Errorf("expected error when trying to get value")
@@ -56,12 +57,12 @@ func Test_GetFileOrString_NoEnvVar_Nothing(t *testing.T) {
 
 }
 
-func Test_GetFileOrString_InvalidFile(t *testing.T) {
+func Test_LookupFileOrString_InvalidFile(t *testing.T) {
 	flags := pflag.FlagSet{}
 
 	flags.String("file-flag", "/tmp/non-exists-file", "")
 
-	_, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", "TEST_INLETS_NOT_SET")
+	_, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar("TEST_INLETS_NOT_SET"))
 
 	if err == nil {
 		t.Errorf("expected error when trying to get value")
@@ -69,7 +70,7 @@ func Test_GetFileOrString_InvalidFile(t *testing.T) {
 
 }
 
-func Test_GetFileOrString_EnvVar_File(t *testing.T) {
+func Test_LookupFileOrString_EnvVar_File(t *testing.T) {
 	want := "env var file should get found"
 	file := makeTempFile(want)
 	envVarName := "WE_SHOULD_FIND_THIS"
@@ -79,7 +80,7 @@ func Test_GetFileOrString_EnvVar_File(t *testing.T) {
 	flags.String("file-flag", file, "")
 
 	os.Setenv(envVarName, file)
-	got, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", envVarName)
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar(envVarName))
 	os.Unsetenv(envVarName)
 
 	if err != nil {
@@ -91,7 +92,7 @@ func Test_GetFileOrString_EnvVar_File(t *testing.T) {
 	}
 }
 
-func Test_GetFileOrString_EnvVar_String(t *testing.T) {
+func Test_LookupFileOrString_EnvVar_String(t *testing.T) {
 	want := "we-want-this-value"
 	envVarName := "VALUE_FLAG_SHOULD_OVERRIDE_THIS"
 
@@ -100,7 +101,7 @@ func Test_GetFileOrString_EnvVar_String(t *testing.T) {
 	flags.String("value-flag", want, "")
 
 	os.Setenv(envVarName, "BLANK VALUE")
-	got, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", envVarName)
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar(envVarName))
 	os.Unsetenv(envVarName)
 
 	if err != nil {
@@ -112,7 +113,7 @@ func Test_GetFileOrString_EnvVar_String(t *testing.T) {
 	}
 }
 
-func Test_GetFileOrString_EnvVar_Nothing(t *testing.T) {
+func Test_LookupFileOrString_EnvVar_Nothing(t *testing.T) {
 	want := "this file has some contents"
 	file := makeTempFile(want)
 	envVarName := "ENV_VAR_SET_NO_FLAGS"
@@ -120,7 +121,7 @@ func Test_GetFileOrString_EnvVar_Nothing(t *testing.T) {
 	flags := pflag.FlagSet{}
 
 	os.Setenv(envVarName, file)
-	got, err := GetRequiredFileOrString(&flags, "file-flag", "value-flag", envVarName)
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar(envVarName))
 	os.Unsetenv(envVarName)
 
 	if err != nil {
@@ -132,12 +133,12 @@ func Test_GetFileOrString_EnvVar_Nothing(t *testing.T) {
 	}
 }
 
-func Test_GetFileOrString_NoVals_NotRequired(t *testing.T) {
+func Test_LookupFileOrString_NoVals_NotRequired(t *testing.T) {
 	envVarName := "NO_VALS_ENV_VAR"
 
 	flags := pflag.FlagSet{}
 
-	got, err := getFileOrString(&flags, "file-flag", "value-flag", envVarName, false)
+	got, _, err := LookupFileOrString(&flags, "file-flag", "value-flag", false, EnvVar(envVarName))
 
 	if err != nil {
 		t.Errorf("got error when getting value: %s", err.Error())
@@ -157,3 +158,48 @@ func makeTempFile(contents string) string {
 
 	return file.Name()
 }
+
+func Test_LookupFileOrString_Sources(t *testing.T) {
+	os.Setenv("LOOKUP_FIRST_NOT_SET", "")
+	os.Setenv("LOOKUP_SECOND_SET", "from-second")
+	defer os.Unsetenv("LOOKUP_SECOND_SET")
+
+	flags := pflag.FlagSet{}
+
+	got, from, err := LookupFileOrString(&flags, "file-flag", "value-flag", true, EnvVar("LOOKUP_FIRST_NOT_SET"), EnvVar("LOOKUP_SECOND_SET"))
+	if err != nil {
+		t.Errorf("got error when getting value: %s", err.Error())
+	}
+
+	if got != "from-second" {
+		t.Errorf("want: from-second, but got: %s", got)
+	}
+	if from != "$LOOKUP_SECOND_SET" {
+		t.Errorf("want source: $LOOKUP_SECOND_SET, but got: %s", from)
+	}
+}
+
+func Test_ReadINIKey(t *testing.T) {
+	contents := `[default]
+aws_access_key_id = default-key
+# aws_secret_access_key = commented-out
+
+[staging]
+aws_access_key_id=staging-key
+`
+
+	for section, want := range map[string]string{"default": "default-key", "staging": "staging-key", "missing": ""} {
+		got, err := readINIKey(strings.NewReader(contents), section, "aws_access_key_id")
+		if err != nil {
+			t.Errorf("got error when reading key: %s", err.Error())
+		}
+		if want != got {
+			t.Errorf("%s: want: %q, but got: %q", section, want, got)
+		}
+	}
+
+	got, _ := readINIKey(strings.NewReader(contents), "default", "aws_secret_access_key")
+	if got != "" {
+		t.Errorf("want commented-out key to be skipped, but got: %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// Profile is a named set of values for the flags of inletsctl create and
+// delete, such as the provider, its credentials and region. Credentials
+// can be given as values, as paths to files which hold them, or as
+// commands which print them.
+type Profile struct {
+	Provider         string `yaml:"provider,omitempty"`
+	Region           string `yaml:"region,omitempty"`
+	Zone             string `yaml:"zone,omitempty"`
+	Plan             string `yaml:"plan,omitempty"`
+	AccessToken      string `yaml:"access-token,omitempty"`
+	AccessTokenFile  string `yaml:"access-token-file,omitempty"`
+	AccessTokenCmd   string `yaml:"access-token-cmd,omitempty"`
+	SecretKey        string `yaml:"secret-key,omitempty"`
+	SecretKeyFile    string `yaml:"secret-key-file,omitempty"`
+	SecretKeyCmd     string `yaml:"secret-key-cmd,omitempty"`
+	SessionToken     string `yaml:"session-token,omitempty"`
+	SessionTokenFile string `yaml:"session-token-file,omitempty"`
+	SessionTokenCmd  string `yaml:"session-token-cmd,omitempty"`
+	OrganisationID   string `yaml:"organisation-id,omitempty"`
+	ProjectID        string `yaml:"project-id,omitempty"`
+	SubscriptionID   string `yaml:"subscription-id,omitempty"`
+	VPCID            string `yaml:"vpc-id,omitempty"`
+	SubnetID         string `yaml:"subnet-id,omitempty"`
+	Endpoint         string `yaml:"endpoint,omitempty"`
+	ConsumerKey      string `yaml:"consumer-key,omitempty"`
+	SSHKeyFile       string `yaml:"ssh-key-file,omitempty"`
+	SSHKeyName       string `yaml:"ssh-key-name,omitempty"`
+}
+
+// Config is the user's config file, which holds profiles by name
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// profileCredentials groups the flags which give a credential as a value,
+// a file or a command. The file takes precedence over the others when set,
+// so a profile must not set any of them when the user has given one.
+var profileCredentials = [][]string{
+	{"access-token", "access-token-file", "access-token-cmd"},
+	{"secret-key", "secret-key-file", "secret-key-cmd"},
+	{"session-token", "session-token-file", "session-token-cmd"},
+}
+
+// DefaultConfigPath returns the location of the config file, within the
+// directory given by INLETSCTL_HOME or $HOME/.inletsctl
+func DefaultConfigPath() (string, error) {
+	if home := os.Getenv("INLETSCTL_HOME"); len(home) > 0 {
+		return filepath.Join(home, "config.yaml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory for the config file: %w", err)
+	}
+	return filepath.Join(home, ".inletsctl", "config.yaml"), nil
+}
+
+// LoadConfig reads the config file at path, unknown fields are an error
+// so that a typo in a profile is not silently ignored
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// GetProfile returns the named profile from the config file
+func (c *Config) GetProfile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("no profile named %q, the config file has: %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// values gives the profile's values keyed by flag name, with a leading ~
+// in file paths expanded to the user's home directory
+func (p Profile) values() map[string]string {
+	values := map[string]string{
+		"provider":           p.Provider,
+		"region":             p.Region,
+		"zone":               p.Zone,
+		"plan":               p.Plan,
+		"access-token":       p.AccessToken,
+		"access-token-file":  expandHome(p.AccessTokenFile),
+		"access-token-cmd":   p.AccessTokenCmd,
+		"secret-key":         p.SecretKey,
+		"secret-key-file":    expandHome(p.SecretKeyFile),
+		"secret-key-cmd":     p.SecretKeyCmd,
+		"session-token":      p.SessionToken,
+		"session-token-file": expandHome(p.SessionTokenFile),
+		"session-token-cmd":  p.SessionTokenCmd,
+		"organisation-id":    p.OrganisationID,
+		"project-id":         p.ProjectID,
+		"subscription-id":    p.SubscriptionID,
+		"vpc-id":             p.VPCID,
+		"subnet-id":          p.SubnetID,
+		"endpoint":           p.Endpoint,
+		"consumer-key":       p.ConsumerKey,
+		"ssh-key-file":       expandHome(p.SSHKeyFile),
+		"ssh-key-name":       p.SSHKeyName,
+	}
+
+	for name, value := range values {
+		if len(value) == 0 {
+			delete(values, name)
+		}
+	}
+	return values
+}
+
+// ApplyProfile sets flags from the profile, so that the values are then
+// resolved by LookupFileOrString like any other flag. Flags which have
+// already been set take precedence, as does a credential given on the
+// command line as a value, a file or a command. Values for flags which are
+// not defined, such as plan for inletsctl delete, are skipped.
+func ApplyProfile(flags *pflag.FlagSet, profile Profile) error {
+	values := profile.values()
+
+	for _, group := range profileCredentials {
+		for _, name := range group {
+			if !flags.Changed(name) {
+				continue
+			}
+			for _, n := range group {
+				delete(values, n)
+			}
+			break
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil || flags.Changed(name) {
+			continue
+		}
+		if err := flags.Set(name, values[name]); err != nil {
+			return fmt.Errorf("profile value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
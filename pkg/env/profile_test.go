@@ -0,0 +1,107 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func Test_LoadConfig_GetProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`profiles:
+  staging:
+    provider: ec2
+    region: eu-west-2
+    access-token-file: /tmp/aws-access-key
+`), 0600)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("got error when loading config: %s", err.Error())
+	}
+
+	got, err := config.GetProfile("staging")
+	if err != nil {
+		t.Fatalf("got error when getting profile: %s", err.Error())
+	}
+
+	want := Profile{Provider: "ec2", Region: "eu-west-2", AccessTokenFile: "/tmp/aws-access-key"}
+	if want != got {
+		t.Errorf("want: %+v, but got: %+v", want, got)
+	}
+
+	if _, err := config.GetProfile("production"); err == nil {
+		t.Errorf("expected error for an unknown profile")
+	}
+}
+
+func Test_LoadConfig_UnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`profiles:
+  staging:
+    acess-token: typo
+`), 0600)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("expected error for an unknown field")
+	}
+}
+
+func Test_ApplyProfile_FlagsTakePrecedence(t *testing.T) {
+	flags := pflag.FlagSet{}
+	flags.String("provider", "digitalocean", "")
+	flags.String("region", "lon1", "")
+	flags.String("access-token", "", "")
+	flags.String("access-token-file", "", "")
+	flags.Parse([]string{"--region", "eu-west-1", "--access-token", "from-flag"})
+
+	profile := Profile{
+		Provider:        "ec2",
+		Region:          "eu-west-2",
+		AccessTokenFile: "/tmp/aws-access-key",
+		Plan:            "t3.micro",
+	}
+	if err := ApplyProfile(&flags, profile); err != nil {
+		t.Fatalf("got error when applying profile: %s", err.Error())
+	}
+
+	for name, want := range map[string]string{
+		"provider":          "ec2",
+		"region":            "eu-west-1",
+		"access-token":      "from-flag",
+		"access-token-file": "",
+	} {
+		if got, _ := flags.GetString(name); want != got {
+			t.Errorf("%s: want: %q, but got: %q", name, want, got)
+		}
+	}
+
+	got, _, err := LookupFileOrString(&flags, "access-token-file", "access-token", true, EnvVar("TEST_INLETS_NOT_SET"))
+	if err != nil {
+		t.Fatalf("got error when getting value: %s", err.Error())
+	}
+	if got != "from-flag" {
+		t.Errorf("want: from-flag, but got: %s", got)
+	}
+}
+
+func Test_ApplyProfile_ExpandsHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	flags := pflag.FlagSet{}
+	flags.String("secret-key-file", "", "")
+
+	if err := ApplyProfile(&flags, Profile{SecretKeyFile: "~/.inlets/secret-key"}); err != nil {
+		t.Fatalf("got error when applying profile: %s", err.Error())
+	}
+
+	want := filepath.Join(home, ".inlets", "secret-key")
+	if got, _ := flags.GetString("secret-key-file"); want != got {
+		t.Errorf("want: %s, but got: %s", want, got)
+	}
+}
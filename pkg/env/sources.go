@@ -0,0 +1,206 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package env
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Source is somewhere outside of the command line that a secret can be
+// read from, such as the environment variable or credentials file used
+// by a cloud provider's own SDK and CLI.
+type Source struct {
+	// Name describes the source when reporting where a secret was found,
+	// it must not include the secret itself
+	Name string
+
+	// Get returns the secret, or an empty string when the source does
+	// not have one
+	Get func() (string, error)
+
+	// Used optionally describes the source after Get has found a secret,
+	// when that is more specific than Name, such as which of several
+	// environment variables was read
+	Used func() string
+}
+
+// Describe gives the name to report for a secret found by the source
+func (s Source) Describe() string {
+	if s.Used != nil {
+		return s.Used()
+	}
+	return s.Name
+}
+
+// MarshalText gives the source's name, so that lists of sources can be
+// printed as JSON
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.Name), nil
+}
+
+// EnvVar reads the secret from an environment variable
+func EnvVar(name string) Source {
+	return Source{
+		Name: "$" + name,
+		Get: func() (string, error) {
+			return strings.TrimSpace(os.Getenv(name)), nil
+		},
+	}
+}
+
+// EnvVarFile reads the secret from the file named by an environment
+// variable, such as GOOGLE_APPLICATION_CREDENTIALS
+func EnvVarFile(name string) Source {
+	return Source{
+		Name: "the file in $" + name,
+		Get: func() (string, error) {
+			path := os.Getenv(name)
+			if len(path) == 0 {
+				return "", nil
+			}
+
+			res, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("unable to read the file in $%s: %w", name, err)
+			}
+			return strings.TrimSpace(string(res)), nil
+		},
+	}
+}
+
+// AWSCredentials reads a key such as aws_access_key_id from the shared
+// credentials file used by the AWS CLI and SDKs. The file is found from
+// AWS_SHARED_CREDENTIALS_FILE or $HOME/.aws/credentials, and the profile
+// from AWS_PROFILE or "default". A missing file or profile has no secret.
+func AWSCredentials(key string) Source {
+	return Source{
+		Name: "the AWS shared credentials file",
+		Get: func() (string, error) {
+			path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+			if len(path) == 0 {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return "", nil
+				}
+				path = filepath.Join(home, ".aws", "credentials")
+			}
+
+			profile := os.Getenv("AWS_PROFILE")
+			if len(profile) == 0 {
+				profile = "default"
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return "", nil
+				}
+				return "", err
+			}
+			defer f.Close()
+
+			value, err := readINIKey(f, profile, key)
+			if err != nil {
+				return "", fmt.Errorf("unable to read %s: %w", path, err)
+			}
+			return value, nil
+		},
+	}
+}
+
+// readINIKey finds a key within a section of an INI file, as written by
+// aws configure
+func readINIKey(r io.Reader, section, key string) (string, error) {
+	var current string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+
+		if current != section {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// LookupFileOrString reads a value from the file flag, the command given
+// by the CommandFlag of value when the flag set defines it, or the value
+// flag, and falls back to each of the sources in turn when no flag is set.
+// The file is read first, then the command is run, then the value flag is
+// used. The name of the flag or source which gave the value is returned
+// with it, and is empty when no value was found. When required is true
+// and no value is found, the error lists where one can be given.
+func LookupFileOrString(flags *pflag.FlagSet, file, value string, required bool, sources ...Source) (string, string, error) {
+	if authFile, _ := flags.GetString(file); len(authFile) > 0 {
+		res, err := os.ReadFile(authFile)
+		if err != nil {
+			return "", "", err
+		}
+		return strings.TrimSpace(string(res)), "--" + file, nil
+	}
+
+	command := CommandFlag(value)
+	if flags.Lookup(command) != nil {
+		if c, _ := flags.GetString(command); len(c) > 0 {
+			val, err := runCommand(command, c)
+			if err != nil {
+				return "", "", err
+			}
+			return val, "--" + command, nil
+		}
+	}
+
+	if flagVal, _ := flags.GetString(value); len(flagVal) > 0 {
+		return flagVal, "--" + value, nil
+	}
+
+	for _, source := range sources {
+		val, err := source.Get()
+		if err != nil {
+			return "", "", err
+		}
+		if len(val) > 0 {
+			return val, source.Describe(), nil
+		}
+	}
+
+	if required {
+		names := []string{"--" + file}
+		if flags.Lookup(command) != nil {
+			names = append(names, "--"+command)
+		}
+		names = append(names, "--"+value)
+
+		msg := fmt.Sprintf("give a value for %s", strings.Join(names, ", "))
+		if len(sources) > 0 {
+			var from []string
+			for _, source := range sources {
+				from = append(from, source.Name)
+			}
+			msg += fmt.Sprintf(", or set one of: %s", strings.Join(from, ", "))
+		}
+		return "", "", errors.New(msg)
+	}
+
+	return "", "", nil
+}
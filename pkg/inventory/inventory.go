@@ -0,0 +1,161 @@
+// Copyright (c) Inlets Author(s) 2023. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package inventory keeps a local record of the exit-servers created by
+// inletsctl, so that they can be found and managed by name later on.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Tunnel is the record kept for each exit-server
+type Tunnel struct {
+	Name             string    `json:"name"`
+	Provider         string    `json:"provider"`
+	Region           string    `json:"region,omitempty"`
+	Zone             string    `json:"zone,omitempty"`
+	ProjectID        string    `json:"project_id,omitempty"`
+	HostID           string    `json:"host_id"`
+	IP               string    `json:"ip,omitempty"`
+	Mode             string    `json:"mode"`
+	Domains          []string  `json:"domains,omitempty"`
+	DNSProvider      string    `json:"dns_provider,omitempty"`
+	Ports            []int     `json:"ports,omitempty"`
+	Upstream         string    `json:"upstream,omitempty"`
+	ReservedIP       string    `json:"reserved_ip,omitempty"`
+	Firewall         string    `json:"firewall,omitempty"`
+	SSHUser          string    `json:"ssh_user,omitempty"`
+	InletsProVersion string    `json:"inlets_pro_version"`
+	Token            string    `json:"token"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Inventory is the set of tunnels recorded in a state file
+type Inventory struct {
+	Tunnels []Tunnel `json:"tunnels"`
+
+	path string
+}
+
+// DefaultPath returns the location of the state file, within the
+// directory given by INLETSCTL_HOME or $HOME/.inletsctl
+func DefaultPath() (string, error) {
+	if home := os.Getenv("INLETSCTL_HOME"); len(home) > 0 {
+		return filepath.Join(home, "tunnels.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory for the inventory: %w", err)
+	}
+	return filepath.Join(home, ".inletsctl", "tunnels.json"), nil
+}
+
+// Load reads the inventory from path, a missing file gives an
+// empty inventory which will be created on Save.
+func Load(path string) (*Inventory, error) {
+	inv := &Inventory{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return inv, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, inv); err != nil {
+		return nil, fmt.Errorf("unable to parse inventory %s: %w", path, err)
+	}
+	return inv, nil
+}
+
+// Get returns the tunnel with the given name
+func (i *Inventory) Get(name string) (Tunnel, bool) {
+	for _, t := range i.Tunnels {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tunnel{}, false
+}
+
+// Find returns the tunnel for a host on the given provider, by its
+// ID or IP address
+func (i *Inventory) Find(provider, hostID, ip string) (Tunnel, bool) {
+	for _, t := range i.Tunnels {
+		if t.Provider != provider {
+			continue
+		}
+		if (len(hostID) > 0 && t.HostID == hostID) || (len(ip) > 0 && t.IP == ip) {
+			return t, true
+		}
+	}
+	return Tunnel{}, false
+}
+
+// Put adds the tunnel, or replaces the record with the same name
+func (i *Inventory) Put(tunnel Tunnel) {
+	for n, t := range i.Tunnels {
+		if t.Name == tunnel.Name {
+			i.Tunnels[n] = tunnel
+			return
+		}
+	}
+	i.Tunnels = append(i.Tunnels, tunnel)
+}
+
+// Remove deletes the tunnel with the given name and reports whether it
+// was found
+func (i *Inventory) Remove(name string) bool {
+	for n, t := range i.Tunnels {
+		if t.Name == name {
+			i.Tunnels = append(i.Tunnels[:n], i.Tunnels[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the inventory back to its file. The file holds the auth
+// token for each tunnel, so it is only readable by the current user.
+func (i *Inventory) Save() error {
+	if err := os.MkdirAll(filepath.Dir(i.path), 0700); err != nil {
+		return err
+	}
+
+	sort.Slice(i.Tunnels, func(a, b int) bool {
+		return i.Tunnels[a].Name < i.Tunnels[b].Name
+	})
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(i.path), ".tunnels-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), i.path)
+}
@@ -0,0 +1,115 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Load_MissingFileIsEmpty(t *testing.T) {
+	inv, err := Load(filepath.Join(t.TempDir(), "tunnels.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(inv.Tunnels) != 0 {
+		t.Errorf("want no tunnels, but got: %d", len(inv.Tunnels))
+	}
+}
+
+func Test_Save_RoundTripAndPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "tunnels.json")
+
+	inv, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	inv.Put(Tunnel{
+		Name:      "tunnel-b",
+		Provider:  "digitalocean",
+		Region:    "lon1",
+		HostID:    "1234",
+		IP:        "192.0.2.1",
+		Mode:      "https",
+		Domains:   []string{"b.example.com"},
+		Token:     "secret",
+		CreatedAt: created,
+	})
+	inv.Put(Tunnel{Name: "tunnel-a", Provider: "hetzner", HostID: "5678"})
+
+	if err := inv.Save(); err != nil {
+		t.Fatalf("unexpected error saving: %s", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("want permissions 0600, but got: %o", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Tunnels) != 2 {
+		t.Fatalf("want 2 tunnels, but got: %d", len(loaded.Tunnels))
+	}
+	if loaded.Tunnels[0].Name != "tunnel-a" {
+		t.Errorf("want tunnels sorted by name, but got: %s first", loaded.Tunnels[0].Name)
+	}
+
+	got, ok := loaded.Get("tunnel-b")
+	if !ok {
+		t.Fatalf("want tunnel-b to be found")
+	}
+	if got.Token != "secret" || got.IP != "192.0.2.1" || !got.CreatedAt.Equal(created) {
+		t.Errorf("tunnel not loaded correctly, got: %+v", got)
+	}
+}
+
+func Test_Put_ReplacesByName(t *testing.T) {
+	inv := &Inventory{}
+	inv.Put(Tunnel{Name: "tunnel", HostID: "1"})
+	inv.Put(Tunnel{Name: "tunnel", HostID: "1", IP: "192.0.2.1"})
+
+	if len(inv.Tunnels) != 1 {
+		t.Fatalf("want 1 tunnel, but got: %d", len(inv.Tunnels))
+	}
+	if inv.Tunnels[0].IP != "192.0.2.1" {
+		t.Errorf("want IP to be updated, but got: %q", inv.Tunnels[0].IP)
+	}
+}
+
+func Test_Find_ByIDOrIP(t *testing.T) {
+	inv := &Inventory{}
+	inv.Put(Tunnel{Name: "do", Provider: "digitalocean", HostID: "1", IP: "192.0.2.1"})
+	inv.Put(Tunnel{Name: "hetzner", Provider: "hetzner", HostID: "1", IP: "192.0.2.2"})
+
+	if got, ok := inv.Find("hetzner", "1", ""); !ok || got.Name != "hetzner" {
+		t.Errorf("want hetzner tunnel by ID, but got: %+v", got)
+	}
+	if got, ok := inv.Find("digitalocean", "", "192.0.2.1"); !ok || got.Name != "do" {
+		t.Errorf("want do tunnel by IP, but got: %+v", got)
+	}
+	if _, ok := inv.Find("digitalocean", "", "192.0.2.2"); ok {
+		t.Errorf("want no tunnel for an IP on another provider")
+	}
+}
+
+func Test_Remove(t *testing.T) {
+	inv := &Inventory{}
+	inv.Put(Tunnel{Name: "tunnel"})
+
+	if !inv.Remove("tunnel") {
+		t.Errorf("want tunnel to be removed")
+	}
+	if inv.Remove("tunnel") {
+		t.Errorf("want false when removing a missing tunnel")
+	}
+}